@@ -0,0 +1,177 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const announcement = `{{.ProjectName}} {{.Version}} has been released!
+{{- if .PreRelease}} This is a pre-release.{{end}}
+{{- range $highlight := .Highlights}}
+{{- if $highlight.Name}}
+
+{{$highlight.Name}}
+{{- end}}
+{{- range $change := $highlight.Changes}}
+* {{$change.Change.Title}}
+{{- end}}
+{{- end}}
+
+https://github.com/{{.GithubRepo}}/releases/tag/{{.Tag}}
+`
+
+// announceConfig configures the integrations to announce a release with
+// once it has been published
+type announceConfig struct {
+	// Template is the path to the announcement template, a condensed
+	// built-in template is used by default
+	Template string        `toml:"template" json:"template"`
+	Matrix   *matrixConfig `toml:"matrix" json:"matrix"`
+	SMTP     *smtpConfig   `toml:"smtp" json:"smtp"`
+}
+
+type announcer interface {
+	announce(subject, body string) error
+}
+
+// announcers returns the configured announcers
+func (ac announceConfig) announcers() []announcer {
+	var announcers []announcer
+	if ac.Matrix != nil {
+		announcers = append(announcers, ac.Matrix)
+	}
+	if ac.SMTP != nil {
+		announcers = append(announcers, ac.SMTP)
+	}
+	return announcers
+}
+
+// announceRelease renders the announcement and sends it with each of the
+// configured announcers
+func announceRelease(r *release) error {
+	announcers := r.Announce.announcers()
+	if len(announcers) == 0 {
+		return nil
+	}
+	tmpl := announcement
+	if r.Announce.Template != "" {
+		b, err := os.ReadFile(r.Announce.Template)
+		if err != nil {
+			return fmt.Errorf("unable to read announcement template: %w", err)
+		}
+		tmpl = string(b)
+	}
+	var body bytes.Buffer
+	if err := renderTemplate(&body, tmpl, r); err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("[ANNOUNCE] %s %s released", r.ProjectName, r.Version)
+	for _, a := range announcers {
+		if err := a.announce(subject, body.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matrixConfig posts announcements to a Matrix room, the access token is
+// read from the MATRIX_ACCESS_TOKEN environment variable
+type matrixConfig struct {
+	Homeserver string `toml:"homeserver" json:"homeserver"`
+	Room       string `toml:"room" json:"room"`
+}
+
+// announce sends the announcement as a message to the room
+//
+// See https://spec.matrix.org/v1.8/client-server-api/#put_matrixclientv3roomsroomidsendeventtypetxnid
+func (mc *matrixConfig) announce(subject, body string) error {
+	token := os.Getenv("MATRIX_ACCESS_TOKEN")
+	if token == "" {
+		return errors.New("MATRIX_ACCESS_TOKEN must be set to announce in matrix")
+	}
+	b, err := json.Marshal(map[string]string{
+		"msgtype": "m.text",
+		"body":    body,
+	})
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/release-tool-%d", strings.TrimSuffix(mc.Homeserver, "/"), url.PathEscape(mc.Room), time.Now().UnixNano())
+	req, err := http.NewRequest("PUT", u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d announcing in matrix room %s", resp.StatusCode, mc.Room)
+	}
+	logrus.Infof("Announced release in matrix room %s", mc.Room)
+	return nil
+}
+
+// smtpConfig sends announcements by email, such as to a mailing list. The
+// credentials are read from the SMTP_USERNAME and SMTP_PASSWORD environment
+// variables when set.
+type smtpConfig struct {
+	// Server is the host and port of the SMTP server
+	Server string   `toml:"server" json:"server"`
+	From   string   `toml:"from" json:"from"`
+	To     []string `toml:"to" json:"to"`
+}
+
+func (sc *smtpConfig) announce(subject, body string) error {
+	if len(sc.To) == 0 {
+		return errors.New("no recipients configured for smtp announcement")
+	}
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		host := sc.Server
+		if i := strings.LastIndex(host, ":"); i > 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", sc.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(sc.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	if err := smtp.SendMail(sc.Server, auth, sc.From, sc.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send announcement email: %w", err)
+	}
+	logrus.Infof("Announced release by email to %s", strings.Join(sc.To, ", "))
+	return nil
+}
@@ -0,0 +1,65 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnnounceMatrix(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || !strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/rooms/!room:example.com/send/m.room.message/") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("unexpected authorization %q", auth)
+		}
+		var msg map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		body = msg["body"]
+	}))
+	defer srv.Close()
+	t.Setenv("MATRIX_ACCESS_TOKEN", "secret")
+
+	r := &release{
+		ProjectName: "containerd",
+		GithubRepo:  "containerd/containerd",
+		Tag:         "v1.7.1",
+		Version:     "1.7.1",
+		Highlights: []highlightCategory{{
+			Name:    "Runtime",
+			Changes: []highlightChange{{Change: &change{Title: "Fix shim leak"}}},
+		}},
+		Announce: announceConfig{
+			Matrix: &matrixConfig{Homeserver: srv.URL, Room: "!room:example.com"},
+		},
+	}
+	if err := announceRelease(r); err != nil {
+		t.Fatal(err)
+	}
+	expected := "containerd 1.7.1 has been released!\n\nRuntime\n* Fix shim leak\n\nhttps://github.com/containerd/containerd/releases/tag/v1.7.1\n"
+	if body != expected {
+		t.Fatalf("unexpected announcement %q, expected %q", body, expected)
+	}
+}
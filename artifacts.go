@@ -0,0 +1,115 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// artifactConfig identifies a release binary of the previous and current
+// release by path relative to the release file or by the name of an asset
+// of the GitHub release
+type artifactConfig struct {
+	Platform string `toml:"platform" json:"platform"`
+	Previous string `toml:"previous" json:"previous"`
+	Current  string `toml:"current" json:"current"`
+}
+
+type artifactSize struct {
+	Platform string `json:"platform"`
+	Previous int64  `json:"previous"`
+	Current  int64  `json:"current"`
+}
+
+// Delta returns the change in size from the previous release
+func (as artifactSize) Delta() int64 {
+	return as.Current - as.Previous
+}
+
+// Change returns the formatted size change, such as "+1.2 MiB (+2.5%)"
+func (as artifactSize) Change() string {
+	delta := as.Delta()
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+		delta = -delta
+	}
+	change := sign + humanizeBytes(delta)
+	if as.Previous > 0 {
+		change += fmt.Sprintf(" (%s%.1f%%)", sign, float64(delta)*100/float64(as.Previous))
+	}
+	return change
+}
+
+// getArtifactSizes returns the sizes of the configured artifacts, local
+// paths are relative to the release file directory and the previous and
+// current tags are used to look up release assets
+func getArtifactSizes(artifacts []artifactConfig, dir, repo, previous, current string, cache Cache) ([]artifactSize, error) {
+	var sizes []artifactSize
+	assets := map[string]map[string]int64{}
+	for _, a := range artifacts {
+		prev, err := artifactFileSize(a.Previous, dir, repo, previous, assets, cache)
+		if err != nil {
+			return nil, fmt.Errorf("previous %s artifact: %w", a.Platform, err)
+		}
+		cur, err := artifactFileSize(a.Current, dir, repo, current, assets, cache)
+		if err != nil {
+			return nil, fmt.Errorf("current %s artifact: %w", a.Platform, err)
+		}
+		sizes = append(sizes, artifactSize{
+			Platform: a.Platform,
+			Previous: prev,
+			Current:  cur,
+		})
+	}
+	return sizes, nil
+}
+
+// artifactFileSize returns the size of the local file relative to the
+// directory or otherwise of the asset with the name in the release for the
+// tag
+func artifactFileSize(name, dir, repo, tag string, assets map[string]map[string]int64, cache Cache) (int64, error) {
+	file := name
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	if fi, err := os.Stat(file); err == nil {
+		return fi.Size(), nil
+	}
+	if tag == "" {
+		return 0, fmt.Errorf("%s not found", name)
+	}
+	release, ok := assets[tag]
+	if !ok {
+		info, err := getReleaseInfo(repo, tag, cache)
+		if err != nil {
+			return 0, fmt.Errorf("%s not found locally and failed to get release %s: %w", name, tag, err)
+		}
+		release = map[string]int64{}
+		for _, asset := range info.Assets {
+			release[asset.Name] = asset.Size
+		}
+		assets[tag] = release
+	}
+	size, ok := release[name]
+	if !ok {
+		return 0, fmt.Errorf("%s not found locally or in release %s", name, tag)
+	}
+	return size, nil
+}
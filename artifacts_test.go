@@ -0,0 +1,72 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetArtifactSizes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/containerd/containerd/releases/tags/v1.0.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(releaseInfo{TagName: "v1.0.0", Assets: []releaseAsset{{Name: "containerd-1.0.0-linux-amd64.tar.gz", Size: 1000}}})
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "containerd-1.1.0-linux-amd64.tar.gz"), make([]byte, 1200), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := []artifactConfig{{
+		Platform: "linux/amd64",
+		Previous: "containerd-1.0.0-linux-amd64.tar.gz",
+		Current:  "./bin/containerd-1.1.0-linux-amd64.tar.gz",
+	}}
+	sizes, err := getArtifactSizes(artifacts, dir, "containerd/containerd", "v1.0.0", "v1.1.0", &dirCache{root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 1 || sizes[0].Previous != 1000 || sizes[0].Current != 1200 {
+		t.Fatalf("unexpected sizes %+v", sizes)
+	}
+	if change := sizes[0].Change(); change != "+200 B (+20.0%)" {
+		t.Errorf("unexpected change %q", change)
+	}
+
+	// Local paths are not resolved against the working directory
+	if _, err := getArtifactSizes(artifacts, t.TempDir(), "containerd/containerd", "v1.0.0", "", nilCache{}); err == nil {
+		t.Error("expected error for current artifact outside of the release file directory")
+	}
+}
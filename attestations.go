@@ -0,0 +1,139 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// predicateNames are the names of well known attestation predicate types
+var predicateNames = map[string]string{
+	"https://slsa.dev/provenance/v1":   "SLSA provenance v1",
+	"https://slsa.dev/provenance/v0.2": "SLSA provenance v0.2",
+	"https://spdx.dev/Document":        "SPDX SBOM",
+	"https://cyclonedx.org/bom":        "CycloneDX SBOM",
+}
+
+// attestation lists the attestations of a release artifact, such as the
+// build provenance generated when publishing from GitHub Actions
+type attestation struct {
+	Filename string `json:"filename"`
+	Hash     string `json:"hash"`
+
+	// Predicates are the names of the predicate types of the attestations
+	Predicates []string `json:"predicates"`
+}
+
+// getAttestations returns the attestations of the downloads stored in the
+// GitHub repository, downloads without attestations are omitted
+//
+// See https://docs.github.com/en/rest/repos/repos?apiVersion=2022-11-28#list-attestations
+func getAttestations(repo string, downloads []download) ([]attestation, error) {
+	var attestations []attestation
+	for _, d := range downloads {
+		u := fmt.Sprintf("https://api.github.com/repos/%s/attestations/sha256:%s", repo, d.Hash)
+		predicates, err := getAttestationPredicates(u)
+		if err != nil {
+			return nil, err
+		}
+		if len(predicates) > 0 {
+			attestations = append(attestations, attestation{
+				Filename:   d.Filename,
+				Hash:       d.Hash,
+				Predicates: predicates,
+			})
+		}
+	}
+	return attestations, nil
+}
+
+// getAttestationPredicates returns the names of the sorted predicate types
+// of the attestations at the url, a digest without attestations is not found
+func getAttestationPredicates(u string) ([]string, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/vnd.github+json")
+	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
+	setGithubAuth(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	} else if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, u)
+	}
+
+	var result struct {
+		Attestations []struct {
+			Bundle struct {
+				DSSEEnvelope struct {
+					Payload string `json:"payload"`
+				} `json:"dsseEnvelope"`
+			} `json:"bundle"`
+		} `json:"attestations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	var predicates []string
+	for _, a := range result.Attestations {
+		payload, err := base64.StdEncoding.DecodeString(a.Bundle.DSSEEnvelope.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attestation payload from %s: %w", u, err)
+		}
+		var statement struct {
+			PredicateType string `json:"predicateType"`
+		}
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			return nil, fmt.Errorf("invalid attestation statement from %s: %w", u, err)
+		}
+		name := predicateName(statement.PredicateType)
+		if _, ok := seen[name]; ok || name == "" {
+			continue
+		}
+		seen[name] = struct{}{}
+		predicates = append(predicates, name)
+	}
+	sort.Strings(predicates)
+	return predicates, nil
+}
+
+// predicateName returns the name of a well known predicate type, ignoring
+// the version of SBOM documents, or otherwise the type itself
+func predicateName(predicateType string) string {
+	if name, ok := predicateNames[predicateType]; ok {
+		return name
+	}
+	for prefix, name := range predicateNames {
+		if strings.HasPrefix(predicateType, prefix+"/") {
+			return name
+		}
+	}
+	return predicateType
+}
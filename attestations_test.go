@@ -0,0 +1,64 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetAttestations(t *testing.T) {
+	statement := func(predicateType string) string {
+		return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`{"_type":"https://in-toto.io/Statement/v1","predicateType":%q}`, predicateType)))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/containerd/containerd/attestations/sha256:"+strings.Repeat("a", 64) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"attestations":[{"bundle":{"dsseEnvelope":{"payload":%q}}},{"bundle":{"dsseEnvelope":{"payload":%q}}},{"bundle":{"dsseEnvelope":{"payload":%q}}}]}`,
+			statement("https://spdx.dev/Document/v2.3"), statement("https://slsa.dev/provenance/v1"), statement("https://slsa.dev/provenance/v1"))
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	attestations, err := getAttestations("containerd/containerd", []download{
+		{Filename: "containerd-2.0.0-linux-amd64.tar.gz", Hash: strings.Repeat("a", 64)},
+		{Filename: "containerd-2.0.0-linux-arm64.tar.gz", Hash: strings.Repeat("b", 64)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []attestation{{
+		Filename:   "containerd-2.0.0-linux-amd64.tar.gz",
+		Hash:       strings.Repeat("a", 64),
+		Predicates: []string{"SLSA provenance v1", "SPDX SBOM"},
+	}}
+	if !reflect.DeepEqual(attestations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, attestations)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var backportCheckCommand = &cli.Command{
+	Name:      "backport-check",
+	Usage:     "list labeled mainline pull requests which have not been backported",
+	ArgsUsage: "<range>",
+	Description: `Compares the changes in a release branch range, such as
+"v1.7.0..release/1.7", against the merged mainline pull requests with the
+cherry-pick label and lists those which have not been backported yet.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "github-repo",
+			Usage:    "github repository of the project",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "label",
+			Usage:    "label of pull requests to be backported, such as \"cherry-pick/1.7.x\"",
+			Required: true,
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the release branch range as the first argument")
+		}
+		var (
+			repo       = context.String("github-repo")
+			label      = context.String("label")
+			rangeParts = strings.SplitN(context.Args().First(), "..", 2)
+			previous   string
+			commit     = rangeParts[0]
+		)
+		if len(rangeParts) == 2 {
+			previous, commit = rangeParts[0], rangeParts[1]
+		}
+		cache, _, err := openCache(context.String("cache"))
+		if err != nil {
+			return err
+		}
+
+		candidates, err := getLabeledPullRequests(repo, label)
+		if err != nil {
+			return err
+		}
+		changes, err := changelog(previous, commit)
+		if err != nil {
+			return err
+		}
+		p := githubChange(repo, "", cache)
+		for _, c := range changes {
+			if err := p.process(c); err != nil {
+				return err
+			}
+		}
+
+		missing := notBackported(candidates, changes)
+		for _, pr := range missing {
+			fmt.Fprintf(os.Stdout, "* %s ([#%d](%s))\n", pr.Title, pr.Number, pr.Link)
+		}
+		logrus.Infof("%d of %d pull requests labeled %s not backported", len(missing), len(candidates), label)
+		return nil
+	},
+}
+
+// notBackported returns the candidate pull requests which are neither
+// referenced as the original of a backport nor merged with the same title
+func notBackported(candidates []issueInfo, changes []*change) []issueInfo {
+	var (
+		backported = map[string]struct{}{}
+		titles     = map[string]struct{}{}
+	)
+	for _, c := range changes {
+		if c.Backport != "" {
+			backported[c.Backport] = struct{}{}
+		}
+		if c.IsMerge {
+			titles[c.Title] = struct{}{}
+		}
+	}
+
+	var missing []issueInfo
+	for _, pr := range candidates {
+		if _, ok := backported[fmt.Sprintf("#%d", pr.Number)]; ok {
+			continue
+		}
+		if _, ok := titles[pr.Title]; ok {
+			logrus.Debugf("Matched #%d by title", pr.Number)
+			continue
+		}
+		missing = append(missing, pr)
+	}
+	return missing
+}
+
+type issueInfo struct {
+	Number      int    `json:"number"`
+	Title       string `json:"title"`
+	Link        string `json:"html_url"`
+	PullRequest *struct {
+		MergedAt string `json:"merged_at"`
+	} `json:"pull_request"`
+}
+
+// getLabeledPullRequests returns the merged pull requests with the label
+//
+// See https://docs.github.com/en/rest/issues/issues?apiVersion=2022-11-28#list-repository-issues
+func getLabeledPullRequests(repo, label string) ([]issueInfo, error) {
+	var prs []issueInfo
+	for page := 1; ; page++ {
+		u := fmt.Sprintf("https://api.github.com/repos/%s/issues?state=closed&labels=%s&per_page=%d&page=%d", repo, url.QueryEscape(label), githubPageSize, page)
+		var issues []issueInfo
+		if err := getGithubJSON(u, &issues); err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			if issue.PullRequest != nil && issue.PullRequest.MergedAt != "" {
+				prs = append(prs, issue)
+			}
+		}
+		if len(issues) < githubPageSize {
+			return prs, nil
+		}
+	}
+}
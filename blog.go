@@ -0,0 +1,89 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// blogPost is the default blog post template, front matter is written in
+// YAML which is supported by both Hugo and Jekyll
+const blogPost = `---
+title: "{{.ProjectName}} {{.Version}} released"
+date: {{.GeneratedAt.Format "2006-01-02"}}
+{{- with .Blog.Layout}}
+layout: {{.}}
+{{- end}}
+{{- with .Blog.Tags}}
+tags:
+{{- range $tag := .}}
+  - {{$tag}}
+{{- end}}
+{{- end}}
+---
+
+Welcome to the {{.Tag}} release of {{.ProjectName}}!
+
+{{.Preface}}
+
+{{- if .Highlights}}
+
+## Highlights
+{{- range $highlight := .Highlights}}
+
+{{- if $highlight.Name}}
+
+### {{$highlight.Name}}
+{{- end}}
+{{ range $change := $highlight.Changes}}
+* {{if $change.Project}}{{$change.Project}}: {{end}}{{ $change.Change.Formatted }}
+{{- end}}
+{{- end}}
+{{- end}}
+
+See the [full release notes](https://github.com/{{.GithubRepo}}/releases/tag/{{.Tag}}) for all changes.
+`
+
+// blogConfig configures the blog post scaffolding for static site
+// generators
+type blogConfig struct {
+	// Layout is the layout set in the front matter
+	Layout string `toml:"layout" json:"layout"`
+	// Tags are the tags set in the front matter
+	Tags []string `toml:"tags" json:"tags"`
+	// Template is the path to a template to use in place of the default
+	Template string `toml:"template" json:"template"`
+}
+
+// writeBlogPost renders the blog post for the release to the file
+func writeBlogPost(path string, r *release) error {
+	tmpl := blogPost
+	if r.Blog.Template != "" {
+		b, err := os.ReadFile(r.Blog.Template)
+		if err != nil {
+			return fmt.Errorf("unable to read blog template: %w", err)
+		}
+		tmpl = string(b)
+	}
+	var b bytes.Buffer
+	if err := renderTemplate(&b, tmpl, r); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
@@ -0,0 +1,57 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+// githubReleaseBodyLimit is the maximum size of the body of a GitHub release
+const githubReleaseBodyLimit = 125000
+
+// renderWithinBudget renders the notes and, while they are larger than
+// maxBytes, collapses the largest commit list of the release into the count
+// of its commits and the comparison link, rendering again. The names of the
+// collapsed commit lists are returned. A maxBytes of 0 disables trimming.
+func renderWithinBudget(r *release, maxBytes int, render func() (string, error)) (string, []string, error) {
+	notes, err := render()
+	if err != nil {
+		return "", nil, err
+	}
+	var trimmed []string
+	for maxBytes > 0 && len(notes) > maxBytes {
+		largest := -1
+		for i, pc := range r.Changes {
+			if len(pc.Changes) > 0 && (largest < 0 || len(pc.Changes) > len(r.Changes[largest].Changes)) {
+				largest = i
+			}
+		}
+		if largest < 0 {
+			break
+		}
+		pc := &r.Changes[largest]
+		pc.Truncated += len(pc.Changes)
+		pc.Changes = nil
+
+		name := "the commits of " + r.ProjectName
+		if pc.Name != "" {
+			name = "the commits of " + pc.Name
+		}
+		trimmed = append(trimmed, name)
+
+		if notes, err = render(); err != nil {
+			return "", nil, err
+		}
+	}
+	return notes, trimmed, nil
+}
@@ -0,0 +1,70 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderWithinBudget(t *testing.T) {
+	commits := func(n int) []*change {
+		var changes []*change
+		for i := 0; i < n; i++ {
+			changes = append(changes, &change{Formatted: fmt.Sprintf("Change %d with a long enough description", i)})
+		}
+		return changes
+	}
+	r := &release{
+		ProjectName: "containerd",
+		Changes: []projectChange{
+			{Changes: commits(50), CompareLink: "https://github.com/containerd/containerd/compare/v1.7.0...v1.7.1"},
+			{Name: "ttrpc", Changes: commits(20)},
+			{Name: "log", Changes: commits(2)},
+		},
+	}
+	render := func() (string, error) {
+		var b strings.Builder
+		if err := renderTemplate(&b, releaseNotes, r); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+	full, err := render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notes, trimmed, err := renderWithinBudget(r, len(full)-100, render)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trimmed) != 1 || trimmed[0] != "the commits of containerd" {
+		t.Fatalf("unexpected trimmed sections %v", trimmed)
+	}
+	if len(notes) > len(full)-100 || !strings.Contains(notes, "50 more commits, see the [full comparison]") {
+		t.Errorf("unexpected trimmed notes:\n%s", notes)
+	}
+	if r.Changes[1].Total() != 20 || len(r.Changes[1].Changes) != 20 {
+		t.Errorf("unexpected trimming of ttrpc changes %v", r.Changes[1])
+	}
+
+	if _, trimmed, _ := renderWithinBudget(r, 10, render); len(trimmed) != 2 {
+		t.Errorf("expected all remaining commit lists trimmed, got %v", trimmed)
+	}
+}
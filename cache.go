@@ -17,10 +17,24 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/base32"
+	"fmt"
 	"hash/fnv"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 type Cache interface {
@@ -28,6 +42,44 @@ type Cache interface {
 	Put(string, []byte) error
 }
 
+// openCache returns the cache for the given cache directory along with the
+// directory to use for git clones. When no directory is provided, a cache
+// which never stores anything is returned with an empty git root.
+func openCache(dir string) (Cache, string, error) {
+	if dir == "" {
+		return nilCache{}, "", nil
+	}
+	cd, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = os.Stat(cd); err != nil {
+		return nil, "", fmt.Errorf("unable to use cache dir: %w", err)
+	}
+	gitRoot := filepath.Join(cd, "git")
+	cacheRoot := filepath.Join(cd, "object")
+	if err := os.MkdirAll(gitRoot, 0755); err != nil {
+		return nil, "", fmt.Errorf("unable to mkdir %s: %w", gitRoot, err)
+	}
+	switch cacheBackend {
+	case "dir":
+	case "file":
+		fc, err := openFileCache(cacheRoot + ".db")
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to open cache file: %w", err)
+		}
+		return fc, gitRoot, nil
+	default:
+		return nil, "", fmt.Errorf("unknown cache backend %q", cacheBackend)
+	}
+	if err := os.MkdirAll(cacheRoot, 0755); err != nil {
+		return nil, "", fmt.Errorf("unable to mkdir: %s: %w", cacheRoot, err)
+	}
+	return &dirCache{
+		root: cacheRoot,
+	}, gitRoot, nil
+}
+
 type nilCache struct{}
 
 func (nc nilCache) Get(string) ([]byte, bool) {
@@ -38,22 +90,497 @@ func (nc nilCache) Put(string, []byte) error {
 	return nil
 }
 
+// cacheIndex is the name of the file mapping cache object paths to their
+// original keys, each line holds the object path and key separated by a tab
+const cacheIndex = "index"
+
+// dirCache stores objects in namespaced subdirectories of the root
+// directory, named by the hash of their key.
 type dirCache struct {
 	root string
+
+	mu sync.Mutex
 }
 
 func (dc *dirCache) Get(key string) ([]byte, bool) {
-	b, err := os.ReadFile(dc.path(key))
+	b, err := os.ReadFile(filepath.Join(dc.root, dc.path(key)))
 	return b, err == nil
 }
 
 func (dc *dirCache) Put(key string, value []byte) error {
-	return os.WriteFile(dc.path(key), value, 0755)
+	p := dc.path(key)
+	full := filepath.Join(dc.root, p)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	_, statErr := os.Stat(full)
+	if err := writeFileAtomic(full, value); err != nil {
+		return err
+	}
+	if statErr == nil {
+		// already indexed
+		return nil
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(dc.root, cacheIndex), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\t%s\n", filepath.ToSlash(p), key)
+	return err
+}
+
+// ModTime returns when the object for the key was last stored
+func (dc *dirCache) ModTime(key string) (time.Time, bool) {
+	fi, err := os.Stat(filepath.Join(dc.root, dc.path(key)))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
+}
+
+// writeFileAtomic writes the file through a temporary file in the same
+// directory, so readers never see a partial object. Renaming over an
+// existing file is supported on all platforms.
+func writeFileAtomic(name string, value []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-"+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(value); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
 }
 
+// path returns the object path relative to the cache root
 func (dc *dirCache) path(key string) string {
+	return filepath.FromSlash(objectPath(key))
+}
+
+// objectPath returns the slash separated path for storing a key, made up
+// of the key namespace and hash
+func objectPath(key string) string {
 	h := fnv.New128a()
 	h.Write([]byte(key))
-	h.Sum(nil)
-	return filepath.Join(dc.root, base32.StdEncoding.EncodeToString(h.Sum(nil)))
+	return path.Join(cacheNamespace(key), base32.StdEncoding.EncodeToString(h.Sum(nil)))
+}
+
+// index reads the index file returning the keys by object path
+func (dc *dirCache) index() (map[string]string, error) {
+	b, err := os.ReadFile(filepath.Join(dc.root, cacheIndex))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	index := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		index[parts[0]] = parts[1]
+	}
+	return index, s.Err()
+}
+
+type cacheEntry struct {
+	Key       string
+	Namespace string
+	Path      string
+	Size      int64
+	ModTime   time.Time
+}
+
+// entries returns all objects in the cache sorted by namespace and key.
+// Objects missing from the index, such as those written by the older flat
+// cache layout, have an empty key and the "unknown" namespace.
+func (dc *dirCache) entries() ([]cacheEntry, error) {
+	index, err := dc.index()
+	if err != nil {
+		return nil, err
+	}
+	var entries []cacheEntry
+	err = filepath.WalkDir(dc.root, func(p string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.IsDir() || strings.HasPrefix(de.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(dc.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == cacheIndex {
+			return nil
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return err
+		}
+		e := cacheEntry{
+			Key:       index[rel],
+			Namespace: "unknown",
+			Path:      p,
+			Size:      fi.Size(),
+			ModTime:   fi.ModTime(),
+		}
+		if e.Key != "" {
+			e.Namespace = path.Dir(rel)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Namespace == entries[j].Namespace {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Namespace < entries[j].Namespace
+	})
+	return entries, nil
+}
+
+// remove deletes the given entries and rewrites the index without them
+func (dc *dirCache) remove(entries ...cacheEntry) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	index, err := dc.index()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		rel, err := filepath.Rel(dc.root, e.Path)
+		if err != nil {
+			return err
+		}
+		delete(index, filepath.ToSlash(rel))
+	}
+
+	paths := make([]string, 0, len(index))
+	for p := range index {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var b bytes.Buffer
+	for _, p := range paths {
+		fmt.Fprintf(&b, "%s\t%s\n", p, index[p])
+	}
+	return os.WriteFile(filepath.Join(dc.root, cacheIndex), b.Bytes(), 0644)
+}
+
+// cacheNamespace returns the kind of data stored for a cache key, used as
+// the subdirectory for the object
+func cacheNamespace(key string) string {
+	switch {
+	case strings.HasPrefix(key, "etag "):
+		return "github/etag"
+	case strings.HasPrefix(key, "https://api.github.com/") && strings.Contains(key, "/commits/") && strings.HasSuffix(key, "/pulls number"):
+		return "github/commit-pr"
+	case strings.HasPrefix(key, "https://api.github.com/") && strings.Contains(key, "/pulls/"):
+		return "github/pr"
+	case strings.HasPrefix(key, "https://api.github.com/") && strings.Contains(key, "/security-advisories/"):
+		return "github/advisory"
+	case strings.HasPrefix(key, "https://api.github.com/") && strings.Contains(key, "/releases/"):
+		return "github/release"
+	case strings.HasPrefix(key, "https://gitlab.com/api/") && strings.Contains(key, "/merge_requests/"):
+		return "gitlab/mr"
+	case strings.Contains(key, "/api/v1/repos/") && strings.Contains(key, "/pulls/"):
+		return "gitea/pr"
+	case strings.HasPrefix(key, "git ls-remote "):
+		return "git/ls-remote"
+	case strings.HasPrefix(key, "dependencies "):
+		return "git/dependencies"
+	case strings.HasSuffix(key, "?go-get=1"):
+		return "goget"
+	case strings.HasPrefix(key, "goproxy "):
+		return "goproxy"
+	case strings.HasPrefix(key, "link-check "):
+		return "links"
+	}
+	return "other"
+}
+
+// refreshNamespaces maps the cache refresh phases to their namespaces
+var refreshNamespaces = map[string]string{
+	"prs":        "github/pr",
+	"commit-prs": "github/commit-pr",
+	"mrs":        "gitlab/mr",
+	"gitea-prs":  "gitea/pr",
+	"advisories": "github/advisory",
+	"releases":   "github/release",
+	"git":        "git/ls-remote",
+	"deps":       "git/dependencies",
+	"goget":      "goget",
+	"goproxy":    "goproxy",
+	"links":      "links",
+}
+
+// refreshPhases are all cache refresh phases, used to refresh everything
+var refreshPhases = []string{"prs", "commit-prs", "mrs", "gitea-prs", "advisories", "releases", "git", "deps", "goget", "goproxy", "links"}
+
+// refreshingCache ignores cached values in refreshed namespaces so they are
+// fetched again and overwritten
+type refreshingCache struct {
+	Cache
+	namespaces map[string]struct{}
+}
+
+// refreshCache wraps the cache to refresh entries for the given phases
+func refreshCache(cache Cache, phases ...string) Cache {
+	namespaces := map[string]struct{}{}
+	for _, phase := range phases {
+		namespaces[refreshNamespaces[phase]] = struct{}{}
+	}
+	return &refreshingCache{
+		Cache:      cache,
+		namespaces: namespaces,
+	}
+}
+
+func (rc *refreshingCache) Get(key string) ([]byte, bool) {
+	if _, ok := rc.namespaces[cacheNamespace(key)]; ok {
+		return nil, false
+	}
+	return rc.Cache.Get(key)
+}
+
+// Stale returns the cached value for the key even when it is being
+// refreshed, so the value can be validated rather than fetched again
+func (rc *refreshingCache) Stale(key string) ([]byte, bool) {
+	return staleValue(rc.Cache, key)
+}
+
+// staleValue returns the value stored for the key, including a value which
+// is ignored by the cache because it is being refreshed
+func staleValue(cache Cache, key string) ([]byte, bool) {
+	if sc, ok := cache.(interface {
+		Stale(string) ([]byte, bool)
+	}); ok {
+		return sc.Stale(key)
+	}
+	return cache.Get(key)
+}
+
+// cacheModTime returns when the value for the key was stored, if the cache
+// keeps track of it
+func cacheModTime(cache Cache, key string) (time.Time, bool) {
+	if mc, ok := cache.(interface {
+		ModTime(string) (time.Time, bool)
+	}); ok {
+		return mc.ModTime(key)
+	}
+	return time.Time{}, false
+}
+
+// expiringCache ignores cached values older than the maximum age of their
+// namespace, so they are fetched again and overwritten. Like refreshed
+// values, expired values are still available to be validated.
+type expiringCache struct {
+	Cache
+	maxAges map[string]time.Duration
+}
+
+// expireCache wraps the cache to expire entries of the phases after their
+// maximum age
+func expireCache(cache Cache, maxAges map[string]time.Duration) Cache {
+	namespaces := map[string]time.Duration{}
+	for phase, maxAge := range maxAges {
+		namespaces[refreshNamespaces[phase]] = maxAge
+	}
+	return &expiringCache{
+		Cache:   cache,
+		maxAges: namespaces,
+	}
+}
+
+func (ec *expiringCache) Get(key string) ([]byte, bool) {
+	if maxAge, ok := ec.maxAges[cacheNamespace(key)]; ok {
+		if modTime, ok := cacheModTime(ec.Cache, key); ok && time.Since(modTime) > maxAge {
+			logrus.WithField("age", time.Since(modTime).Round(time.Second)).Debugf("cache entry %s expired", key)
+			return nil, false
+		}
+	}
+	return ec.Cache.Get(key)
+}
+
+func (ec *expiringCache) Stale(key string) ([]byte, bool) {
+	return staleValue(ec.Cache, key)
+}
+
+// parseCacheTTLs parses maximum ages given as phase=duration, or a single
+// duration for every phase. Durations may also be given in days, such as 7d.
+func parseCacheTTLs(values []string) (map[string]time.Duration, error) {
+	maxAges := map[string]time.Duration{}
+	for _, value := range values {
+		phase, ttl, ok := strings.Cut(value, "=")
+		if !ok {
+			phase, ttl = "", value
+		} else if _, ok := refreshNamespaces[phase]; !ok {
+			return nil, fmt.Errorf("unknown cache phase %q", phase)
+		}
+		var (
+			maxAge time.Duration
+			err    error
+		)
+		if strings.HasSuffix(ttl, "d") {
+			var days int
+			days, err = strconv.Atoi(strings.TrimSuffix(ttl, "d"))
+			maxAge = time.Duration(days) * 24 * time.Hour
+		} else {
+			maxAge, err = time.ParseDuration(ttl)
+		}
+		if err != nil || maxAge <= 0 {
+			return nil, fmt.Errorf("invalid cache ttl %q", value)
+		}
+		if phase == "" {
+			for _, phase := range refreshPhases {
+				if _, ok := maxAges[phase]; !ok {
+					maxAges[phase] = maxAge
+				}
+			}
+			continue
+		}
+		maxAges[phase] = maxAge
+	}
+	return maxAges, nil
+}
+
+// memoryCache keeps the values stored during the run in memory in front of
+// the cache, so values fetched concurrently ahead of processing are reused
+// even without a cache directory
+type memoryCache struct {
+	Cache
+
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func newMemoryCache(cache Cache) *memoryCache {
+	return &memoryCache{
+		Cache:  cache,
+		values: map[string][]byte{},
+	}
+}
+
+func (mc *memoryCache) Get(key string) ([]byte, bool) {
+	mc.mu.Lock()
+	b, ok := mc.values[key]
+	mc.mu.Unlock()
+	if ok {
+		return b, true
+	}
+	return mc.Cache.Get(key)
+}
+
+func (mc *memoryCache) Put(key string, value []byte) error {
+	mc.mu.Lock()
+	mc.values[key] = value
+	mc.mu.Unlock()
+	return mc.Cache.Put(key, value)
+}
+
+func (mc *memoryCache) Stale(key string) ([]byte, bool) {
+	return staleValue(mc.Cache, key)
+}
+
+// httpCache stores objects on a remote server using plain GET and PUT
+// requests, such as an object store bucket or a simple HTTP file server.
+// Reads and writes go through the local cache when provided.
+type httpCache struct {
+	url   string
+	token string
+	local Cache
+}
+
+// newHTTPCache returns a cache backed by the remote url, the token is sent
+// as a bearer token when set
+func newHTTPCache(u, token string, local Cache) Cache {
+	if local == nil {
+		local = nilCache{}
+	}
+	return &httpCache{
+		url:   strings.TrimSuffix(u, "/"),
+		token: token,
+		local: local,
+	}
+}
+
+func (hc *httpCache) Get(key string) ([]byte, bool) {
+	if b, ok := hc.local.Get(key); ok {
+		return b, true
+	}
+	req, err := hc.request("GET", key, nil)
+	if err != nil {
+		return nil, false
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logrus.WithError(err).Debug("remote cache get failed")
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false
+	}
+	hc.local.Put(key, b)
+	return b, true
+}
+
+// ModTime returns when the value was stored in the local cache
+func (hc *httpCache) ModTime(key string) (time.Time, bool) {
+	return cacheModTime(hc.local, key)
+}
+
+func (hc *httpCache) Put(key string, value []byte) error {
+	if err := hc.local.Put(key, value); err != nil {
+		return err
+	}
+	req, err := hc.request("PUT", key, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d for remote cache put", resp.StatusCode)
+	}
+	return nil
+}
+
+func (hc *httpCache) request(method, key string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, hc.url+"/"+objectPath(key), body)
+	if err != nil {
+		return nil, err
+	}
+	if hc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+hc.token)
+	}
+	return req, nil
 }
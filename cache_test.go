@@ -0,0 +1,138 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDirCacheEntries(t *testing.T) {
+	dc := &dirCache{root: t.TempDir()}
+	keys := []string{
+		"https://api.github.com/repos/containerd/containerd/pulls/1 title labels",
+		"git ls-remote https://github.com/containerd/ttrpc v1.0.0 v1.0.0^{}",
+		"https://golang.org/x/sys?go-get=1",
+	}
+	for _, key := range keys {
+		if err := dc.Put(key, []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := dc.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"git/ls-remote", "github/pr", "goget"}
+	if len(entries) != len(expected) {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+	for i, ns := range expected {
+		if entries[i].Namespace != ns {
+			t.Errorf("[%d] unexpected namespace %q, expected %q", i, entries[i].Namespace, ns)
+		}
+	}
+
+	if err := dc.remove(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dc.Get(keys[1]); ok {
+		t.Fatal("expected removed entry to be missing")
+	}
+}
+
+func TestHTTPCache(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		objects = map[string][]byte{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "PUT":
+			b, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = b
+		case "GET":
+			b, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(b)
+		}
+	}))
+	defer srv.Close()
+
+	key := "https://api.github.com/repos/containerd/containerd/pulls/1 title labels"
+	if err := newHTTPCache(srv.URL, "", nil).Put(key, []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	local := &dirCache{root: t.TempDir()}
+	b, ok := newHTTPCache(srv.URL, "", local).Get(key)
+	if !ok || string(b) != "value" {
+		t.Fatalf("unexpected remote value %q", b)
+	}
+	if b, ok := local.Get(key); !ok || string(b) != "value" {
+		t.Fatalf("unexpected local value %q", b)
+	}
+}
+
+func TestExpiringCache(t *testing.T) {
+	maxAges, err := parseCacheTTLs([]string{"prs=2h", "1d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxAges["prs"] != 2*time.Hour || maxAges["goget"] != 24*time.Hour {
+		t.Fatalf("unexpected ttls %v", maxAges)
+	}
+	if _, err := parseCacheTTLs([]string{"labels=1h"}); err == nil {
+		t.Error("expected unknown phase to fail")
+	}
+
+	dc := &dirCache{root: t.TempDir()}
+	pr := "https://api.github.com/repos/containerd/containerd/pulls/1 title labels"
+	goget := "https://github.com/containerd/log?go-get=1"
+	for _, key := range []string{pr, goget} {
+		if err := dc.Put(key, []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-3 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dc.root, dc.path(pr)), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := expireCache(dc, maxAges)
+	if _, ok := cache.Get(pr); ok {
+		t.Error("expected pull request to expire")
+	}
+	if b, ok := staleValue(cache, pr); !ok || string(b) != "value" {
+		t.Errorf("expected expired value to be stale, got %q", b)
+	}
+	if _, ok := cache.Get(goget); !ok {
+		t.Error("expected go-get resolution to be cached")
+	}
+}
@@ -0,0 +1,153 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var cacheCommand = &cli.Command{
+	Name:  "cache",
+	Usage: "inspect and invalidate entries in the cache directory",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "ls",
+			Usage:     "list cached keys with their age and size",
+			ArgsUsage: "[namespace]",
+			Action: func(context *cli.Context) error {
+				entries, err := cacheEntries(context)
+				if err != nil {
+					return err
+				}
+				namespace := context.Args().First()
+				now := time.Now()
+				w := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+				fmt.Fprintln(w, "NAMESPACE\tAGE\tSIZE\tKEY")
+				for _, e := range entries {
+					if namespace != "" && e.Namespace != namespace {
+						continue
+					}
+					fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", e.Namespace, now.Sub(e.ModTime).Round(time.Second), e.Size, e.Key)
+				}
+				return w.Flush()
+			},
+		},
+		{
+			Name:  "stat",
+			Usage: "show the number and size of cached entries by namespace",
+			Action: func(context *cli.Context) error {
+				entries, err := cacheEntries(context)
+				if err != nil {
+					return err
+				}
+				type stat struct {
+					count  int
+					size   int64
+					oldest time.Time
+				}
+				var (
+					namespaces []string
+					stats      = map[string]*stat{}
+				)
+				for _, e := range entries {
+					st, ok := stats[e.Namespace]
+					if !ok {
+						st = &stat{oldest: e.ModTime}
+						stats[e.Namespace] = st
+						namespaces = append(namespaces, e.Namespace)
+					}
+					st.count++
+					st.size += e.Size
+					if e.ModTime.Before(st.oldest) {
+						st.oldest = e.ModTime
+					}
+				}
+				now := time.Now()
+				w := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+				fmt.Fprintln(w, "NAMESPACE\tENTRIES\tSIZE\tOLDEST")
+				for _, ns := range namespaces {
+					st := stats[ns]
+					fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", ns, st.count, st.size, now.Sub(st.oldest).Round(time.Second))
+				}
+				return w.Flush()
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "remove cached entries with keys matching a regular expression",
+			ArgsUsage: "<pattern>",
+			Action: func(context *cli.Context) error {
+				if context.NArg() != 1 {
+					return errors.New("please specify the key pattern as the first argument")
+				}
+				re, err := regexp.Compile(context.Args().First())
+				if err != nil {
+					return fmt.Errorf("unable to compile pattern: %w", err)
+				}
+				store, err := openCacheStore(context)
+				if err != nil {
+					return err
+				}
+				entries, err := store.entries()
+				if err != nil {
+					return err
+				}
+				var matched []cacheEntry
+				for _, e := range entries {
+					if e.Key == "" || !re.MatchString(e.Key) {
+						continue
+					}
+					logrus.Debugf("Removing cache entry %s", e.Key)
+					matched = append(matched, e)
+				}
+				if err := store.remove(matched...); err != nil {
+					return err
+				}
+				logrus.Infof("Removed %d cache entries", len(matched))
+				return nil
+			},
+		},
+	},
+}
+
+func openCacheStore(context *cli.Context) (cacheStore, error) {
+	cache, _, err := openCache(context.String("cache"))
+	if err != nil {
+		return nil, err
+	}
+	store, ok := cache.(cacheStore)
+	if !ok {
+		return nil, errors.New("no cache directory configured, use --cache or RELEASE_TOOL_CACHE")
+	}
+	return store, nil
+}
+
+func cacheEntries(context *cli.Context) ([]cacheEntry, error) {
+	store, err := openCacheStore(context)
+	if err != nil {
+		return nil, err
+	}
+	return store.entries()
+}
@@ -0,0 +1,120 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const changelogTemplate = `
+{{- if .Highlights}}### Highlights
+{{- range $highlight := .Highlights}}
+
+#### {{$highlight.Name}}
+{{ range $change := $highlight.Changes}}
+* {{ $change.Change.Formatted }}
+{{- end}}
+{{- end}}
+
+{{end -}}
+{{- range $project := .Changes}}### Changes
+{{range $change := $project.Changes }}
+{{- if ne $change.Formatted "" }}
+{{if not $change.IsMerge}}  {{end}}* {{$change.Formatted}}
+{{- end}}
+{{- end}}
+{{end -}}
+`
+
+var changelogCommand = &cli.Command{
+	Name:      "changelog",
+	Usage:     "print the changes for a range without generating release notes",
+	ArgsUsage: "<range>",
+	Description: `Prints the changes in a range, such as "v1.7.0..main", using the
+same pull request enrichment as the release notes. Useful for triaging
+pull requests outside of a release.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "github-repo",
+			Usage: "github repository of the project, required for links and highlights",
+		},
+		&cli.BoolFlag{
+			Name:    "linkify",
+			Aliases: []string{"l"},
+			Usage:   "add links to changelog",
+		},
+		&cli.BoolFlag{
+			Name:    "highlights",
+			Aliases: []string{"g"},
+			Usage:   "use highlights based on pull request",
+		},
+		&cli.BoolFlag{
+			Name:    "short",
+			Aliases: []string{"s"},
+			Usage:   "shorten changelog length where possible",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-commits",
+			Usage: "skips commit links and titles",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the range as the first argument")
+		}
+		var (
+			repo        = context.String("github-repo")
+			linkify     = context.Bool("linkify")
+			highlights  = context.Bool("highlights")
+			skipCommits = context.Bool("skip-commits")
+			rangeParts  = strings.SplitN(context.Args().First(), "..", 2)
+			previous    string
+			commit      = rangeParts[0]
+		)
+		if len(rangeParts) == 2 {
+			previous, commit = rangeParts[0], rangeParts[1]
+		}
+		if (linkify || highlights) && repo == "" {
+			return errors.New("github repository is required for links and highlights")
+		}
+		cache, _, err := openCache(context.String("cache"))
+		if err != nil {
+			return err
+		}
+
+		changes, err := changelog(previous, commit)
+		if err != nil {
+			return err
+		}
+		if err := formatChanges(changes, repo, "", cache, linkify || highlights, context.Bool("short"), skipCommits); err != nil {
+			return err
+		}
+		r := &release{}
+		projectChanges := []projectChange{{Changes: changes}}
+		if highlights {
+			r.Highlights = groupHighlights(projectChanges, nil)
+		}
+		if !highlights || !skipCommits {
+			r.Changes = projectChanges
+		}
+		return renderTemplate(os.Stdout, changelogTemplate, r)
+	},
+}
@@ -0,0 +1,159 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const checksumsFile = "SHA256SUMS"
+
+var checksumsCommand = &cli.Command{
+	Name:      "checksums",
+	Usage:     "write the checksums of the release artifacts in a directory",
+	ArgsUsage: "<dir>",
+	Description: `Writes the SHA256 checksums of the files in the directory to SHA256SUMS in
+the format of sha256sum. The file can be signed by running a command with
+the path to the file as the last argument. Pass the file to --checksums when
+generating the release notes to list the downloads.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "name of the checksums file written in the directory",
+			Value: checksumsFile,
+		},
+		&cli.StringFlag{
+			Name:  "sign-command",
+			Usage: "command to sign the checksums file with, such as \"gpg --detach-sign --armor\"",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the artifacts directory as the first argument")
+		}
+		dir := context.Args().First()
+		output := filepath.Join(dir, context.String("output"))
+		downloads, err := writeChecksums(dir, output)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Wrote checksums of %d artifacts to %s", len(downloads), output)
+		if command := context.String("sign-command"); command != "" {
+			if err := signChecksums(command, output); err != nil {
+				return fmt.Errorf("failed to sign checksums: %w", err)
+			}
+			logrus.Infof("Signed %s", output)
+		}
+		return nil
+	},
+}
+
+// writeChecksums computes the checksums of the regular files in the
+// directory, excluding the checksums file and its signatures, and writes
+// them to the output file
+func writeChecksums(dir, output string) ([]download, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var downloads []download
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || name == filepath.Base(output) || strings.HasPrefix(name, filepath.Base(output)+".") {
+			continue
+		}
+		hash, err := fileSHA256(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		downloads = append(downloads, download{Filename: name, Hash: hash})
+	}
+	sort.Slice(downloads, func(i, j int) bool {
+		return downloads[i].Filename < downloads[j].Filename
+	})
+
+	var b bytes.Buffer
+	for _, d := range downloads {
+		fmt.Fprintf(&b, "%s  %s\n", d.Hash, d.Filename)
+	}
+	return downloads, os.WriteFile(output, b.Bytes(), 0644)
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// signChecksums runs the sign command with the checksums file appended as
+// the last argument
+func signChecksums(command, path string) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return errors.New("empty sign command")
+	}
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// readChecksums returns the downloads listed in a checksums file in the
+// format written by sha256sum
+func readChecksums(path string) ([]download, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var downloads []download
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		ln := strings.TrimSpace(s.Text())
+		if ln == "" {
+			continue
+		}
+		hash, name, ok := strings.Cut(ln, " ")
+		if !ok || len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid checksum line %q in %s", ln, path)
+		}
+		// Binary mode is marked with an asterisk before the name
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		downloads = append(downloads, download{Filename: name, Hash: hash})
+	}
+	return downloads, s.Err()
+}
@@ -0,0 +1,60 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"release-tool-1.0.0-linux-amd64.tar.gz":  "linux",
+		"release-tool-1.0.0-darwin-arm64.tar.gz": "darwin",
+		"SHA256SUMS.asc":                         "signature",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "extra"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, checksumsFile)
+	written, err := writeChecksums(dir, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := readChecksums(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 2 || len(read) != 2 {
+		t.Fatalf("unexpected checksums %v, read %v", written, read)
+	}
+	for i, d := range read {
+		if d != written[i] {
+			t.Errorf("[%d] unexpected checksum %v, expected %v", i, d, written[i])
+		}
+	}
+	if read[1].Filename != "release-tool-1.0.0-linux-amd64.tar.gz" || read[1].Hash != "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18" {
+		t.Errorf("unexpected checksum %v", read[1])
+	}
+}
@@ -0,0 +1,138 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var contributorsCommand = &cli.Command{
+	Name:      "contributors",
+	Usage:     "list the contributors for a range",
+	ArgsUsage: "<range>",
+	Description: `Lists the contributors in a range, such as "v1.6.0..v1.7.0", ordered
+by number of commits along with mailmap suggestions. The project .mailmap
+is used when run from the project root.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "github-repo",
+			Usage: "github repository of the project, used to look up contributor handles",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "name or email of a commit author to leave out of the contributors",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the range as the first argument")
+		}
+		var (
+			repo       = context.String("github-repo")
+			rangeParts = strings.SplitN(context.Args().First(), "..", 2)
+			previous   string
+			commit     = rangeParts[0]
+		)
+		if len(rangeParts) == 2 {
+			previous, commit = rangeParts[0], rangeParts[1]
+		}
+		cache, _, err := openCache(context.String("cache"))
+		if err != nil {
+			return err
+		}
+		mailmapPath, err := filepath.Abs(".mailmap")
+		if err != nil {
+			return fmt.Errorf("failed to resolve mailmap: %w", err)
+		}
+		gitConfigs["mailmap.file"] = mailmapPath
+
+		contributors := map[string]contributor{}
+		changes, err := changelog(previous, commit)
+		if err != nil {
+			return err
+		}
+		addContributors(changes, contributors, context.StringSlice("exclude"))
+		all := orderContributors(contributors)
+
+		w := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "### Contributors")
+		fmt.Fprintln(w)
+		for _, c := range all {
+			name := c.Name
+			if repo != "" {
+				handle, err := githubHandle(repo, gitChangeDiff(previous, commit), c.Email, cache)
+				if err != nil {
+					logrus.WithError(err).Warnf("unable to find github handle for %s", c.Email)
+				} else if handle != "" {
+					name = fmt.Sprintf("%s (@%s)", name, handle)
+				}
+			}
+			fmt.Fprintf(w, "* %s\t%s\n", name, pluralize(c.Commits, "commit"))
+		}
+		if suggestions := mailmapSuggestions(all); len(suggestions) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "### Mailmap Suggestions")
+			fmt.Fprintln(w)
+			for _, suggestion := range suggestions {
+				fmt.Fprintf(w, "* %s\n", suggestion)
+			}
+		}
+		return w.Flush()
+	},
+}
+
+// githubHandle returns the github login of the author of a commit by the
+// contributor in the given range
+//
+// See https://docs.github.com/en/rest/commits/commits?apiVersion=2022-11-28#get-a-commit
+func githubHandle(repo, rangeSpec, email string, cache Cache) (string, error) {
+	out, err := git("log", "-1", "--format=%H", "--fixed-strings", "--author=<"+email+">", rangeSpec)
+	if err != nil {
+		return "", err
+	}
+	sha := strings.TrimSpace(string(out))
+	if sha == "" {
+		return "", fmt.Errorf("no commit found for %s", email)
+	}
+	u := fmt.Sprintf("https://api.github.com/repos/%s/commits/%s", repo, sha)
+	key := u + " author"
+	if b, ok := cache.Get(key); ok {
+		return string(b), nil
+	}
+	var info struct {
+		Author *struct {
+			Login string `json:"login"`
+		} `json:"author"`
+	}
+	if err := getGithubJSON(u, &info); err != nil {
+		return "", err
+	}
+	if info.Author == nil {
+		// The email is not associated with a github account
+		return "", nil
+	}
+	cache.Put(key, []byte(info.Author.Login))
+	return info.Author.Login, nil
+}
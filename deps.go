@@ -0,0 +1,166 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+)
+
+var depsCommand = &cli.Command{
+	Name:      "deps",
+	Usage:     "report the dependency changes for a range without generating notes",
+	ArgsUsage: "<range>",
+	Description: `Computes the new, updated and removed dependencies between two
+revisions, such as "v1.7.0..HEAD", along with how each updated version was
+resolved and any resolution failures.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "sub-path",
+			Usage: "subpath of the go module in the repository",
+		},
+		&cli.StringSliceFlag{
+			Name:  "ignore",
+			Usage: "dependencies to ignore",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the range as the first argument")
+		}
+		rangeParts := strings.SplitN(context.Args().First(), "..", 2)
+		if len(rangeParts) != 2 {
+			return errors.New("range must be of the form <previous>..<commit>")
+		}
+		cache, _, err := openCache(context.String("cache"))
+		if err != nil {
+			return err
+		}
+		subpath := context.String("sub-path")
+		previous, err := parseDependencies(rangeParts[0], subpath, nil, cache)
+		if err != nil {
+			return fmt.Errorf("failed to parse dependencies for %s: %w", rangeParts[0], err)
+		}
+		current, err := parseDependencies(rangeParts[1], subpath, nil, cache)
+		if err != nil {
+			return fmt.Errorf("failed to parse dependencies for %s: %w", rangeParts[1], err)
+		}
+
+		delta := getDependencyDelta(previous, current, context.StringSlice("ignore"), cache)
+		w := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tNAME\tPREVIOUS\tCURRENT\tRESOLVED")
+		var failed []dependencyDelta
+		for _, d := range delta {
+			if d.Err != nil {
+				failed = append(failed, d)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Status, d.Name, formatDeltaVersion(d.Previous), formatDeltaVersion(d.Current), d.Method)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			fmt.Println()
+			for _, d := range failed {
+				fmt.Printf("%s: %v\n", d.Name, d.Err)
+			}
+			return fmt.Errorf("failed to resolve %d dependencies", len(failed))
+		}
+		return nil
+	},
+}
+
+type dependencyDelta struct {
+	Name string
+	// Status is one of "new", "updated", "removed" or "failed"
+	Status   string
+	Previous *dependency
+	Current  *dependency
+	// Method is how the shas of updated dependencies were resolved
+	Method string
+	Err    error
+}
+
+// getDependencyDelta returns the changed dependencies sorted by name,
+// updated versions which resolve to the same commit are omitted
+func getDependencyDelta(previous, current []dependency, ignored []string, cache Cache) []dependencyDelta {
+	pm, cm := toDepMap(previous), toDepMap(current)
+	for _, name := range ignored {
+		delete(pm, name)
+		delete(cm, name)
+	}
+	var delta []dependencyDelta
+	for name, c := range cm {
+		c := c
+		p, ok := pm[name]
+		if !ok {
+			delta = append(delta, dependencyDelta{Name: name, Status: "new", Current: &c})
+			continue
+		}
+		if p.Ref == c.Ref {
+			continue
+		}
+		d := dependencyDelta{Name: name, Status: "updated", Previous: &p, Current: &c}
+		pMethod, err := resolveDependency(&p, cache)
+		if err == nil {
+			if c.GitURL == "" {
+				c.GitURL = p.GitURL
+			}
+			var cMethod string
+			cMethod, err = resolveDependency(&c, cache)
+			d.Method = cMethod
+			if pMethod != cMethod {
+				d.Method = pMethod + "," + cMethod
+			}
+		}
+		if err == nil && (p.Sha == "" || c.Sha == "") {
+			err = errors.New("no commit found for version")
+		}
+		if err != nil {
+			d.Status, d.Method, d.Err = "failed", "", err
+		} else if p.Sha == c.Sha {
+			continue
+		}
+		delta = append(delta, d)
+	}
+	for name, p := range pm {
+		p := p
+		if _, ok := cm[name]; !ok {
+			delta = append(delta, dependencyDelta{Name: name, Status: "removed", Previous: &p})
+		}
+	}
+	sort.Slice(delta, func(i, j int) bool {
+		return delta[i].Name < delta[j].Name
+	})
+	return delta
+}
+
+func formatDeltaVersion(dep *dependency) string {
+	if dep == nil {
+		return "-"
+	}
+	if dep.Sha != "" && !strings.HasSuffix(dep.Ref, dep.Sha) {
+		return fmt.Sprintf("%s (%s)", dep.Ref, dep.Sha)
+	}
+	return dep.Ref
+}
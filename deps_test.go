@@ -0,0 +1,114 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetDependencyDelta(t *testing.T) {
+	previous := []dependency{
+		{Name: "example.com/same", Ref: "v1.0.0"},
+		{Name: "example.com/updated", Ref: "aaaaaaaaaaaa", Sha: "aaaaaaaaaaaa"},
+		{Name: "example.com/retagged", Ref: "bbbbbbbbbbbb", Sha: "bbbbbbbbbbbb"},
+		{Name: "example.com/removed", Ref: "v1.0.0"},
+		{Name: "example.com/ignored", Ref: "v1.0.0"},
+	}
+	current := []dependency{
+		{Name: "example.com/same", Ref: "v1.0.0"},
+		{Name: "example.com/updated", Ref: "cccccccccccc", Sha: "cccccccccccc"},
+		{Name: "example.com/retagged", Ref: "v1.1.0", Sha: "bbbbbbbbbbbb"},
+		{Name: "example.com/new", Ref: "v1.0.0"},
+		{Name: "example.com/ignored", Ref: "v2.0.0"},
+	}
+	delta := getDependencyDelta(previous, current, []string{"example.com/ignored"}, &dirCache{root: t.TempDir()})
+
+	expected := []struct {
+		name, status, method string
+	}{
+		{"example.com/new", "new", ""},
+		{"example.com/removed", "removed", ""},
+		{"example.com/updated", "updated", "version"},
+	}
+	if len(delta) != len(expected) {
+		t.Fatalf("expected %d changes, got %d: %+v", len(expected), len(delta), delta)
+	}
+	for i, e := range expected {
+		if d := delta[i]; d.Name != e.name || d.Status != e.status || d.Method != e.method || d.Err != nil {
+			t.Errorf("unexpected change %d: %+v", i, d)
+		}
+	}
+}
+
+func TestGetDependencySeries(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	for _, release := range []struct {
+		tag      string
+		requires string
+	}{
+		{"v1.0.0", "example.com/a v1.0.0\n\texample.com/b v1.0.0\n\texample.com/same v1.0.0"},
+		{"v1.0.1", "example.com/a v1.0.1\n\texample.com/b v1.0.0\n\texample.com/same v1.0.0"},
+		{"v1.0.2", "example.com/a v1.0.1\n\texample.com/c v1.0.0\n\texample.com/same v1.0.0"},
+		{"v1.0.3", "example.com/a v1.0.3\n\texample.com/c v1.1.0\n\texample.com/same v1.0.0"},
+	} {
+		gomod := "module example.com/mod\n\ngo 1.21\n\nrequire (\n\t" + release.requires + "\n)\n"
+		tr.commit("Release "+release.tag, map[string]string{"go.mod": gomod})
+		tr.git("tag", release.tag)
+	}
+
+	for _, tc := range []struct {
+		from, to string
+		tags     []string
+		series   []dependencySeries
+	}{
+		{
+			from: "v1.0.0",
+			to:   "v1.0.3",
+			tags: []string{"v1.0.0", "v1.0.1", "v1.0.2", "v1.0.3"},
+			series: []dependencySeries{
+				{Name: "example.com/a", Versions: []string{"v1.0.0", "v1.0.1", "v1.0.3"}},
+				{Name: "example.com/b", Versions: []string{"v1.0.0", "_removed_"}},
+				{Name: "example.com/c", Versions: []string{"_new_", "v1.0.0", "v1.1.0"}},
+			},
+		},
+		{
+			from: "v1.0.1",
+			to:   "v1.0.2",
+			tags: []string{"v1.0.1", "v1.0.2"},
+			series: []dependencySeries{
+				{Name: "example.com/b", Versions: []string{"v1.0.0", "_removed_"}},
+				{Name: "example.com/c", Versions: []string{"_new_", "v1.0.0"}},
+			},
+		},
+	} {
+		tags, err := seriesTags(tc.from, tc.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tags, tc.tags) {
+			t.Errorf("%s..%s: expected tags %v, got %v", tc.from, tc.to, tc.tags, tags)
+		}
+		series, err := getDependencySeries(tags, "", &dirCache{root: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(series, tc.series) {
+			t.Errorf("%s..%s: expected series %+v, got %+v", tc.from, tc.to, tc.series, series)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// dependencyUpdate is a dependency bump parsed from the title and body of a
+// Dependabot or Renovate pull request
+type dependencyUpdate struct {
+	Module string `json:"module"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+
+	ReleaseNotesLink string `json:"release_notes_link"`
+	CompareLink      string `json:"compare_link"`
+}
+
+var (
+	// Dependabot titles such as "Bump golang.org/x/net from 0.7.0 to 0.8.0",
+	// optionally with a conventional commit prefix
+	dependabotTitleRegexp = regexp.MustCompile(`(?i)^(?:[\w()-]+: )?bump (\S+) from (\S+) to (\S+)`)
+	// Renovate titles such as "Update module golang.org/x/net to v0.8.0"
+	renovateTitleRegexp = regexp.MustCompile(`(?i)^(?:[\w()-]+: )?update (?:module |dependency )?(\S+) to (\S+)`)
+
+	releaseNotesLinkRegexp = regexp.MustCompile(`\[Release notes\]\((https?://[^)\s]+)\)`)
+	releaseTagLinkRegexp   = regexp.MustCompile(`https?://[^)\s"']+/releases/tag/[^)\s"']+`)
+	compareLinkRegexp      = regexp.MustCompile(`https?://[^)\s"']+/compare/[^)\s"']+`)
+)
+
+// parseDependencyUpdate returns the dependency bumped by the pull request
+// with the upstream links from its body, or nil when the title is not that
+// of a dependency update
+func parseDependencyUpdate(title, body string) *dependencyUpdate {
+	var u dependencyUpdate
+	if matches := dependabotTitleRegexp.FindStringSubmatch(title); matches != nil {
+		u = dependencyUpdate{Module: matches[1], From: matches[2], To: matches[3]}
+	} else if matches := renovateTitleRegexp.FindStringSubmatch(title); matches != nil {
+		u = dependencyUpdate{Module: matches[1], To: matches[2]}
+	} else {
+		return nil
+	}
+	if matches := releaseNotesLinkRegexp.FindStringSubmatch(body); matches != nil {
+		u.ReleaseNotesLink = matches[1]
+	} else {
+		u.ReleaseNotesLink = releaseTagLinkRegexp.FindString(body)
+	}
+	u.CompareLink = compareLinkRegexp.FindString(body)
+	return &u
+}
+
+// linkDependencyUpdates sets the upstream links of the dependencies from the
+// changes which bumped them within the release. The release notes are those
+// of the bump to the released version, the comparison covers the whole range
+// from the previous version when the dependency was bumped several times.
+func linkDependencyUpdates(deps []dependency, changes []*change) {
+	updates := map[string][]*dependencyUpdate{}
+	for _, c := range changes {
+		if u := c.DependencyUpdate; u != nil {
+			updates[u.Module] = append(updates[u.Module], u)
+		}
+	}
+	if len(updates) == 0 {
+		return
+	}
+	for i, dep := range deps {
+		var inRange []*dependencyUpdate
+		for _, u := range updates[dep.Name] {
+			to := "v" + strings.TrimPrefix(u.To, "v")
+			if to == dep.Ref {
+				deps[i].ReleaseNotesLink = u.ReleaseNotesLink
+			}
+			if dep.Previous == "" {
+				if to == dep.Ref {
+					deps[i].CompareLink = u.CompareLink
+				}
+			} else if semver.Compare(to, dep.Previous) > 0 && semver.Compare(to, dep.Ref) <= 0 {
+				inRange = append(inRange, u)
+			}
+		}
+		for _, u := range inRange {
+			if link := compareRange(u.CompareLink, dep.Previous, dep.Ref); link != "" {
+				deps[i].CompareLink = link
+				break
+			}
+		}
+	}
+}
+
+// compareRange rewrites the comparison link to compare the given versions,
+// keeping the tag prefix of a module in a subdirectory such as "api/"
+func compareRange(link, from, to string) string {
+	i := strings.LastIndex(link, "/compare/")
+	if i < 0 {
+		return ""
+	}
+	base := link[:i+len("/compare/")]
+	var prefix string
+	if _, head, ok := strings.Cut(link[len(base):], "..."); ok {
+		if j := strings.LastIndex(head, "/"); j >= 0 {
+			prefix = head[:j+1]
+		}
+	}
+	return base + prefix + from + "..." + prefix + to
+}
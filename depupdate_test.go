@@ -0,0 +1,106 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+const dependabotBody = `Bumps [golang.org/x/net](https://github.com/golang/net) from 0.7.0 to 0.8.0.
+<details>
+<summary>Commits</summary>
+<ul>
+<li><a href="https://github.com/golang/net/commit/8e2b117"><code>8e2b117</code></a> http2/h2c: handle request bodies during h2c connection upgrading</li>
+<li>See full diff in <a href="https://github.com/golang/net/compare/v0.7.0...v0.8.0">compare view</a></li>
+</ul>
+</details>`
+
+const renovateBody = `This PR contains the following updates:
+
+| Package | Type | Update | Change |
+|---|---|---|---|
+| [github.com/opencontainers/runc](https://github.com/opencontainers/runc) | require | patch | ` + "`v1.1.4` -> `v1.1.5`" + ` |
+
+### Release Notes
+
+<details>
+<summary>opencontainers/runc</summary>
+
+### [` + "`v1.1.5`" + `](https://github.com/opencontainers/runc/releases/tag/v1.1.5)
+
+[Compare Source](https://github.com/opencontainers/runc/compare/v1.1.4...v1.1.5)
+</details>`
+
+func TestParseDependencyUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		title    string
+		body     string
+		expected *dependencyUpdate
+	}{
+		{
+			"build(deps): bump golang.org/x/net from 0.7.0 to 0.8.0", dependabotBody,
+			&dependencyUpdate{Module: "golang.org/x/net", From: "0.7.0", To: "0.8.0", CompareLink: "https://github.com/golang/net/compare/v0.7.0...v0.8.0"},
+		},
+		{
+			"Update module github.com/opencontainers/runc to v1.1.5", renovateBody,
+			&dependencyUpdate{Module: "github.com/opencontainers/runc", To: "v1.1.5", ReleaseNotesLink: "https://github.com/opencontainers/runc/releases/tag/v1.1.5", CompareLink: "https://github.com/opencontainers/runc/compare/v1.1.4...v1.1.5"},
+		},
+		{"Fix shim cleanup", "", nil},
+	} {
+		u := parseDependencyUpdate(tc.title, tc.body)
+		if (u == nil) != (tc.expected == nil) || (u != nil && *u != *tc.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tc.title, tc.expected, u)
+		}
+	}
+}
+
+func TestLinkDependencyUpdates(t *testing.T) {
+	deps := []dependency{
+		{Name: "golang.org/x/net", Previous: "v0.7.0", Ref: "v0.8.0"},
+		{Name: "golang.org/x/sys", Previous: "v0.5.0", Ref: "v0.6.0"},
+		{Name: "github.com/opencontainers/runc", Previous: "v1.1.4", Ref: "v1.1.7"},
+		{Name: "github.com/containerd/containerd/api", Previous: "v1.7.0", Ref: "v1.7.1"},
+		{Name: "github.com/containerd/log", Ref: "v0.1.0"},
+	}
+	changes := []*change{
+		{DependencyUpdate: parseDependencyUpdate("Bump golang.org/x/net from 0.7.0 to 0.8.0", dependabotBody)},
+		{DependencyUpdate: parseDependencyUpdate("Bump golang.org/x/sys from 0.4.0 to 0.5.0", "https://github.com/golang/sys/compare/v0.4.0...v0.5.0")},
+		{DependencyUpdate: parseDependencyUpdate("Update module github.com/opencontainers/runc to v1.1.5", "https://github.com/opencontainers/runc/compare/v1.1.4...v1.1.5")},
+		{DependencyUpdate: parseDependencyUpdate("Update module github.com/opencontainers/runc to v1.1.7", "[Release notes](https://github.com/opencontainers/runc/releases/tag/v1.1.7)")},
+		{DependencyUpdate: parseDependencyUpdate("Bump github.com/containerd/containerd/api from 1.7.0 to 1.7.1", "https://github.com/containerd/containerd/compare/api/v1.7.0...api/v1.7.1")},
+		{DependencyUpdate: parseDependencyUpdate("Bump github.com/containerd/log from 0.0.9 to 0.1.0", "https://github.com/containerd/log/compare/v0.0.9...v0.1.0")},
+		{},
+	}
+	linkDependencyUpdates(deps, changes)
+	for i, expected := range []struct {
+		releaseNotes, compare string
+	}{
+		{"", "https://github.com/golang/net/compare/v0.7.0...v0.8.0"},
+		// Bumped before the previous release
+		{"", ""},
+		// Bumped twice, compared over both bumps
+		{"https://github.com/opencontainers/runc/releases/tag/v1.1.7", "https://github.com/opencontainers/runc/compare/v1.1.4...v1.1.7"},
+		{"", "https://github.com/containerd/containerd/compare/api/v1.7.0...api/v1.7.1"},
+		// New dependency, link taken as is
+		{"", "https://github.com/containerd/log/compare/v0.0.9...v0.1.0"},
+	} {
+		if deps[i].ReleaseNotesLink != expected.releaseNotes {
+			t.Errorf("%s: unexpected release notes link %q", deps[i].Name, deps[i].ReleaseNotesLink)
+		}
+		if deps[i].CompareLink != expected.compare {
+			t.Errorf("%s: unexpected compare link %q", deps[i].Name, deps[i].CompareLink)
+		}
+	}
+}
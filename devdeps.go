@@ -0,0 +1,184 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Usages of dependencies which are not part of the build
+const (
+	usageTool = "tool"
+	usageTest = "test"
+)
+
+// moduleImports returns the packages imported by the tools.go files at the
+// commit, which are constrained to the "tools" build tag to track the
+// versions of tools in go.mod, and the packages imported by the other Go
+// files, leaving out tests and vendored packages
+func moduleImports(commit, subpath string) ([]string, []string, error) {
+	args := []string{"ls-tree", "-r", "--name-only", commit}
+	if subpath != "" {
+		args = append(args, "--", filepath.ToSlash(subpath))
+	}
+	out, err := git(args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	for _, name := range strings.Split(string(out), "\n") {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || strings.HasPrefix(name, "vendor/") || strings.Contains(name, "/vendor/") {
+			continue
+		}
+		names = append(names, name)
+	}
+	files, err := filesFromRev(commit, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tools, other []string
+	for _, name := range names {
+		imports, isTools, err := parseImports(name, files[name])
+		if err != nil {
+			if path.Base(name) == "tools.go" {
+				return nil, nil, err
+			}
+			logrus.WithError(err).Debugf("Ignoring imports of %s", name)
+			continue
+		}
+		if isTools && path.Base(name) == "tools.go" {
+			tools = append(tools, imports...)
+		} else {
+			other = append(other, imports...)
+		}
+	}
+	return tools, other, nil
+}
+
+// parseToolImports returns the imports of the file when it is constrained
+// to the "tools" build tag
+func parseToolImports(name string, r io.Reader) ([]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	imports, tools, err := parseImports(name, b)
+	if err != nil || !tools {
+		return nil, err
+	}
+	return imports, nil
+}
+
+// parseImports returns the imports of the file and whether it is constrained
+// to the "tools" build tag
+func parseImports(name string, b []byte) ([]string, bool, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), name, b, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, false, err
+	}
+	var tools bool
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, "//go:build") && !strings.HasPrefix(c.Text, "// +build") {
+				continue
+			}
+			for _, field := range strings.Fields(strings.NewReplacer("(", " ", ")", " ", "&&", " ", ",", " ").Replace(c.Text)) {
+				if field == "tools" {
+					tools = true
+				}
+			}
+		}
+	}
+	var imports []string
+	for _, spec := range f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, p)
+		}
+	}
+	return imports, tools, nil
+}
+
+// classifyDependencies sets the usage of the dependencies providing the tool
+// imports but none of the other imports, or matching the patterns of
+// modules only used by tools or tests
+func classifyDependencies(deps []dependency, toolImports, otherImports, toolPatterns, testPatterns []string) {
+	matches := func(name string, patterns []string) bool {
+		for _, pattern := range patterns {
+			if matchModulePattern(pattern, name) {
+				return true
+			}
+		}
+		return false
+	}
+	for i, dep := range deps {
+		switch {
+		case matches(dep.Name, toolPatterns):
+			deps[i].Usage = usageTool
+		case matches(dep.Name, testPatterns):
+			deps[i].Usage = usageTest
+		}
+	}
+	// provider returns the index of the module providing the package, which
+	// has the longest matching path
+	provider := func(imp string) int {
+		best := -1
+		for i, dep := range deps {
+			if (imp == dep.Name || strings.HasPrefix(imp, dep.Name+"/")) && (best < 0 || len(dep.Name) > len(deps[best].Name)) {
+				best = i
+			}
+		}
+		return best
+	}
+	used := map[int]struct{}{}
+	for _, imp := range otherImports {
+		if i := provider(imp); i >= 0 {
+			used[i] = struct{}{}
+		}
+	}
+	for _, imp := range toolImports {
+		i := provider(imp)
+		if _, ok := used[i]; i < 0 || ok {
+			continue
+		}
+		if deps[i].Usage == "" {
+			deps[i].Usage = usageTool
+		}
+	}
+}
+
+// withoutDevDependencies returns the dependencies used by the build,
+// removing those only used by tools or tests
+func withoutDevDependencies(deps []dependency) []dependency {
+	var build []dependency
+	for _, dep := range deps {
+		if dep.Usage == "" {
+			build = append(build, dep)
+		}
+	}
+	return build
+}
@@ -0,0 +1,116 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseToolImports(t *testing.T) {
+	tools := `//go:build tools
+// +build tools
+
+package tools
+
+import (
+	_ "github.com/cpuguy83/go-md2man/v2"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go"
+)
+`
+	imports, err := parseToolImports("tools.go", strings.NewReader(tools))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"github.com/cpuguy83/go-md2man/v2", "google.golang.org/protobuf/cmd/protoc-gen-go"}; !reflect.DeepEqual(imports, expected) {
+		t.Errorf("expected %v, got %v", expected, imports)
+	}
+
+	imports, err = parseToolImports("tools.go", strings.NewReader("package tools\n\nimport _ \"github.com/containerd/log\"\n"))
+	if err != nil || imports != nil {
+		t.Errorf("expected no imports without the tools build tag, got %v: %v", imports, err)
+	}
+}
+
+func TestClassifyDependencies(t *testing.T) {
+	deps := []dependency{
+		{Name: "github.com/containerd/log"},
+		{Name: "github.com/stretchr/testify"},
+		{Name: "google.golang.org/protobuf"},
+		{Name: "google.golang.org/protobuf/cmd"},
+		{Name: "gotest.tools/v3"},
+		{Name: "github.com/onsi/ginkgo/v2"},
+	}
+	classifyDependencies(deps, []string{"google.golang.org/protobuf/cmd/protoc-gen-go"}, nil, nil, []string{"github.com/stretchr/*", "gotest.tools/*", "github.com/onsi/..."})
+	var usages []string
+	for _, dep := range deps {
+		usages = append(usages, dep.Usage)
+	}
+	if expected := []string{"", usageTest, "", usageTool, usageTest, usageTest}; !reflect.DeepEqual(usages, expected) {
+		t.Errorf("expected usages %q, got %q", expected, usages)
+	}
+	if build := withoutDevDependencies(deps); len(build) != 2 || build[1].Name != "google.golang.org/protobuf" {
+		t.Errorf("unexpected build dependencies %v", build)
+	}
+}
+
+func TestClassifyToolAndBuildDependencies(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		other    []string
+		expected []string
+	}{
+		{"tools only", []string{"github.com/containerd/log"}, []string{"", usageTool, usageTool}},
+		{"tool imported by the build", []string{"github.com/containerd/log", "google.golang.org/protobuf/proto"}, []string{"", "", usageTool}},
+		{"tool command imported by the build", []string{"github.com/cpuguy83/go-md2man/v2/md2man"}, []string{"", usageTool, ""}},
+	} {
+		deps := []dependency{
+			{Name: "github.com/containerd/log"},
+			{Name: "google.golang.org/protobuf"},
+			{Name: "github.com/cpuguy83/go-md2man/v2"},
+		}
+		classifyDependencies(deps, []string{"google.golang.org/protobuf/cmd/protoc-gen-go", "github.com/cpuguy83/go-md2man/v2"}, tc.other, nil, nil)
+		var usages []string
+		for _, dep := range deps {
+			usages = append(usages, dep.Usage)
+		}
+		if !reflect.DeepEqual(usages, tc.expected) {
+			t.Errorf("%s: expected usages %q, got %q", tc.name, tc.expected, usages)
+		}
+	}
+}
+
+func TestModuleImports(t *testing.T) {
+	newTestRepo(t, map[string]string{
+		"tools.go":          "//go:build tools\n\npackage tools\n\nimport _ \"google.golang.org/protobuf/cmd/protoc-gen-go\"\n",
+		"main.go":           "package main\n\nimport \"google.golang.org/protobuf/proto\"\n",
+		"main_test.go":      "package main\n\nimport \"github.com/stretchr/testify/assert\"\n",
+		"vendor/log/log.go": "package log\n\nimport \"github.com/sirupsen/logrus\"\n",
+	})
+
+	tools, other, err := moduleImports("HEAD", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"google.golang.org/protobuf/cmd/protoc-gen-go"}; !reflect.DeepEqual(tools, expected) {
+		t.Errorf("expected tool imports %v, got %v", expected, tools)
+	}
+	if expected := []string{"google.golang.org/protobuf/proto"}; !reflect.DeepEqual(other, expected) {
+		t.Errorf("expected other imports %v, got %v", expected, other)
+	}
+}
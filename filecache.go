@@ -0,0 +1,191 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// cacheBackend is how objects are stored in the cache directory, either as
+// a file per object or in a single file
+var cacheBackend = "dir"
+
+// fileCacheBucket holds the objects of the single file cache, each value is
+// prefixed by the time it was stored
+var fileCacheBucket = []byte("objects")
+
+// fileCacheTimeout is how long to wait for another run holding the cache
+// file before failing
+var fileCacheTimeout = time.Minute
+
+// fileCache stores all objects in a single bbolt database, which is
+// friendlier to network filesystems than thousands of small files.
+//
+// The database is only opened for the duration of each operation so that
+// concurrent runs can share the cache, bbolt locks the file while it is
+// open, shared for reads and exclusive for writes.
+type fileCache struct {
+	name string
+}
+
+// openFileCache opens or creates the single file cache
+func openFileCache(name string) (*fileCache, error) {
+	fc := &fileCache{name: name}
+	if err := fc.update(func(b *bolt.Bucket) error { return nil }); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// view calls fn with the objects bucket in a read only transaction
+func (fc *fileCache) view(fn func(*bolt.Bucket) error) error {
+	db, err := bolt.Open(fc.name, 0644, &bolt.Options{Timeout: fileCacheTimeout, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(fileCacheBucket)
+		if b == nil {
+			return errors.New("missing objects bucket")
+		}
+		return fn(b)
+	})
+}
+
+// update calls fn with the objects bucket in a read write transaction,
+// creating the file and bucket when missing
+func (fc *fileCache) update(fn func(*bolt.Bucket) error) error {
+	db, err := bolt.Open(fc.name, 0644, &bolt.Options{Timeout: fileCacheTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(fileCacheBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+// encodeFileCacheValue prefixes the value with the time it was stored
+func encodeFileCacheValue(value []byte, modTime time.Time) []byte {
+	b := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(modTime.UnixNano()))
+	return append(b, value...)
+}
+
+// decodeFileCacheValue returns the value and the time it was stored, the
+// value is only valid for the duration of the transaction
+func decodeFileCacheValue(b []byte) ([]byte, time.Time, bool) {
+	if len(b) < 8 {
+		return nil, time.Time{}, false
+	}
+	return b[8:], time.Unix(0, int64(binary.BigEndian.Uint64(b))), true
+}
+
+func (fc *fileCache) Get(key string) ([]byte, bool) {
+	var value []byte
+	var ok bool
+	err := fc.view(func(b *bolt.Bucket) error {
+		var v []byte
+		v, _, ok = decodeFileCacheValue(b.Get([]byte(key)))
+		value = append([]byte(nil), v...)
+		return nil
+	})
+	if err != nil {
+		return nil, false
+	}
+	return value, ok
+}
+
+func (fc *fileCache) Put(key string, value []byte) error {
+	return fc.update(func(b *bolt.Bucket) error {
+		return b.Put([]byte(key), encodeFileCacheValue(value, time.Now()))
+	})
+}
+
+// ModTime returns when the value for the key was last stored
+func (fc *fileCache) ModTime(key string) (time.Time, bool) {
+	var modTime time.Time
+	var ok bool
+	err := fc.view(func(b *bolt.Bucket) error {
+		_, modTime, ok = decodeFileCacheValue(b.Get([]byte(key)))
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, false
+	}
+	return modTime, ok
+}
+
+// entries returns all objects in the cache sorted by namespace and key
+func (fc *fileCache) entries() ([]cacheEntry, error) {
+	var entries []cacheEntry
+	err := fc.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			value, modTime, ok := decodeFileCacheValue(v)
+			if !ok {
+				return nil
+			}
+			entries = append(entries, cacheEntry{
+				Key:       string(k),
+				Namespace: cacheNamespace(string(k)),
+				Path:      fc.name,
+				Size:      int64(len(value)),
+				ModTime:   modTime,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Namespace == entries[j].Namespace {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Namespace < entries[j].Namespace
+	})
+	return entries, nil
+}
+
+// remove deletes the given entries from the file
+func (fc *fileCache) remove(entries ...cacheEntry) error {
+	return fc.update(func(b *bolt.Bucket) error {
+		for _, e := range entries {
+			if err := b.Delete([]byte(e.Key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// cacheStore is a cache which can list and remove its entries
+type cacheStore interface {
+	Cache
+	entries() ([]cacheEntry, error)
+	remove(...cacheEntry) error
+}
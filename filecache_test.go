@@ -0,0 +1,129 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestFileCache(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "object.db")
+	fc, err := openFileCache(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := "https://api.github.com/repos/containerd/containerd/pulls/1 title labels"
+	goget := "https://github.com/containerd/log?go-get=1"
+	for _, kv := range [][2]string{{pr, "old"}, {goget, "github.com/containerd/log"}, {pr, "new"}} {
+		if err := fc.Put(kv[0], []byte(kv[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b, ok := fc.Get(pr); !ok || string(b) != "new" {
+		t.Fatalf("unexpected value %q", b)
+	}
+	if _, ok := fc.Get("missing"); ok {
+		t.Error("expected missing key to be absent")
+	}
+
+	fc, err = openFileCache(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := fc.Get(pr); !ok || string(b) != "new" {
+		t.Fatalf("unexpected reopened value %q", b)
+	}
+	if modTime, ok := fc.ModTime(pr); !ok || time.Since(modTime) > time.Minute {
+		t.Errorf("unexpected modification time %v", modTime)
+	}
+
+	entries, err := fc.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Namespace != "github/pr" || entries[1].Namespace != "goget" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if entries[0].Size != 3 {
+		t.Errorf("unexpected size %d", entries[0].Size)
+	}
+	if err := fc.remove(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fc.Get(pr); ok {
+		t.Error("expected removed entry to be gone")
+	}
+	if b, ok := fc.Get(goget); !ok || string(b) != "github.com/containerd/log" {
+		t.Errorf("unexpected value after remove %q", b)
+	}
+	if err := fc.Put(pr, []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := fc.Get(pr); !ok || string(b) != "again" {
+		t.Errorf("unexpected value after put %q", b)
+	}
+}
+
+func TestFileCacheShared(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "object.db")
+	a, err := openFileCache(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := openFileCache(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, put := range []struct {
+		fc  *fileCache
+		key string
+	}{{a, "a1"}, {b, "b1"}, {a, "a2"}} {
+		if err := put.fc.Put(put.key, []byte(put.key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.remove(cacheEntry{Key: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Put("a3", []byte("a3")); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a1", "a2", "a3"} {
+		if v, ok := b.Get(key); !ok || string(v) != key {
+			t.Errorf("unexpected value %q for %s", v, key)
+		}
+	}
+	if _, ok := a.Get("b1"); ok {
+		t.Error("expected removed entry to be gone")
+	}
+
+	// Writes wait for another run holding the file
+	defer func(timeout time.Duration) { fileCacheTimeout = timeout }(fileCacheTimeout)
+	fileCacheTimeout = 50 * time.Millisecond
+	db, err := bolt.Open(name, 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := a.Put("a4", []byte("a4")); err == nil {
+		t.Error("expected write to time out while the file is held")
+	}
+}
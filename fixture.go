@@ -0,0 +1,106 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/urfave/cli/v2"
+)
+
+var renderFixtureCommand = &cli.Command{
+	Name:      "render-fixture",
+	Usage:     "render the template from release data in a JSON or TOML fixture",
+	ArgsUsage: "<fixture>",
+	Description: `Renders the release notes template using release data read from a
+fixture file instead of git and the network. Use this to test custom
+templates by comparing against a golden file.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "template filepath to use in place of the default",
+			Value: defaultTemplateFile,
+		},
+		&cli.StringFlag{
+			Name:  "golden",
+			Usage: "golden file to compare the rendered output against",
+		},
+		&cli.BoolFlag{
+			Name:  "update",
+			Usage: "update the golden file with the rendered output",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the fixture file as the first argument")
+		}
+		r, err := loadFixture(context.Args().First())
+		if err != nil {
+			return err
+		}
+		tmpl, err := getTemplate(context)
+		if err != nil {
+			return err
+		}
+
+		golden := context.String("golden")
+		if golden == "" {
+			return renderTemplate(os.Stdout, tmpl, r)
+		}
+
+		var b bytes.Buffer
+		if err := renderTemplate(&b, tmpl, r); err != nil {
+			return err
+		}
+		if context.Bool("update") {
+			return os.WriteFile(golden, b.Bytes(), 0644)
+		}
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			return fmt.Errorf("unable to read golden file: %w", err)
+		}
+		if !bytes.Equal(expected, b.Bytes()) {
+			return fmt.Errorf("rendered output does not match golden file %s", golden)
+		}
+		return nil
+	},
+}
+
+// loadFixture reads release data from a JSON or TOML file, the format is
+// determined by the file extension with TOML as the default
+func loadFixture(path string) (*release, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r release
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(b, &r)
+	} else {
+		err = toml.Unmarshal(b, &r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse fixture %s: %w", path, err)
+	}
+	return &r, nil
+}
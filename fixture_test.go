@@ -0,0 +1,121 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestLoadFixtureJSON(t *testing.T) {
+	r := &release{
+		ProjectName: "containerd",
+		GithubRepo:  "containerd/containerd",
+		Tag:         "v1.7.1",
+		Previous:    "v1.7.0",
+		CommitSha:   "0123456789abcdef0123456789abcdef01234567",
+		Changes: []projectChange{{
+			Name: "containerd",
+			Changes: []*change{{
+				Commit:      "0123456789ab",
+				Title:       "Fix shim cleanup",
+				PullRequest: 42,
+				IsMerge:     true,
+				Formatted:   "Fix shim cleanup ([#42](https://github.com/containerd/containerd/pull/42))",
+			}},
+		}},
+		Contributors: []contributor{{Name: "Jane Doe", Email: "jane@example.com", Commits: 1}},
+		Dependencies: []dependency{{Name: "golang.org/x/net", Previous: "v0.7.0", Ref: "v0.8.0", GitURL: "https://go.googlesource.com/net"}},
+		Provenance:   &provenance{ToolVersion: "v0.3.0", GeneratedAt: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)},
+		CommitDate:   time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The field names are part of the output format used by automation
+	for _, expected := range []string{`"project_name":"containerd"`, `"commit_sha":"0123456789abcdef`, `"pull_request":42`, `"is_merge":true`, `"git_url":"https://go.googlesource.com/net"`, `"tool_version":"v0.3.0"`} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected %s in %s", expected, b)
+		}
+	}
+
+	name := filepath.Join(t.TempDir(), "release.json")
+	if err := os.WriteFile(name, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadFixture(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, r) {
+		t.Errorf("expected %+v, got %+v", r, loaded)
+	}
+}
+
+func TestRenderFixtureCommand(t *testing.T) {
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "release.toml")
+	tmpl := filepath.Join(dir, "notes.tmpl")
+	golden := filepath.Join(dir, "notes.golden")
+	for name, content := range map[string]string{
+		fixture: "project_name = \"containerd\"\ntag = \"v1.7.1\"\nprevious = \"v1.7.0\"\n",
+		tmpl:    "{{.ProjectName}} {{.Tag}} (since {{.Previous}})\n",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		golden string
+		err    string
+	}{
+		{"missing golden", nil, "", "unable to read golden file: open " + golden + ": no such file or directory"},
+		{"update", []string{"--update"}, "containerd v1.7.1 (since v1.7.0)\n", ""},
+		{"match", nil, "containerd v1.7.1 (since v1.7.0)\n", ""},
+		{"mismatch", nil, "containerd v1.7.0\n", "rendered output does not match golden file " + golden},
+	} {
+		if tc.golden != "" && tc.args == nil {
+			if err := os.WriteFile(golden, []byte(tc.golden), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		app := &cli.App{Commands: []*cli.Command{renderFixtureCommand}}
+		args := append([]string{"release-tool", "render-fixture", "--template", tmpl, "--golden", golden}, tc.args...)
+		err := app.Run(append(args, fixture))
+		if tc.err == "" && err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Fatalf("%s: expected error %q, got %v", tc.name, tc.err, err)
+		}
+		if tc.golden != "" {
+			if b, err := os.ReadFile(golden); err != nil || string(b) != tc.golden {
+				t.Errorf("%s: expected golden file %q, got %q (%v)", tc.name, tc.golden, b, err)
+			}
+		}
+	}
+}
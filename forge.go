@@ -0,0 +1,84 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// getForgeJSON sends the request to the API of a forge, such as GitLab or
+// Gitea, and decodes the JSON response. Unauthorized responses are reported
+// as warnings of the kind naming the token environment variable.
+func getForgeJSON(req *http.Request, kind, tokenEnv string, v interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	u := req.URL.String()
+	if resp.StatusCode >= 400 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			warnings.warn(kind, logrus.Fields{"url": u}, fmt.Sprintf("Unauthorized response, try setting the %s environment variable", tokenEnv))
+		}
+		return fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, u)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// getCachedJSON decodes the object cached at the key into v, or otherwise
+// gets it and caches it
+func getCachedJSON(key string, cache Cache, v interface{}, get func(interface{}) error) error {
+	if b, ok := cache.Get(key); ok && json.Unmarshal(b, v) == nil {
+		return nil
+	}
+	if err := get(v); err != nil {
+		return err
+	}
+	if b, err := json.Marshal(v); err == nil {
+		cache.Put(key, b)
+	}
+	return nil
+}
+
+// pullChange sets the change from the pull or merge request merging it, the
+// number is referenced with the separator such as "#" or "!"
+func pullChange(c *change, repo, linkName, sep string, number int64, title, body string, labels []pullRequestLabel, link string) {
+	applyLabels(c, labels)
+	c.PullRequest = number
+	c.Title = title
+	applyReleaseNote(c, body)
+
+	if c.Link == "" {
+		c.Link = link
+	}
+	c.Formatted = fmt.Sprintf("%s (%s)", c.Title, formatReference(repo, linkName, sep, strconv.FormatInt(number, 10), c.Link))
+}
+
+// commitChange sets the change to the commit description linked to the
+// commit
+func commitChange(c *change, repo, linkName, link string) {
+	c.Title = c.Description
+	c.Link = link
+	c.Formatted = fmt.Sprintf("%s %s", formatReference(repo, linkName, "@", c.Commit, c.Link), c.Description)
+}
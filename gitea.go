@@ -0,0 +1,142 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// giteaHosts are the hosts serving Gitea or Forgejo, codeberg.org and the
+// hosts configured for the release
+var giteaHosts = map[string]struct{}{
+	"codeberg.org": {},
+}
+
+var gprr = regexp.MustCompile(`^Merge pull request '.*' \(#([0-9]+)\) from \S+ into \S+$`)
+
+// giteaRepo splits a repository prefixed with a Gitea host into the host
+// and the owner and name of the repository
+func giteaRepo(repo string) (string, string, bool) {
+	parts := strings.SplitN(repo, "/", 4)
+	if len(parts) < 3 {
+		return "", "", false
+	}
+	if _, ok := giteaHosts[parts[0]]; !ok {
+		return "", "", false
+	}
+	return parts[0], parts[1] + "/" + parts[2], true
+}
+
+type giteaChangeProcessor struct {
+	host     string
+	repo     string
+	linkName string
+	cache    Cache
+}
+
+func giteaChange(host, repo, linkName string, cache Cache) changeProcessor {
+	return &giteaChangeProcessor{
+		host:     host,
+		repo:     repo,
+		linkName: linkName,
+		cache:    cache,
+	}
+}
+
+func (p *giteaChangeProcessor) process(c *change) error {
+	if matches := gprr.FindStringSubmatch(c.Description); matches != nil {
+		pr, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		info, err := p.getPRInfo(p.repo, pr)
+		if err != nil {
+			return err
+		}
+		p.prChange(c, info, pr)
+		c.IsMerge = true
+	} else if strings.HasPrefix(c.Description, "Merge") {
+		logrus.Debugf("Not matched: %q", c.Description)
+	}
+
+	if c.Formatted == "" {
+		full, err := git("rev-parse", c.Commit)
+		if err != nil {
+			return err
+		}
+		commit := strings.TrimSpace(string(full))
+
+		commitChange(c, p.repo, p.linkName, fmt.Sprintf("https://%s/%s/commit/%s", p.host, p.repo, commit))
+	}
+	return nil
+}
+
+func (p *giteaChangeProcessor) prChange(c *change, info giteaPullRequestInfo, pr int64) {
+	link := info.HTMLURL
+	if link == "" {
+		link = fmt.Sprintf("https://%s/%s/pulls/%d", p.host, p.repo, pr)
+	}
+	pullChange(c, p.repo, p.linkName, "#", pr, info.Title, info.Body, info.Labels, link)
+}
+
+type giteaPullRequestInfo struct {
+	Title   string             `json:"title"`
+	Body    string             `json:"body"`
+	Labels  []pullRequestLabel `json:"labels"`
+	HTMLURL string             `json:"html_url"`
+}
+
+func (p *giteaChangeProcessor) getPRInfo(repo string, pr int64) (giteaPullRequestInfo, error) {
+	u := fmt.Sprintf("https://%s/api/v1/repos/%s/pulls/%d", p.host, repo, pr)
+	key := u + " title body labels html_url"
+	var info giteaPullRequestInfo
+	err := getCachedJSON(key, p.cache, &info, func(v interface{}) error {
+		if err := getGiteaJSON(u, v); err != nil {
+			return err
+		}
+		if v.(*giteaPullRequestInfo).Title == "" {
+			return fmt.Errorf("unexpected empty title for %s", u)
+		}
+		return nil
+	})
+	if err != nil {
+		return giteaPullRequestInfo{}, err
+	}
+	return info, nil
+}
+
+// getGiteaJSON requests the Gitea API url and decodes the JSON response,
+// authenticating with GITEA_TOKEN when set
+func getGiteaJSON(u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token := os.Getenv("GITEA_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	return getForgeJSON(req, warningGitea, "GITEA_TOKEN", v)
+}
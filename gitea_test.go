@@ -0,0 +1,85 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestGiteaRepo(t *testing.T) {
+	for _, tc := range []struct {
+		repo string
+		host string
+		name string
+		ok   bool
+	}{
+		{"codeberg.org/forgejo/forgejo", "codeberg.org", "forgejo/forgejo", true},
+		{"codeberg.org/owner/project/v2", "codeberg.org", "owner/project", true},
+		{"codeberg.org/owner", "", "", false},
+		{"github.com/containerd/containerd", "", "", false},
+	} {
+		host, name, ok := giteaRepo(tc.repo)
+		if host != tc.host || name != tc.name || ok != tc.ok {
+			t.Errorf("%s: unexpected %q %q %t", tc.repo, host, name, ok)
+		}
+	}
+}
+
+func TestGiteaPullRequestChange(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/owner/project/pulls/12" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(giteaPullRequestInfo{
+			Title:   "Fix mount options",
+			Labels:  []pullRequestLabel{{Name: "impact/breaking"}},
+			HTMLURL: "https://codeberg.org/owner/project/pulls/12",
+		})
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	p, ok := changeProcessorFor("codeberg.org/owner/project", "codeberg.org/owner/project", &dirCache{root: t.TempDir()}).(*giteaChangeProcessor)
+	if !ok {
+		t.Fatal("expected a Gitea change processor")
+	}
+	if !gprr.MatchString("Merge pull request 'Fix mount options' (#12) from user/fix-mount into main") {
+		t.Error("expected Gitea merge commit to match")
+	}
+	info, err := p.getPRInfo(p.repo, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &change{}
+	p.prChange(c, info, 12)
+
+	if !c.IsBreaking || c.PullRequest != 12 {
+		t.Errorf("unexpected change %+v", c)
+	}
+	if expected := "Fix mount options ([owner/project#12](https://codeberg.org/owner/project/pulls/12))"; c.Formatted != expected {
+		t.Errorf("expected %q, got %q", expected, c.Formatted)
+	}
+}
@@ -17,33 +17,125 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
-var prr = regexp.MustCompile(`^Merge pull request(?: #([0-9]+))? from (\S+)$`)
+var (
+	prr     = regexp.MustCompile(`^Merge pull request(?: #([0-9]+))? from (\S+)$`)
+	squashr = regexp.MustCompile(`^.+ \(#([0-9]+)\)$`)
+)
+
+// linkStyles are the styles of the links to pull requests and commits:
+// "markdown" links the reference, "url" writes the full url, "reference"
+// writes references qualified with the repository such as org/repo#123 and
+// "short" writes #123 and commit shas, qualifying references to other
+// repositories. GitHub autolinks references in release bodies.
+var linkStyles = []string{"markdown", "url", "reference", "short"}
+
+// linkStyle is the style of the links in the formatted changes
+var linkStyle = "markdown"
+
+// formatReference returns the link to the pull or merge request, commit or
+// advisory in the link style. The link name is the repository shown for
+// changes from other repositories and the separator joins the repository
+// and reference, such as "#" or "@".
+func formatReference(repo, linkName, sep, ref, link string) string {
+	switch linkStyle {
+	case "url":
+		return link
+	case "reference":
+		return repo + sep + ref
+	case "short":
+		if linkName == "" {
+			return strings.TrimPrefix(sep, "@") + ref
+		}
+		return linkName + sep + ref
+	}
+	if sep == "@" {
+		return fmt.Sprintf("[`%s`](%s)", ref, link)
+	}
+	return fmt.Sprintf("[%s%s%s](%s)", linkName, sep, ref, link)
+}
 
 type githubChangeProcessor struct {
-	repo         string
-	linkName     string
-	cache        Cache
-	refreshCache bool
+	repo     string
+	linkName string
+	cache    Cache
+
+	// associated are the pull requests already listed from a merge
+	// commit, squash merge or associated commit, later commits of the same
+	// pull request are listed below it
+	associated map[int64]struct{}
+
+	// squash is set when subjects ending in a number, such as "(#123)",
+	// are squash merged pull requests rather than issue references
+	squash bool
 }
 
-func githubChange(repo, linkName string, cache Cache, refreshCache bool) changeProcessor {
+func githubChange(repo, linkName string, cache Cache) changeProcessor {
 	return &githubChangeProcessor{
-		repo:         repo,
-		linkName:     linkName,
-		cache:        cache,
-		refreshCache: refreshCache,
+		repo:     repo,
+		linkName: linkName,
+		cache:    cache,
+		squash:   githubSquashPulls != "never",
+	}
+}
+
+// githubSquashPulls is whether commit subjects ending in a number are
+// squash merged pull requests: "always", "never" or "auto" to only treat
+// them as pull requests when no pull request was merged with a merge commit
+var githubSquashPulls = "auto"
+
+// squashMerged returns whether the changes are squash merged pull requests
+// in the githubSquashPulls mode
+func squashMerged(changes []*change) bool {
+	switch githubSquashPulls {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	for _, c := range changes {
+		if matches := prr.FindStringSubmatch(c.Description); len(matches) == 3 && matches[1] != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// githubModuleRepo returns the owner and name of the GitHub repository of a
+// module path such as github.com/containerd/containerd/api
+func githubModuleRepo(name string) (string, bool) {
+	parts := strings.SplitN(name, "/", 4)
+	if len(parts) < 3 || parts[0] != "github.com" {
+		return "", false
+	}
+	return parts[1] + "/" + parts[2], true
+}
+
+// markAssociated records the pull request as listed, returning false when
+// it was already listed
+func (p *githubChangeProcessor) markAssociated(pr int64) bool {
+	if _, ok := p.associated[pr]; ok {
+		return false
+	}
+	if p.associated == nil {
+		p.associated = map[int64]struct{}{}
 	}
+	p.associated[pr] = struct{}{}
+	return true
 }
 
 func (p *githubChangeProcessor) process(c *change) error {
@@ -59,6 +151,7 @@ func (p *githubChangeProcessor) process(c *change) error {
 				return err
 			}
 			p.prChange(c, info, pr)
+			p.markAssociated(pr)
 
 		} else if strings.HasPrefix(string(matches[2]), "GHSA-") {
 			ghsa := string(matches[2])
@@ -72,6 +165,24 @@ func (p *githubChangeProcessor) process(c *change) error {
 			logrus.Debugf("Nothing matched: %q", c.Description)
 		}
 		c.IsMerge = true
+	} else if matches := squashr.FindStringSubmatch(c.Description); matches != nil && p.squash {
+		// Squash merged pull requests have the number appended to the
+		// subject, the number may also refer to an issue or to a pull
+		// request already listed from its merge commit
+		pr, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		if _, ok := p.associated[pr]; !ok {
+			info, err := p.getPRInfo(p.repo, pr)
+			if err != nil {
+				warnings.warn(warningGithub, logrus.Fields{"commit": c.Commit, "pr": pr, "error": err}, "Unable to get squash merged pull request")
+			} else {
+				p.prChange(c, info, pr)
+				p.markAssociated(pr)
+				c.IsMerge = true
+			}
+		}
 	} else if strings.HasPrefix(c.Description, "Merge") {
 		logrus.WithField("matches", matches).Debugf("Not matched: %q", c.Description)
 	}
@@ -83,41 +194,167 @@ func (p *githubChangeProcessor) process(c *change) error {
 		}
 		commit := strings.TrimSpace(string(full))
 
-		c.Title = c.Description
-		c.Link = fmt.Sprintf("https://github.com/%s/commit/%s", p.repo, commit)
-		c.Formatted = fmt.Sprintf("[`%s`](%s) %s", c.Commit, c.Link, c.Description)
+		if githubCommitPulls {
+			if err := p.associatePR(c, commit); err != nil {
+				return err
+			}
+			if c.Formatted != "" {
+				return nil
+			}
+		}
+
+		commitChange(c, p.repo, p.linkName, fmt.Sprintf("https://github.com/%s/commit/%s", p.repo, commit))
 	}
 	return nil
 }
 
 func (p *githubChangeProcessor) prChange(c *change, info pullRequestInfo, pr int64) {
-	for _, l := range info.Labels {
+	title := info.Title
+	if len(title) > 0 && title[0] == '[' {
+		idx := strings.IndexByte(title, ']')
+		if idx > 0 {
+			title = strings.TrimSpace(title[idx+1:])
+		}
+	}
+	pullChange(c, p.repo, p.linkName, "#", pr, title, info.Body, info.Labels, fmt.Sprintf("https://github.com/%s/pull/%d", p.repo, pr))
+	c.DependencyUpdate = parseDependencyUpdate(info.Title, info.Body)
+
+	if ref, link := p.backportOf(info.Title, info.Body); ref != "" {
+		c.Backport = ref
+		c.BackportLink = link
+		switch linkStyle {
+		case "markdown":
+			c.Formatted = fmt.Sprintf("%s (backport of [%s](%s))", c.Formatted, ref, link)
+		case "url":
+			c.Formatted = fmt.Sprintf("%s (backport of %s)", c.Formatted, link)
+		default:
+			c.Formatted = fmt.Sprintf("%s (backport of %s)", c.Formatted, ref)
+		}
+	}
+}
+
+// applyLabels sets the highlight, breaking, deprecation, audience and
+// category of the change from the labels of its pull or merge request
+func applyLabels(c *change, labels []pullRequestLabel) {
+	for _, l := range labels {
+		c.Labels = append(c.Labels, l.Name)
 		if l.Name == "impact/changelog" {
 			c.IsHighlight = true
 		} else if l.Name == "impact/breaking" {
 			c.IsBreaking = true
 		} else if l.Name == "impact/deprecation" {
 			c.IsDeprecation = true
+		} else if l.Name == "release-note-action-required" {
+			c.IsActionRequired = true
+		} else if strings.HasPrefix(l.Name, "audience/") {
+			c.Audiences = append(c.Audiences, l.Name[9:])
 		} else if strings.HasPrefix(l.Name, "area/") {
 			if l.Description != "" {
 				c.Category = l.Description
 			} else {
 				c.Category = l.Name[5:]
 			}
+			c.CategoryList = append(c.CategoryList, c.Category)
+			c.Areas = append(c.Areas, l.Name[5:])
 		}
 	}
-	c.Title = info.Title
-	if len(c.Title) > 0 && c.Title[0] == '[' {
-		idx := strings.IndexByte(c.Title, ']')
-		if idx > 0 {
-			c.Title = strings.TrimSpace(c.Title[idx+1:])
+	sort.Strings(c.CategoryList)
+	sort.Strings(c.Areas)
+}
+
+var (
+	backportRegexp      = regexp.MustCompile(`(?i)\b(?:backport|cherry[- ]pick)(?:ed)?(?:\s+(?:of|from))?(?:\s+pr)?\s+([\w.-]+/[\w.-]+)?#([0-9]+)`)
+	backportTitleRegexp = regexp.MustCompile(`^\[[^\]]+\]\s.*\(()#([0-9]+)\)$`)
+	cherryPickRegexp    = regexp.MustCompile(`(?i)\(cherry picked from commit ([0-9a-f]{7,40})\)|^cherry-picked-from:\s*([0-9a-f]{7,40})\s*$`)
+)
+
+// backportOf returns the reference and link of the original pull request or
+// commit referenced by a backport pull request body, or by its title when
+// prefixed with the release branch such as "[release/1.7] Fix (#123)"
+func (p *githubChangeProcessor) backportOf(title, body string) (string, string) {
+	matches := backportRegexp.FindStringSubmatch(body)
+	if matches == nil {
+		matches = backportTitleRegexp.FindStringSubmatch(title)
+	}
+	if matches != nil {
+		repo := matches[1]
+		if repo == "" {
+			repo = p.repo
 		}
+		return matches[1] + "#" + matches[2], fmt.Sprintf("https://github.com/%s/pull/%s", repo, matches[2])
 	}
+	for _, line := range strings.Split(body, "\n") {
+		matches := cherryPickRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		commit := matches[1] + matches[2]
+		short := commit
+		if len(short) > 12 {
+			short = short[:12]
+		}
+		return short, fmt.Sprintf("https://github.com/%s/commit/%s", p.repo, commit)
+	}
+	return "", ""
+}
 
-	if c.Link == "" {
-		c.Link = fmt.Sprintf("https://github.com/%s/pull/%d", p.repo, pr)
+var releaseNoteRegexp = regexp.MustCompile("(?s)```release-note\\r?\\n(.*?)```")
+
+// getReleaseNote returns the text of the release-note block in a pull
+// request body
+func getReleaseNote(body string) string {
+	matches := releaseNoteRegexp.FindStringSubmatch(body)
+	if matches == nil {
+		return ""
 	}
-	c.Formatted = fmt.Sprintf("%s ([%s#%d](%s))", c.Title, p.linkName, pr, c.Link)
+	return strings.TrimSpace(matches[1])
+}
+
+var (
+	releaseNoteNoneRegexp = regexp.MustCompile(`(?im)^release-note:\s*NONE\s*$`)
+	actionRequiredRegexp  = regexp.MustCompile(`(?i)^action required:?\s*`)
+)
+
+// applyReleaseNote sets the release note and upgrade note of the change from
+// the pull request body. Following the Kubernetes conventions, a release
+// note of NONE removes the change from the highlights and a note starting
+// with "ACTION REQUIRED" marks the change as requiring action on upgrade.
+func applyReleaseNote(c *change, body string) {
+	note := getReleaseNote(body)
+	if strings.EqualFold(note, "NONE") || (note == "" && releaseNoteNoneRegexp.MatchString(body)) {
+		c.IsHighlight = false
+		note = ""
+	}
+	if loc := actionRequiredRegexp.FindStringIndex(note); loc != nil {
+		c.IsActionRequired = true
+		note = note[loc[1]:]
+	}
+	if note != "" {
+		c.ReleaseNote = note
+		// The note replaces the title only for changes listed in the
+		// highlights, the changes list keeps the pull request titles
+		if c.IsHighlight || c.IsBreaking || c.IsDeprecation || c.IsActionRequired {
+			c.Title = releaseNoteTitle(note)
+		}
+	}
+	c.UpgradeNote = getUpgradeNote(body)
+}
+
+// releaseNoteTitle returns the release note as a single line title
+func releaseNoteTitle(note string) string {
+	return strings.Join(strings.Fields(note), " ")
+}
+
+var upgradeNoteRegexp = regexp.MustCompile("(?s)```upgrade-note\\r?\\n(.*?)```")
+
+// getUpgradeNote returns the text of the upgrade-note block in a pull
+// request body
+func getUpgradeNote(body string) string {
+	matches := upgradeNoteRegexp.FindStringSubmatch(body)
+	if matches == nil {
+		return ""
+	}
+	return strings.TrimSpace(matches[1])
 }
 
 type pullRequestLabel struct {
@@ -126,60 +363,307 @@ type pullRequestLabel struct {
 }
 
 type pullRequestInfo struct {
-	Title  string             `json:"title"`
-	Labels []pullRequestLabel `json:"labels"`
+	Title     string             `json:"title"`
+	Body      string             `json:"body"`
+	Labels    []pullRequestLabel `json:"labels"`
+	UpdatedAt time.Time          `json:"updated_at"`
 }
 
-// getPRInfo returns the Pull Request info from the github API
+// githubPageSize is the number of items requested for each page from list
+// endpoints of the GitHub API, at most 100
+var githubPageSize = 100
+
+// inlineLabelLimit is the number of labels returned with a pull request
+// at which the labels may have been truncated
+const inlineLabelLimit = 30
+
+// getLabels returns all labels of the issue or pull request
 //
-// See https://docs.github.com/en/rest/pulls/pulls?apiVersion=2022-11-28#get-a-pull-request
-func (p *githubChangeProcessor) getPRInfo(repo string, prn int64) (pullRequestInfo, error) {
+// See https://docs.github.com/en/rest/issues/labels?apiVersion=2022-11-28#list-labels-for-an-issue
+func getLabels(repo string, number int64) ([]pullRequestLabel, error) {
+	var labels []pullRequestLabel
+	for page := 1; ; page++ {
+		u := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d/labels?per_page=%d&page=%d", repo, number, githubPageSize, page)
+		var pageLabels []pullRequestLabel
+		if err := getGithubJSON(u, &pageLabels); err != nil {
+			return nil, err
+		}
+		labels = append(labels, pageLabels...)
+		if len(pageLabels) < githubPageSize {
+			return labels, nil
+		}
+	}
+}
+
+// prInfoKey returns the API url of the pull request and the cache key of
+// its info
+func prInfoKey(repo string, prn int64) (string, string) {
 	u := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d", repo, prn)
-	key := u + " title labels"
-	if !p.refreshCache {
-		if b, ok := p.cache.Get(key); ok {
-			var info pullRequestInfo
-			if err := json.Unmarshal(b, &info); err == nil {
-				return info, nil
+	return u, u + " title body labels"
+}
+
+// githubCommitPulls associates commits without a pull request number in
+// their message with the pull request which merged them
+var githubCommitPulls = false
+
+// associatePR treats the commit as a change of the pull request which merged
+// it, such as from a rebase merge, when it is the first commit seen from the
+// pull request
+//
+// See https://docs.github.com/en/rest/commits/commits?apiVersion=2022-11-28#list-pull-requests-associated-with-a-commit
+func (p *githubChangeProcessor) associatePR(c *change, commit string) error {
+	pr, err := p.getCommitPR(commit)
+	if err != nil || pr == 0 {
+		return err
+	}
+	if !p.markAssociated(pr) {
+		return nil
+	}
+
+	info, err := p.getPRInfo(p.repo, pr)
+	if err != nil {
+		return err
+	}
+	p.prChange(c, info, pr)
+	c.IsMerge = true
+	return nil
+}
+
+// getCommitPR returns the number of the merged pull request associated with
+// the commit or 0 when the commit was not merged from a pull request
+func (p *githubChangeProcessor) getCommitPR(commit string) (int64, error) {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/commits/%s/pulls", p.repo, commit)
+	key := u + " number"
+	if b, ok := p.cache.Get(key); ok {
+		if pr, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			return pr, nil
+		}
+	}
+	var pulls []struct {
+		Number   int64      `json:"number"`
+		MergedAt *time.Time `json:"merged_at"`
+	}
+	if err := getGithubJSON(u, &pulls); err != nil {
+		return 0, err
+	}
+	var pr int64
+	for _, pull := range pulls {
+		if pull.MergedAt != nil {
+			pr = pull.Number
+			break
+		}
+	}
+	p.cache.Put(key, []byte(strconv.FormatInt(pr, 10)))
+	return pr, nil
+}
+
+// githubConcurrency is the number of pull requests, advisories and commit
+// pull requests fetched at a time ahead of processing the changes
+var githubConcurrency = 1
+
+// fetchConcurrently fetches the pull requests, advisories and commit pull
+// requests of the changes using up to concurrency requests at a time, storing
+// them in the cache of the processor. The changes are processed in order
+// afterwards, failed requests are left for the processor to report.
+func (p *githubChangeProcessor) fetchConcurrently(changes []*change, concurrency int) {
+	var (
+		wg   sync.WaitGroup
+		work = make(chan *change)
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range work {
+				if err := p.fetch(c); err != nil {
+					logrus.WithError(err).WithField("commit", c.Commit).Debug("unable to fetch ahead of processing")
+				}
 			}
+		}()
+	}
+	for _, c := range changes {
+		work <- c
+	}
+	close(work)
+	wg.Wait()
+}
+
+// fetch gets the info the processor needs for the change
+func (p *githubChangeProcessor) fetch(c *change) error {
+	if matches := prr.FindStringSubmatch(c.Description); len(matches) == 3 {
+		if matches[1] != "" {
+			pr, err := strconv.ParseInt(matches[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			_, err = p.getPRInfo(p.repo, pr)
+			return err
+		} else if strings.HasPrefix(matches[2], "GHSA-") {
+			_, err := p.getAdvisoryInfo(p.repo, matches[2])
+			return err
+		}
+		return nil
+	} else if matches := squashr.FindStringSubmatch(c.Description); matches != nil && p.squash {
+		pr, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return err
 		}
+		_, err = p.getPRInfo(p.repo, pr)
+		return err
 	}
-	req, err := http.NewRequest("GET", u, nil)
+	if !githubCommitPulls {
+		return nil
+	}
+	full, err := git("rev-parse", c.Commit)
 	if err != nil {
-		return pullRequestInfo{}, err
+		return err
 	}
-	req.Header.Add("Accept", "application/vnd.github+json")
-	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
-	if user, token := os.Getenv("GITHUB_ACTOR"), os.Getenv("GITHUB_TOKEN"); user != "" && token != "" {
-		req.SetBasicAuth(user, token)
+	pr, err := p.getCommitPR(strings.TrimSpace(string(full)))
+	if err != nil || pr == 0 {
+		return err
 	}
+	_, err = p.getPRInfo(p.repo, pr)
+	return err
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return pullRequestInfo{}, err
+// githubBatchSize is the number of pull requests fetched by each GraphQL
+// query when batching lookups, 0 disables batching
+var githubBatchSize = 0
+
+// prefetchPRInfo fetches the info of the merged pull requests of the
+// changes which are not cached using batched GraphQL queries, storing them
+// in the cache for the change processor
+//
+// See https://docs.github.com/en/graphql/reference/objects#pullrequest
+func prefetchPRInfo(repo string, changes []*change, cache Cache, squash bool) error {
+	owner, name, ok := strings.Cut(repo, "/")
+	if githubBatchSize <= 0 || !ok {
+		return nil
 	}
-	defer resp.Body.Close()
+	var prs []int64
+	for _, c := range changes {
+		matches := prr.FindStringSubmatch(c.Description)
+		if len(matches) != 3 && squash {
+			matches = squashr.FindStringSubmatch(c.Description)
+		}
+		if matches == nil || matches[1] == "" {
+			continue
+		}
+		prn, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		_, key := prInfoKey(repo, prn)
+		if _, ok := cache.Get(key); !ok {
+			prs = append(prs, prn)
+		}
+	}
+	for start := 0; start < len(prs); start += githubBatchSize {
+		end := start + githubBatchSize
+		if end > len(prs) {
+			end = len(prs)
+		}
+		batch := prs[start:end]
+		logrus.Debugf("Fetching %d pull requests of %s", len(batch), repo)
 
-	if resp.StatusCode >= 400 {
-		if resp.StatusCode >= 403 {
-			logrus.Warn("Forbidden response, try setting GITHUB_ACTOR and GITHUB_TOKEN environment variables")
+		var query strings.Builder
+		query.WriteString("query($owner: String!, $name: String!) {\n  repository(owner: $owner, name: $name) {\n")
+		for _, prn := range batch {
+			fmt.Fprintf(&query, "    pr%d: pullRequest(number: %d) { title body updatedAt labels(first: 100) { totalCount nodes { name description } } }\n", prn, prn)
+		}
+		query.WriteString("  }\n}")
+
+		var result struct {
+			Repository map[string]*struct {
+				Title     string    `json:"title"`
+				Body      string    `json:"body"`
+				UpdatedAt time.Time `json:"updatedAt"`
+				Labels    struct {
+					TotalCount int                `json:"totalCount"`
+					Nodes      []pullRequestLabel `json:"nodes"`
+				} `json:"labels"`
+			} `json:"repository"`
+		}
+		if err := githubGraphQL(query.String(), map[string]interface{}{"owner": owner, "name": name}, &result); err != nil {
+			// A missing pull request fails the whole query, fetch each
+			// separately instead so errors are reported for the change
+			warnings.warn(warningGithub, logrus.Fields{"repo": repo, "error": err}, "Unable to fetch pull requests in batches")
+			return nil
+		}
+		for _, prn := range batch {
+			pr := result.Repository[fmt.Sprintf("pr%d", prn)]
+			if pr == nil || pr.Title == "" || pr.Labels.TotalCount > len(pr.Labels.Nodes) {
+				// Left for the change processor to fetch
+				continue
+			}
+			b, err := json.Marshal(pullRequestInfo{
+				Title:     pr.Title,
+				Body:      pr.Body,
+				Labels:    pr.Labels.Nodes,
+				UpdatedAt: pr.UpdatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			_, key := prInfoKey(repo, prn)
+			cache.Put(key, b)
 		}
-		return pullRequestInfo{}, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, u)
 	}
+	return nil
+}
 
-	dec := json.NewDecoder(resp.Body)
+// getPRInfo returns the Pull Request info from the github API
+//
+// See https://docs.github.com/en/rest/pulls/pulls?apiVersion=2022-11-28#get-a-pull-request
+func (p *githubChangeProcessor) getPRInfo(repo string, prn int64) (pullRequestInfo, error) {
+	u, key := prInfoKey(repo, prn)
+	if b, ok := p.cache.Get(key); ok {
+		var info pullRequestInfo
+		if err := json.Unmarshal(b, &info); err == nil {
+			return info, nil
+		}
+	}
 
+	// When refreshing, the cached pull request is kept unless it has been
+	// updated since it was cached
+	var stale pullRequestInfo
+	if b, ok := staleValue(p.cache, key); ok {
+		if err := json.Unmarshal(b, &stale); err != nil {
+			stale = pullRequestInfo{}
+		}
+	}
+	var etag string
+	if !stale.UpdatedAt.IsZero() {
+		if b, ok := p.cache.Get(etagKey(key)); ok {
+			etag = string(b)
+		}
+	}
 	var info pullRequestInfo
-	if err := dec.Decode(&info); err != nil {
+	modified, etag, err := getGithubJSONConditional(u, stale.UpdatedAt, etag, &info)
+	if err != nil {
 		return pullRequestInfo{}, err
 	}
+	if !modified {
+		logrus.WithField("updated", stale.UpdatedAt).Debugf("pull request %s#%d not modified", repo, prn)
+		info = stale
+	}
 	if info.Title == "" {
 		return pullRequestInfo{}, fmt.Errorf("unexpected empty title for %s", u)
 	}
+	if modified && len(info.Labels) >= inlineLabelLimit {
+		labels, err := getLabels(repo, prn)
+		if err != nil {
+			return pullRequestInfo{}, err
+		}
+		info.Labels = labels
+	}
 
 	cacheB, err := json.Marshal(info)
 	if err == nil {
 		p.cache.Put(key, cacheB)
+		if modified && etag != "" {
+			p.cache.Put(etagKey(key), []byte(etag))
+		}
 	}
 
 	return info, nil
@@ -187,6 +671,8 @@ func (p *githubChangeProcessor) getPRInfo(repo string, prn int64) (pullRequestIn
 
 func (p *githubChangeProcessor) advisoryChange(c *change, info advisoryInfo, ghsa string) {
 	c.IsSecurity = true
+	c.Severity = strings.ToLower(info.Severity)
+	c.CVE = info.CVE
 	c.Link = info.Link
 	if c.Link == "" {
 		c.Link = fmt.Sprintf("https://github.com/%s/security/advisories/%s", p.repo, ghsa)
@@ -195,7 +681,15 @@ func (p *githubChangeProcessor) advisoryChange(c *change, info advisoryInfo, ghs
 	if summary == "" {
 		summary = "Github Security Advisory"
 	}
-	c.Formatted = fmt.Sprintf("%s [%s](%s)", summary, ghsa, c.Link)
+	switch linkStyle {
+	case "markdown":
+		c.Formatted = fmt.Sprintf("%s [%s](%s)", summary, ghsa, c.Link)
+	case "url":
+		c.Formatted = fmt.Sprintf("%s %s", summary, c.Link)
+	default:
+		// advisory identifiers are autolinked
+		c.Formatted = fmt.Sprintf("%s %s", summary, ghsa)
+	}
 	cveInfo := []string{}
 	if info.CVE != "" {
 		cveInfo = append(cveInfo, info.CVE)
@@ -210,11 +704,22 @@ func (p *githubChangeProcessor) advisoryChange(c *change, info advisoryInfo, ghs
 }
 
 type advisoryInfo struct {
-	CVE         string `json:"cve_id"`
-	Link        string `json:"html_url"`
-	Summary     string `json:"summary"`
-	Description string `json:"description"`
-	Severity    string `json:"severity"`
+	CVE             string                  `json:"cve_id"`
+	Link            string                  `json:"html_url"`
+	Summary         string                  `json:"summary"`
+	Description     string                  `json:"description"`
+	Severity        string                  `json:"severity"`
+	Vulnerabilities []advisoryVulnerability `json:"vulnerabilities"`
+}
+
+// advisoryVulnerability is a package affected by an advisory
+type advisoryVulnerability struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	VulnerableVersions string `json:"vulnerable_version_range"`
+	PatchedVersions    string `json:"patched_versions"`
 }
 
 // getAdvisoryInfo returns github security advisory info
@@ -222,49 +727,259 @@ type advisoryInfo struct {
 // See https://docs.github.com/en/rest/security-advisories/repository-advisories?apiVersion=2022-11-28#get-a-repository-security-advisory
 func (p *githubChangeProcessor) getAdvisoryInfo(repo, advisory string) (advisoryInfo, error) {
 	u := fmt.Sprintf("https://api.github.com/repos/%s/security-advisories/%s", repo, advisory)
-	key := u + " cve link summary description severity"
-	if !p.refreshCache {
-		if b, ok := p.cache.Get(key); ok {
-			var info advisoryInfo
-			if err := json.Unmarshal(b, &info); err == nil {
-				return info, nil
-			}
-		}
+	key := u + " cve link summary description severity vulnerabilities"
+	var info advisoryInfo
+	if err := getCachedGithubJSON(u, key, p.cache, &info); err != nil {
+		return advisoryInfo{}, err
 	}
+	return info, nil
+}
+
+// getGithubJSON requests the GitHub API url and decodes the JSON response
+func getGithubJSON(u string, v interface{}) error {
+	_, err := getGithubJSONSince(u, time.Time{}, v)
+	return err
+}
+
+// getGithubJSONSince decodes the response into v only when the object has
+// been modified since the given time, a zero time always gets the object
+func getGithubJSONSince(u string, since time.Time, v interface{}) (bool, error) {
+	modified, _, err := getGithubJSONConditional(u, since, "", v)
+	return modified, err
+}
+
+// getGithubJSONConditional decodes the response into v only when the object
+// has been modified since the given time or no longer matches the ETag,
+// returning the ETag of the response. A zero time and empty ETag always get
+// the object. Not modified responses do not count against the rate limit.
+func getGithubJSONConditional(u string, since time.Time, etag string, v interface{}) (bool, string, error) {
 	req, err := http.NewRequest("GET", u, nil)
 	if err != nil {
-		return advisoryInfo{}, err
+		return false, "", err
 	}
 	req.Header.Add("Accept", "application/vnd.github+json")
 	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
+	if !since.IsZero() {
+		req.Header.Add("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+	setGithubAuth(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && (!since.IsZero() || etag != "") {
+		return false, etag, nil
+	}
+
+	if resp.StatusCode >= 400 {
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			warnings.warn(warningGithub, logrus.Fields{"url": u, "reset": resp.Header.Get("X-RateLimit-Reset")}, "GitHub API rate limit exceeded")
+		} else if resp.StatusCode >= 403 {
+			warnings.warn(warningGithub, logrus.Fields{"url": u}, "Forbidden response, try setting GITHUB_ACTOR and GITHUB_TOKEN environment variables")
+		}
+		return false, "", fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, u)
+	}
+
+	return true, resp.Header.Get("ETag"), json.NewDecoder(resp.Body).Decode(v)
+}
+
+// etagKey returns the cache key of the ETag of the object cached at the key
+func etagKey(key string) string {
+	return "etag " + key
+}
+
+// getCachedGithubJSON decodes the object cached at the key into v, or
+// otherwise requests the url. An object being refreshed is revalidated with
+// its ETag and reused when not modified.
+func getCachedGithubJSON(u, key string, cache Cache, v interface{}) error {
+	if b, ok := cache.Get(key); ok && json.Unmarshal(b, v) == nil {
+		return nil
+	}
+	var etag string
+	stale, ok := staleValue(cache, key)
+	if ok {
+		if b, ok := cache.Get(etagKey(key)); ok {
+			etag = string(b)
+		}
+	}
+	modified, etag, err := getGithubJSONConditional(u, time.Time{}, etag, v)
+	if err != nil {
+		return err
+	}
+	if !modified {
+		logrus.WithField("etag", etag).Debugf("%s not modified", u)
+		if err := json.Unmarshal(stale, v); err != nil {
+			return err
+		}
+		cache.Put(key, stale)
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err == nil {
+		cache.Put(key, b)
+		if etag != "" {
+			cache.Put(etagKey(key), []byte(etag))
+		}
+	}
+	return nil
+}
+
+// setGithubAuth adds the credentials from the environment to the request
+func setGithubAuth(req *http.Request) {
 	if user, token := os.Getenv("GITHUB_ACTOR"), os.Getenv("GITHUB_TOKEN"); user != "" && token != "" {
 		req.SetBasicAuth(user, token)
+	} else if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// postGithubJSON sends the value as JSON to the GitHub API and decodes the
+// JSON response into result when not nil
+func postGithubJSON(u string, v, result interface{}) error {
+	b, err := json.Marshal(v)
 	if err != nil {
-		return advisoryInfo{}, err
+		return err
+	}
+	req, err := http.NewRequest("POST", u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/vnd.github+json")
+	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
+	setGithubAuth(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		if resp.StatusCode >= 403 {
-			logrus.Warn("Forbidden response, try setting GITHUB_USER and GITHUB_TOKEN environment variables")
+		if resp.StatusCode == 401 || resp.StatusCode == 403 {
+			warnings.warn(warningGithub, logrus.Fields{"url": u}, "Unauthorized response, try setting GITHUB_ACTOR and GITHUB_TOKEN environment variables")
 		}
-		return advisoryInfo{}, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, u)
+		return fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, u)
+	}
+	if result == nil {
+		return nil
 	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
 
-	dec := json.NewDecoder(resp.Body)
+// githubGraphQL runs the GraphQL query against the GitHub API and decodes
+// the data of the response into v
+//
+// See https://docs.github.com/en/graphql/guides/forming-calls-with-graphql
+func githubGraphQL(query string, variables map[string]interface{}, v interface{}) error {
+	b, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", "https://api.github.com/graphql", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setGithubAuth(req)
 
-	var info advisoryInfo
-	if err := dec.Decode(&info); err != nil {
-		return advisoryInfo{}, err
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	cacheB, err := json.Marshal(info)
-	if err == nil {
-		p.cache.Put(key, cacheB)
+	if resp.StatusCode >= 400 {
+		if resp.StatusCode == 401 || resp.StatusCode == 403 {
+			warnings.warn(warningGithub, nil, "Unauthorized GraphQL response, try setting the GITHUB_TOKEN environment variable")
+		}
+		return fmt.Errorf("unexpected status code %d for GraphQL query", resp.StatusCode)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
 	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("graphql error: %s", result.Errors[0].Message)
+	}
+	return json.Unmarshal(result.Data, v)
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type releaseInfo struct {
+	TagName string         `json:"tag_name"`
+	Name    string         `json:"name"`
+	Body    string         `json:"body"`
+	Assets  []releaseAsset `json:"assets"`
+}
 
+// verifyCommitCount checks the number of commits from git log matches the
+// number of commits GitHub compares between the base and head, which differ
+// when the local clone is stale or another fork is checked out
+//
+// See https://docs.github.com/en/rest/commits/commits?apiVersion=2022-11-28#compare-two-commits
+func verifyCommitCount(repo, base, head string, count int) error {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/compare/%s...%s?per_page=1", repo, base, head)
+	var comparison struct {
+		TotalCommits int `json:"total_commits"`
+	}
+	if err := getGithubJSON(u, &comparison); err != nil {
+		return fmt.Errorf("failed to compare commits on GitHub: %w", err)
+	}
+	if comparison.TotalCommits != count {
+		return fmt.Errorf("found %s locally but GitHub compares %s in %s, check the clone is up to date", pluralize(count, "commit"), pluralize(comparison.TotalCommits, "commit"), repo)
+	}
+	return nil
+}
+
+// getReleaseInfo returns the published release for a tag
+//
+// See https://docs.github.com/en/rest/releases/releases?apiVersion=2022-11-28#get-a-release-by-tag-name
+func getReleaseInfo(repo, tag string, cache Cache) (releaseInfo, error) {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, tag)
+	key := u + " tag name body assets"
+	var info releaseInfo
+	if err := getCachedGithubJSON(u, key, cache, &info); err != nil {
+		return releaseInfo{}, err
+	}
 	return info, nil
 }
+
+var notesDepRegexp = regexp.MustCompile(`^\* \*\*(\S+)\*\*\s+(?:\S+ -> (\S+)|(\S+) \*\*_new_\*\*)`)
+
+// parseNotesDependencies parses the dependency versions from the
+// "Dependency Changes" section of rendered release notes
+func parseNotesDependencies(notes string) map[string]string {
+	deps := map[string]string{}
+	for _, line := range strings.Split(notes, "\n") {
+		matches := notesDepRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		if matches[2] != "" {
+			deps[matches[1]] = matches[2]
+		} else {
+			deps[matches[1]] = matches[3]
+		}
+	}
+	return deps
+}
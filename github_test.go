@@ -0,0 +1,756 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseNotesDependencies(t *testing.T) {
+	notes := "### Dependency Changes\n\n" +
+		"* **github.com/containerd/ttrpc**                v1.2.1 -> v1.2.2\n" +
+		"* **github.com/containerd/log**                  v0.1.0 **_new_**\n" +
+		"\nPrevious release can be found at [v1.7.0](https://github.com/containerd/containerd/releases/tag/v1.7.0)\n"
+
+	deps := parseNotesDependencies(notes)
+	for name, version := range map[string]string{
+		"github.com/containerd/ttrpc": "v1.2.2",
+		"github.com/containerd/log":   "v0.1.0",
+	} {
+		if deps[name] != version {
+			t.Errorf("[%s] unexpected version %q, expected %q", name, deps[name], version)
+		}
+	}
+	if len(deps) != 2 {
+		t.Errorf("unexpected dependencies %v", deps)
+	}
+}
+
+func TestBackportOf(t *testing.T) {
+	p := &githubChangeProcessor{repo: "containerd/containerd"}
+	for _, tc := range []struct {
+		title string
+		body  string
+		ref   string
+		link  string
+	}{
+		{"Fix shim cleanup", "Backport of #8123", "#8123", "https://github.com/containerd/containerd/pull/8123"},
+		{"Fix shim cleanup", "Cherry-pick of containerd/ttrpc#12\n\nFixes races", "containerd/ttrpc#12", "https://github.com/containerd/ttrpc/pull/12"},
+		{"Fix shim cleanup", "cherry picked from #45", "#45", "https://github.com/containerd/containerd/pull/45"},
+		{"Fix shim cleanup", "(cherry picked from commit 0123456789abcdef0123456789abcdef01234567)", "0123456789ab", "https://github.com/containerd/containerd/commit/0123456789abcdef0123456789abcdef01234567"},
+		{"Fix shim cleanup", "Fixes races\n\nCherry-picked-from: 0123456789abcdef\nSigned-off-by: Jane Doe <jane@example.com>", "0123456789ab", "https://github.com/containerd/containerd/commit/0123456789abcdef"},
+		{"[release/1.7] Fix shim cleanup (#123)", "", "#123", "https://github.com/containerd/containerd/pull/123"},
+		{"[release/1.7] Fix shim cleanup (#123)", "Backport of #8123", "#8123", "https://github.com/containerd/containerd/pull/8123"},
+		{"Fix shim cleanup (#123)", "Fixes #123", "", ""},
+	} {
+		ref, link := p.backportOf(tc.title, tc.body)
+		if ref != tc.ref || link != tc.link {
+			t.Errorf("[%q %q] unexpected backport %q %q, expected %q %q", tc.title, tc.body, ref, link, tc.ref, tc.link)
+		}
+	}
+}
+
+func TestGetReleaseNote(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		note string
+	}{
+		{"Fixes #123\n\n```release-note\nAdd support for foo\n```\n", "Add support for foo"},
+		{"```release-note\r\nWindows line endings\r\n```", "Windows line endings"},
+		{"```release-note\n```", ""},
+		{"No release note", ""},
+	} {
+		if note := getReleaseNote(tc.body); note != tc.note {
+			t.Errorf("unexpected release note %q, expected %q", note, tc.note)
+		}
+	}
+}
+
+func TestGetUpgradeNote(t *testing.T) {
+	body := "Fixes #123\n\n```release-note\nRemove foo\n```\n\n```upgrade-note\nRun the migration\nbefore restarting\n```\n"
+	if note := getUpgradeNote(body); note != "Run the migration\nbefore restarting" {
+		t.Errorf("unexpected upgrade note %q", note)
+	}
+	if note := getUpgradeNote("```release-note\nRemove foo\n```"); note != "" {
+		t.Errorf("unexpected upgrade note %q", note)
+	}
+}
+
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestGetLabels(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/containerd/containerd/issues/1234/labels" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		var labels []pullRequestLabel
+		for i := (page - 1) * 2; i < page*2 && i < 5; i++ {
+			labels = append(labels, pullRequestLabel{Name: fmt.Sprintf("label-%d", i)})
+		}
+		json.NewEncoder(w).Encode(labels)
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client, size int) {
+		httpClient = client
+		githubPageSize = size
+	}(httpClient, githubPageSize)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+	githubPageSize = 2
+
+	labels, err := getLabels("containerd/containerd", 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 5 || labels[4].Name != "label-4" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+}
+
+func TestGetPRInfoRefreshNotModified(t *testing.T) {
+	updated := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !updated.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		json.NewEncoder(w).Encode(pullRequestInfo{Title: "Add feature", UpdatedAt: updated})
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	dc := &dirCache{root: t.TempDir()}
+	p := &githubChangeProcessor{repo: "containerd/containerd", cache: dc}
+	if _, err := p.getPRInfo(p.repo, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	p.cache = refreshCache(dc, "prs")
+	info, err := p.getPRInfo(p.repo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Title != "Add feature" || requests != 2 {
+		t.Errorf("unexpected info %v after %d requests", info, requests)
+	}
+
+	updated = updated.Add(time.Hour)
+	info, err = p.getPRInfo(p.repo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.UpdatedAt.Equal(updated) {
+		t.Errorf("expected updated pull request, got %v", info)
+	}
+}
+
+func TestGetReleaseInfoRefreshETag(t *testing.T) {
+	etag := `"v1"`
+	var requests, notModified int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		json.NewEncoder(w).Encode(releaseInfo{TagName: "v2.0.0", Name: "containerd " + etag})
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	dc := &dirCache{root: t.TempDir()}
+	if _, err := getReleaseInfo("containerd/containerd", "v2.0.0", dc); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := getReleaseInfo("containerd/containerd", "v2.0.0", refreshCache(dc, "releases"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != `containerd "v1"` || requests != 2 || notModified != 1 {
+		t.Errorf("unexpected info %v after %d requests", info, requests)
+	}
+
+	etag = `"v2"`
+	info, err = getReleaseInfo("containerd/containerd", "v2.0.0", refreshCache(dc, "releases"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != `containerd "v2"` || notModified != 1 {
+		t.Errorf("expected modified release, got %v", info)
+	}
+}
+
+func TestPrefetchPRInfo(t *testing.T) {
+	var queries int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		queries++
+		var req struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		repository := map[string]interface{}{}
+		for _, prn := range []int{1, 2, 3} {
+			alias := fmt.Sprintf("pr%d", prn)
+			if !strings.Contains(req.Query, alias+":") {
+				continue
+			}
+			repository[alias] = map[string]interface{}{
+				"title":  fmt.Sprintf("Change %d", prn),
+				"body":   "```release-note\nNote\n```",
+				"labels": map[string]interface{}{"totalCount": 1, "nodes": []map[string]string{{"name": "impact/changelog"}}},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"repository": repository}})
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client, size int) {
+		httpClient = client
+		githubBatchSize = size
+	}(httpClient, githubBatchSize)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+	githubBatchSize = 2
+
+	dc := &dirCache{root: t.TempDir()}
+	changes := []*change{
+		{Description: "Merge pull request #1 from user/branch"},
+		{Description: "Fix typo"},
+		{Description: "Merge pull request #2 from user/other"},
+		{Description: "Merge pull request #3 from user/third"},
+	}
+	if err := prefetchPRInfo("containerd/containerd", changes, dc, true); err != nil {
+		t.Fatal(err)
+	}
+	if queries != 2 {
+		t.Errorf("unexpected %d queries, expected 2", queries)
+	}
+
+	p := &githubChangeProcessor{repo: "containerd/containerd", linkName: "", cache: dc}
+	info, err := p.getPRInfo("containerd/containerd", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Title != "Change 3" || len(info.Labels) != 1 || info.Labels[0].Name != "impact/changelog" {
+		t.Errorf("unexpected info %v", info)
+	}
+	if queries != 2 {
+		t.Errorf("expected cached info, got %d queries", queries)
+	}
+}
+
+func TestPrefetchPRInfoFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client, size int, report *warningReport) {
+		httpClient = client
+		githubBatchSize = size
+		warnings = report
+	}(httpClient, githubBatchSize, warnings)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+	githubBatchSize = 2
+	warnings = &warningReport{}
+
+	changes := []*change{{Description: "Merge pull request #1 from user/branch"}}
+	if err := prefetchPRInfo("containerd/containerd", changes, nilCache{}, true); err != nil {
+		t.Fatal(err)
+	}
+	if found := warnings.ofKind(warningGithub); len(found) != 1 || found[0].Message != "Unable to fetch pull requests in batches" || found[0].Fields["repo"] != "containerd/containerd" {
+		t.Errorf("unexpected warnings %+v", found)
+	}
+}
+
+func TestGithubModuleRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		repo string
+		ok   bool
+	}{
+		{"github.com/containerd/containerd", "containerd/containerd", true},
+		{"github.com/containerd/containerd/api", "containerd/containerd", true},
+		{"github.com/containerd/containerd/v2/pkg", "containerd/containerd", true},
+		{"github.com/containerd", "", false},
+		{"gitlab.com/containerd/containerd", "", false},
+	} {
+		repo, ok := githubModuleRepo(tc.name)
+		if repo != tc.repo || ok != tc.ok {
+			t.Errorf("expected %q, %v for %s, got %q, %v", tc.repo, tc.ok, tc.name, repo, ok)
+		}
+	}
+}
+
+func TestSquashMergeChange(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/containerd/containerd/pulls/42" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(pullRequestInfo{
+			Title:  "Fix shim cleanup",
+			Labels: []pullRequestLabel{{Name: "impact/changelog"}},
+		})
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	p := githubChange("containerd/containerd", "", &dirCache{root: t.TempDir()})
+	c := &change{Commit: "0123456789ab", Description: "Fix cleanup of shims (#42)"}
+	if err := p.process(c); err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsMerge || !c.IsHighlight || c.PullRequest != 42 {
+		t.Errorf("unexpected change %+v", c)
+	}
+	if expected := "Fix shim cleanup ([#42](https://github.com/containerd/containerd/pull/42))"; c.Formatted != expected {
+		t.Errorf("expected %q, got %q", expected, c.Formatted)
+	}
+	if squashr.MatchString("Update dependencies") || squashr.MatchString("(#42)") {
+		t.Error("unexpected squash merge match")
+	}
+}
+
+func TestSquashMerged(t *testing.T) {
+	defer func(mode string) {
+		githubSquashPulls = mode
+	}(githubSquashPulls)
+
+	squashed := []*change{{Description: "Fix cleanup of shims (#42)"}}
+	merged := []*change{{Description: "Merge pull request #41 from dev/branch"}, {Description: "Fix cleanup of shims (#42)"}}
+	for _, tc := range []struct {
+		mode     string
+		changes  []*change
+		expected bool
+	}{
+		{"auto", squashed, true},
+		{"auto", merged, false},
+		{"always", merged, true},
+		{"never", squashed, false},
+	} {
+		githubSquashPulls = tc.mode
+		if actual := squashMerged(tc.changes); actual != tc.expected {
+			t.Errorf("expected %v for %d changes in %s mode, got %v", tc.expected, len(tc.changes), tc.mode, actual)
+		}
+	}
+
+	githubSquashPulls = "never"
+	p := githubChange("containerd/containerd", "", nilCache{})
+	c := &change{Commit: "HEAD", Description: "Fix cleanup of shims (#42)"}
+	if err := p.process(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.IsMerge || c.PullRequest != 0 || !strings.Contains(c.Link, "/commit/") {
+		t.Errorf("expected issue reference to be listed as a commit, got %+v", c)
+	}
+}
+
+func TestFormatChangesConcurrently(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		if !strings.HasPrefix(r.URL.Path, "/repos/containerd/containerd/pulls/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(pullRequestInfo{
+			Title: "Change " + strings.TrimPrefix(r.URL.Path, "/repos/containerd/containerd/pulls/"),
+		})
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client, concurrency int) {
+		httpClient = client
+		githubConcurrency = concurrency
+	}(httpClient, githubConcurrency)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+	githubConcurrency = 4
+
+	var changes []*change
+	for i := 1; i <= 20; i++ {
+		changes = append(changes, &change{Description: fmt.Sprintf("Merge pull request #%d from dev/branch", i)})
+	}
+	if err := formatChanges(changes, "containerd/containerd", "", nilCache{}, true, false, false); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range changes {
+		if expected := fmt.Sprintf("Change %d ([#%d](https://github.com/containerd/containerd/pull/%d))", i+1, i+1, i+1); c.Formatted != expected {
+			t.Errorf("expected %q, got %q", expected, c.Formatted)
+		}
+	}
+	for path, n := range requests {
+		if n != 1 {
+			t.Errorf("expected a single request for %s, got %d", path, n)
+		}
+	}
+}
+
+func TestAssociateCommitPR(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/pulls") && strings.HasPrefix(r.URL.Path, "/repos/containerd/containerd/commits/"):
+			w.Write([]byte(`[{"number": 6, "merged_at": null}, {"number": 7, "merged_at": "2023-03-01T12:00:00Z"}]`))
+		case r.URL.Path == "/repos/containerd/containerd/pulls/7":
+			json.NewEncoder(w).Encode(pullRequestInfo{Title: "Rework shim cleanup"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client, commitPulls bool) {
+		httpClient = client
+		githubCommitPulls = commitPulls
+	}(httpClient, githubCommitPulls)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+	githubCommitPulls = true
+
+	p := githubChange("containerd/containerd", "", &dirCache{root: t.TempDir()})
+	first := &change{Commit: "HEAD", Description: "Close shim on cleanup"}
+	second := &change{Commit: "HEAD~1", Description: "Add shim cleanup test"}
+	for _, c := range []*change{first, second} {
+		if err := p.process(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !first.IsMerge || first.PullRequest != 7 || first.Title != "Rework shim cleanup" {
+		t.Errorf("unexpected first change %+v", first)
+	}
+	if second.IsMerge || second.PullRequest != 0 || !strings.Contains(second.Link, "/commit/") {
+		t.Errorf("expected second commit of the pull request to be listed as a commit, got %+v", second)
+	}
+}
+
+func TestAssociateMergedPR(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/pulls") && strings.HasPrefix(r.URL.Path, "/repos/containerd/containerd/commits/"):
+			w.Write([]byte(`[{"number": 7, "merged_at": "2023-03-01T12:00:00Z"}]`))
+		case r.URL.Path == "/repos/containerd/containerd/pulls/7":
+			json.NewEncoder(w).Encode(pullRequestInfo{Title: "Rework shim cleanup"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client, commitPulls bool) {
+		httpClient = client
+		githubCommitPulls = commitPulls
+	}(httpClient, githubCommitPulls)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+	githubCommitPulls = true
+
+	for _, merge := range []string{
+		"Merge pull request #7 from dev/shim-cleanup",
+		"Rework shim cleanup (#7)",
+	} {
+		p := githubChange("containerd/containerd", "", &dirCache{root: t.TempDir()})
+		first := &change{Commit: "HEAD", Description: merge}
+		second := &change{Commit: "HEAD~1", Description: "Add shim cleanup test"}
+		for _, c := range []*change{first, second} {
+			if err := p.process(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !first.IsMerge || first.PullRequest != 7 {
+			t.Errorf("unexpected change %+v for %q", first, merge)
+		}
+		if second.IsMerge || second.PullRequest != 0 || !strings.Contains(second.Link, "/commit/") {
+			t.Errorf("expected commit of %q to be listed as a commit, got %+v", merge, second)
+		}
+	}
+}
+
+func TestApplyReleaseNote(t *testing.T) {
+	for _, tc := range []struct {
+		body           string
+		labeled        bool
+		title          string
+		highlight      bool
+		actionRequired bool
+	}{
+		{"```release-note\nAdd CDI support\n```", true, "Add CDI support", true, false},
+		{"```release-note\nAdd CDI support\n```", false, "Original title", false, false},
+		{"```release-note\nNONE\n```", true, "Original title", false, false},
+		{"Cleanup\n\nrelease-note: NONE\n", true, "Original title", false, false},
+		{"```release-note\nACTION REQUIRED: Remove the deprecated `aufs` snapshotter config\n```", true, "Remove the deprecated `aufs` snapshotter config", true, true},
+		{"```release-note\nACTION REQUIRED: Remove the deprecated `aufs` snapshotter config\n```", false, "Remove the deprecated `aufs` snapshotter config", false, true},
+	} {
+		c := &change{Title: "Original title", IsHighlight: tc.labeled}
+		applyReleaseNote(c, tc.body)
+		if c.Title != tc.title || c.IsHighlight != tc.highlight || c.IsActionRequired != tc.actionRequired {
+			t.Errorf("unexpected change %+v for %q", c, tc.body)
+		}
+	}
+}
+
+func TestVerifyCommitCount(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/containerd/containerd/compare/aaaa...bbbb" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"total_commits":3,"commits":[{"sha":"cccc"}]}`)
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	if err := verifyCommitCount("containerd/containerd", "aaaa", "bbbb", 3); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := verifyCommitCount("containerd/containerd", "aaaa", "bbbb", 2); err == nil || !strings.Contains(err.Error(), "GitHub compares 3 commits") {
+		t.Errorf("expected mismatch, got %v", err)
+	}
+	if err := verifyCommitCount("containerd/containerd", "aaaa", "dddd", 3); err == nil {
+		t.Error("expected unknown commit to fail")
+	}
+}
+
+func TestFormatReference(t *testing.T) {
+	defer func(style string) {
+		linkStyle = style
+	}(linkStyle)
+	pr := "https://github.com/containerd/containerd/pull/42"
+	commit := "https://github.com/containerd/ttrpc/commit/0123456789abcdef"
+	for _, tc := range []struct {
+		style    string
+		expected []string
+	}{
+		{"markdown", []string{"[#42](" + pr + ")", "[containerd/ttrpc#7](https://github.com/containerd/ttrpc/pull/7)", "[`0123456789ab`](" + commit + ")"}},
+		{"url", []string{pr, "https://github.com/containerd/ttrpc/pull/7", commit}},
+		{"reference", []string{"containerd/containerd#42", "containerd/ttrpc#7", "containerd/ttrpc@0123456789ab"}},
+		{"short", []string{"#42", "containerd/ttrpc#7", "0123456789ab"}},
+	} {
+		linkStyle = tc.style
+		for i, actual := range []string{
+			formatReference("containerd/containerd", "", "#", "42", pr),
+			formatReference("containerd/ttrpc", "containerd/ttrpc", "#", "7", "https://github.com/containerd/ttrpc/pull/7"),
+			formatReference("containerd/ttrpc", "", "@", "0123456789ab", commit),
+		} {
+			if actual != tc.expected[i] {
+				t.Errorf("%s: expected %q, got %q", tc.style, tc.expected[i], actual)
+			}
+		}
+	}
+}
+
+func TestGetLabeledPullRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/containerd/containerd/issues" || r.URL.Query().Get("labels") != "cherry-pick/1.7.x" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			fmt.Fprint(w, `[{"number": 1, "title": "Fix shim cleanup", "pull_request": {"merged_at": "2023-03-01T12:00:00Z"}}, {"number": 2, "title": "Shim leaks"}]`)
+		default:
+			fmt.Fprint(w, `[{"number": 3, "title": "Closed unmerged", "pull_request": {"merged_at": null}}]`)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client, size int) {
+		httpClient = client
+		githubPageSize = size
+	}(httpClient, githubPageSize)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+	githubPageSize = 2
+
+	prs, err := getLabeledPullRequests("containerd/containerd", "cherry-pick/1.7.x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 1 || prs[0].Number != 1 {
+		t.Errorf("expected only the merged pull request, got %+v", prs)
+	}
+}
+
+func TestNotBackported(t *testing.T) {
+	candidates := []issueInfo{
+		{Number: 1, Title: "Fix shim cleanup"},
+		{Number: 2, Title: "Update runc"},
+		{Number: 3, Title: "Fix CRI races"},
+	}
+	for _, tc := range []struct {
+		name    string
+		changes []*change
+		missing []int
+	}{
+		{"none", nil, []int{1, 2, 3}},
+		{"by reference", []*change{{IsMerge: true, Title: "[release/1.7] Fix shim cleanup", Backport: "#1"}}, []int{2, 3}},
+		{"by title", []*change{{IsMerge: true, Title: "Update runc"}}, []int{1, 3}},
+		{"title of commit", []*change{{Title: "Update runc"}}, []int{1, 2, 3}},
+		{"other repository", []*change{{IsMerge: true, Title: "Bump ttrpc", Backport: "containerd/ttrpc#3"}}, []int{1, 2, 3}},
+	} {
+		var missing []int
+		for _, pr := range notBackported(candidates, tc.changes) {
+			missing = append(missing, pr.Number)
+		}
+		if !reflect.DeepEqual(missing, tc.missing) {
+			t.Errorf("%s: expected %v not backported, got %v", tc.name, tc.missing, missing)
+		}
+	}
+}
+
+func TestGetGithubJSONWarnings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rate-limited":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "1700000000")
+			w.WriteHeader(http.StatusForbidden)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/unavailable":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client, report *warningReport) {
+		httpClient = client
+		warnings = report
+	}(httpClient, warnings)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	for _, tc := range []struct {
+		path    string
+		message string
+		reset   string
+	}{
+		{"/rate-limited", "GitHub API rate limit exceeded", "1700000000"},
+		{"/forbidden", "Forbidden response, try setting GITHUB_ACTOR and GITHUB_TOKEN environment variables", ""},
+		{"/unavailable", "", ""},
+		{"/ok", "", ""},
+	} {
+		warnings = &warningReport{}
+		u := "https://api.github.com" + tc.path
+		var v struct{}
+		if err := getGithubJSON(u, &v); (err != nil) != (tc.path != "/ok") {
+			t.Errorf("%s: unexpected error %v", tc.path, err)
+		}
+		found := warnings.ofKind(warningGithub)
+		if tc.message == "" {
+			if len(found) != 0 {
+				t.Errorf("%s: unexpected warnings %+v", tc.path, found)
+			}
+			continue
+		}
+		if len(found) != 1 || found[0].Message != tc.message || found[0].Fields["url"] != u || found[0].Fields["reset"] != tc.reset {
+			t.Errorf("%s: unexpected warnings %+v", tc.path, found)
+		}
+	}
+}
+
+func TestGithubHandle(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	var shas []string
+	for _, author := range []string{"Jane Doe <jane@example.com>", "John Roe <john@example.com>"} {
+		tr.git("commit", "-q", "--allow-empty", "--author", author, "-m", "Commit by "+author)
+		shas = append(shas, tr.git("rev-parse", "HEAD"))
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/containerd/containerd/commits/" + shas[0]:
+			fmt.Fprint(w, `{"author": {"login": "jdoe"}}`)
+		case "/repos/containerd/containerd/commits/" + shas[1]:
+			fmt.Fprint(w, `{"author": null}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	for _, tc := range []struct {
+		email  string
+		handle string
+		err    string
+	}{
+		{"jane@example.com", "jdoe", ""},
+		{"john@example.com", "", ""},
+		{"someone@example.com", "", "no commit found for someone@example.com"},
+	} {
+		handle, err := githubHandle("containerd/containerd", "HEAD", tc.email, nilCache{})
+		if tc.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.email, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("%s: expected error %q, got %v", tc.email, tc.err, err)
+		}
+		if handle != tc.handle {
+			t.Errorf("%s: expected handle %q, got %q", tc.email, tc.handle, handle)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const gitlabPrefix = "gitlab.com/"
+
+var (
+	mrr             = regexp.MustCompile(`^Merge branch '.+' into '.+'$`)
+	mergeRequestRef = regexp.MustCompile(`(?m)^See merge request (?:\S+)?!([0-9]+)\s*$`)
+)
+
+type gitlabChangeProcessor struct {
+	repo     string
+	linkName string
+	cache    Cache
+}
+
+func gitlabChange(repo, linkName string, cache Cache) changeProcessor {
+	return &gitlabChangeProcessor{
+		repo:     repo,
+		linkName: linkName,
+		cache:    cache,
+	}
+}
+
+// changeProcessorFor returns the change processor for the repository,
+// repositories prefixed with gitlab.com are hosted on GitLab, those prefixed
+// with a Gitea host on Gitea and all others on GitHub
+func changeProcessorFor(repo, linkName string, cache Cache) changeProcessor {
+	if strings.HasPrefix(repo, gitlabPrefix) {
+		return gitlabChange(strings.TrimPrefix(repo, gitlabPrefix), strings.TrimPrefix(linkName, gitlabPrefix), cache)
+	}
+	if host, name, ok := giteaRepo(repo); ok {
+		if linkName != "" {
+			linkName = name
+		}
+		return giteaChange(host, name, linkName, cache)
+	}
+	return githubChange(repo, linkName, cache)
+}
+
+func (p *gitlabChangeProcessor) process(c *change) error {
+	if mrr.MatchString(c.Description) {
+		message, err := git("log", "-1", "--format=%B", c.Commit)
+		if err != nil {
+			return err
+		}
+		if matches := mergeRequestRef.FindSubmatch(message); matches != nil {
+			mr, err := strconv.ParseInt(string(matches[1]), 10, 64)
+			if err != nil {
+				return err
+			}
+
+			info, err := p.getMRInfo(p.repo, mr)
+			if err != nil {
+				return err
+			}
+			p.mrChange(c, info, mr)
+		} else {
+			logrus.Debugf("No merge request referenced: %q", c.Description)
+		}
+		c.IsMerge = true
+	} else if strings.HasPrefix(c.Description, "Merge") {
+		logrus.Debugf("Not matched: %q", c.Description)
+	}
+
+	if c.Formatted == "" {
+		full, err := git("rev-parse", c.Commit)
+		if err != nil {
+			return err
+		}
+		commit := strings.TrimSpace(string(full))
+
+		commitChange(c, p.repo, p.linkName, fmt.Sprintf("https://gitlab.com/%s/-/commit/%s", p.repo, commit))
+	}
+	return nil
+}
+
+func (p *gitlabChangeProcessor) mrChange(c *change, info mergeRequestInfo, mr int64) {
+	link := info.WebURL
+	if link == "" {
+		link = fmt.Sprintf("https://gitlab.com/%s/-/merge_requests/%d", p.repo, mr)
+	}
+	pullChange(c, p.repo, p.linkName, "!", mr, info.Title, info.Description, info.Labels, link)
+}
+
+type mergeRequestInfo struct {
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Labels      []pullRequestLabel `json:"labels"`
+	WebURL      string             `json:"web_url"`
+}
+
+func (p *gitlabChangeProcessor) getMRInfo(repo string, mr int64) (mergeRequestInfo, error) {
+	u := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/merge_requests/%d?with_labels_details=true", url.PathEscape(repo), mr)
+	key := u + " title description labels web_url"
+	var info mergeRequestInfo
+	err := getCachedJSON(key, p.cache, &info, func(v interface{}) error {
+		if err := getGitlabJSON(u, v); err != nil {
+			return err
+		}
+		if v.(*mergeRequestInfo).Title == "" {
+			return fmt.Errorf("unexpected empty title for %s", u)
+		}
+		return nil
+	})
+	if err != nil {
+		return mergeRequestInfo{}, err
+	}
+	return info, nil
+}
+
+// getGitlabJSON requests the GitLab API url and decodes the JSON response,
+// authenticating with GITLAB_TOKEN when set
+func getGitlabJSON(u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+	return getForgeJSON(req, warningGitlab, "GITLAB_TOKEN", v)
+}
@@ -0,0 +1,83 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestGitlabMergeRequestChange(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fproject/merge_requests/42" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(mergeRequestInfo{
+			Title:       "Add feature",
+			Description: "```release-note\nAdd the feature\n```",
+			Labels:      []pullRequestLabel{{Name: "impact/changelog"}, {Name: "area/runtime", Description: "Runtime"}},
+			WebURL:      "https://gitlab.com/group/project/-/merge_requests/42",
+		})
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	p, ok := changeProcessorFor("gitlab.com/group/project", "", &dirCache{root: t.TempDir()}).(*gitlabChangeProcessor)
+	if !ok {
+		t.Fatal("expected a GitLab change processor")
+	}
+	info, err := p.getMRInfo(p.repo, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &change{Description: "Merge branch 'feature' into 'main'"}
+	p.mrChange(c, info, 42)
+
+	if !c.IsHighlight || c.Category != "Runtime" || c.PullRequest != 42 {
+		t.Errorf("unexpected change %+v", c)
+	}
+	if expected := "Add the feature ([!42](https://gitlab.com/group/project/-/merge_requests/42))"; c.Formatted != expected {
+		t.Errorf("expected %q, got %q", expected, c.Formatted)
+	}
+}
+
+func TestMergeRequestRef(t *testing.T) {
+	for _, tc := range []struct {
+		message string
+		mr      string
+	}{
+		{"Merge branch 'feature' into 'main'\n\nAdd feature\n\nSee merge request group/project!42\n", "42"},
+		{"Merge branch 'feature' into 'main'\n\nSee merge request !7", "7"},
+		{"Merge branch 'feature' into 'main'\n", ""},
+	} {
+		var mr string
+		if matches := mergeRequestRef.FindStringSubmatch(tc.message); matches != nil {
+			mr = matches[1]
+		}
+		if mr != tc.mr {
+			t.Errorf("expected merge request %q in %q, got %q", tc.mr, tc.message, mr)
+		}
+	}
+}
@@ -6,7 +6,8 @@ require (
 	github.com/pelletier/go-toml/v2 v2.0.5
 	github.com/sirupsen/logrus v1.9.0
 	github.com/urfave/cli/v2 v2.20.3
-	golang.org/x/mod v0.6.0
+	go.etcd.io/bbolt v1.3.8
+	golang.org/x/mod v0.13.0
 	golang.org/x/net v0.23.0
 )
 
@@ -0,0 +1,130 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/mod/module"
+)
+
+// isPrivateModule returns whether the module matches the GOPRIVATE,
+// GONOPROXY or GONOSUMDB patterns and should not be resolved through
+// public services
+func isPrivateModule(name string) bool {
+	for _, env := range []string{"GOPRIVATE", "GONOPROXY", "GONOSUMDB"} {
+		if patterns := os.Getenv(env); patterns != "" && module.MatchPrefixPatterns(patterns, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// goProxies returns the proxy urls configured by GOPROXY, only explicitly
+// configured proxies are used and "direct" or "off" end the list
+func goProxies() []string {
+	var proxies []string
+	for _, p := range strings.FieldsFunc(os.Getenv("GOPROXY"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if p == "direct" || p == "off" {
+			break
+		}
+		proxies = append(proxies, strings.TrimSuffix(p, "/"))
+	}
+	return proxies
+}
+
+type proxyInfo struct {
+	Version string `json:"Version"`
+	Origin  *struct {
+		VCS  string `json:"VCS"`
+		URL  string `json:"URL"`
+		Hash string `json:"Hash"`
+	} `json:"Origin"`
+}
+
+var errProxyNotFound = errors.New("not found in module proxy")
+
+// resolveFromProxy resolves the git url and sha of the dependency using the
+// configured module proxies
+//
+// See https://go.dev/ref/mod#goproxy-protocol
+func resolveFromProxy(dep *dependency, cache Cache) error {
+	proxies := goProxies()
+	if len(proxies) == 0 || isPrivateModule(dep.Name) {
+		return errProxyNotFound
+	}
+	escaped, err := module.EscapePath(dep.Name)
+	if err != nil {
+		return err
+	}
+	// The ref has "+incompatible" removed, try it with the suffix as well
+	for _, version := range []string{dep.Ref, dep.Ref + "+incompatible"} {
+		ev, err := module.EscapeVersion(version)
+		if err != nil {
+			return err
+		}
+		for _, proxy := range proxies {
+			info, err := getProxyInfo(fmt.Sprintf("%s/%s/@v/%s.info", proxy, escaped, ev), cache)
+			if err != nil {
+				logrus.WithError(err).Debugf("proxy lookup failed for %s@%s", dep.Name, version)
+				continue
+			}
+			if info.Origin == nil || info.Origin.VCS != "git" || info.Origin.Hash == "" {
+				continue
+			}
+			if dep.GitURL == "" {
+				dep.GitURL = info.Origin.URL
+			}
+			dep.Sha = info.Origin.Hash
+			if len(dep.Sha) > 12 {
+				dep.Sha = dep.Sha[:12]
+			}
+			return nil
+		}
+	}
+	return errProxyNotFound
+}
+
+func getProxyInfo(u string, cache Cache) (proxyInfo, error) {
+	key := "goproxy " + u
+	var info proxyInfo
+	if b, ok := cache.Get(key); ok {
+		if err := json.Unmarshal(b, &info); err == nil {
+			return info, nil
+		}
+	}
+	resp, err := httpClient.Get(u)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return info, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, u)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return info, err
+	}
+	if b, err := json.Marshal(info); err == nil {
+		cache.Put(key, b)
+	}
+	return info, nil
+}
@@ -0,0 +1,70 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveFromProxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/!burnt!sushi/toml/@v/v1.2.0.info":
+			fmt.Fprint(w, `{"Version":"v1.2.0","Origin":{"VCS":"git","URL":"https://github.com/BurntSushi/toml","Hash":"0123456789abcdef0123456789abcdef01234567"}}`)
+		case "/github.com/docker/docker/@v/v20.10.0+incompatible.info":
+			fmt.Fprint(w, `{"Version":"v20.10.0+incompatible","Origin":{"VCS":"git","URL":"https://github.com/moby/moby","Hash":"fedcba9876543210fedcba9876543210fedcba98"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	t.Setenv("GOPROXY", ts.URL+",direct")
+	t.Setenv("GOPRIVATE", "example.com/private")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GONOSUMDB", "")
+
+	for _, tc := range []struct {
+		dep    dependency
+		gitURL string
+		sha    string
+	}{
+		{dependency{Name: "github.com/BurntSushi/toml", Ref: "v1.2.0"}, "https://github.com/BurntSushi/toml", "0123456789ab"},
+		{dependency{Name: "github.com/docker/docker", Ref: "v20.10.0"}, "https://github.com/moby/moby", "fedcba987654"},
+		{dependency{Name: "example.com/private/mod", Ref: "v1.0.0"}, "", ""},
+		{dependency{Name: "github.com/missing/mod", Ref: "v1.0.0"}, "", ""},
+	} {
+		dep := tc.dep
+		err := resolveFromProxy(&dep, &dirCache{root: t.TempDir()})
+		if tc.sha == "" {
+			if err == nil {
+				t.Errorf("%s: expected error, got sha %s", dep.Name, dep.Sha)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", dep.Name, err)
+			continue
+		}
+		if dep.GitURL != tc.gitURL || dep.Sha != tc.sha {
+			t.Errorf("%s: got %s %s, expected %s %s", dep.Name, dep.GitURL, dep.Sha, tc.gitURL, tc.sha)
+		}
+	}
+}
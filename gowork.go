@@ -0,0 +1,113 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+const goWork = "go.work"
+
+// parseGoWorkDependencies returns the merged dependencies of the modules used
+// by the workspace at the commit. Replacements in the workspace override
+// those of the modules. The go.work.sum only holds checksums and is not
+// needed.
+func parseGoWorkDependencies(commit string, contents []byte, replaced map[string]replacedModule) ([]dependency, error) {
+	work, err := modfile.ParseWork(goWork, contents, nil)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, use := range work.Use {
+		files = append(files, path.Join(use.Path, goMod))
+	}
+	modFiles, err := filesFromRev(commit, files)
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[string]struct{}{}
+	var depLists [][]dependency
+	for _, file := range files {
+		b, ok := modFiles[file]
+		if !ok {
+			return nil, fmt.Errorf("workspace module %s not found at %s", file, commit)
+		}
+		members[modfile.ModulePath(b)] = struct{}{}
+		deps, err := parseGoModDependencies(bytes.NewReader(b), replaced)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		depLists = append(depLists, deps)
+	}
+	deps := mergeDependencies(depLists, members)
+	index := map[string]int{}
+	for i, dep := range deps {
+		index[dep.Name] = i
+	}
+
+	for _, replace := range work.Replace {
+		if replaced != nil {
+			replaced[replace.Old.Path] = replacedModule{Old: replace.Old.Path, New: replace.New.Path, Version: replace.New.Version}
+		}
+		if modfile.IsDirectoryPath(replace.New.Path) {
+			continue
+		}
+		commitOrVersion, isSha := getCommitOrVersion(replace.New.Version)
+		if commitOrVersion == "" {
+			return nil, fmt.Errorf("%w: poorly formatted version in replace section %s", errUnknownFormat, replace.New)
+		}
+		dep := formatDependency(replace.New.Path, commitOrVersion, isSha)
+		if i, ok := index[dep.Name]; ok {
+			deps[i] = dep
+		}
+	}
+	return deps, nil
+}
+
+// mergeDependencies merges the dependencies of several modules, excluding
+// the modules themselves. The highest version is used when the modules
+// require different versions of a dependency, otherwise the first seen.
+func mergeDependencies(depLists [][]dependency, members map[string]struct{}) []dependency {
+	depMap := map[string]dependency{}
+	for _, deps := range depLists {
+		for _, dep := range deps {
+			if _, ok := members[dep.Name]; ok {
+				continue
+			}
+			existing, ok := depMap[dep.Name]
+			if ok && (!semver.IsValid(dep.Ref) || !semver.IsValid(existing.Ref) || semver.Compare(dep.Ref, existing.Ref) <= 0) {
+				continue
+			}
+			depMap[dep.Name] = dep
+		}
+	}
+	deps := make([]dependency, 0, len(depMap))
+	for _, dep := range depMap {
+		deps = append(deps, dep)
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Name < deps[j].Name
+	})
+	return deps
+}
@@ -0,0 +1,108 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoWorkDependencies(t *testing.T) {
+	newTestRepo(t, map[string]string{
+		"go.work": `go 1.21
+
+toolchain go1.21.5
+
+use (
+	./api
+	./core
+)
+
+replace github.com/containerd/log => github.com/containerd/log v0.1.1
+`,
+		"api/go.mod": `module github.com/containerd/containerd/api
+
+go 1.21
+
+require github.com/containerd/ttrpc v1.2.2
+`,
+		"core/go.mod": `module github.com/containerd/containerd/core
+
+go 1.21
+
+toolchain go1.21.5
+
+require (
+	github.com/containerd/containerd/api v0.0.0-00010101000000-000000000000
+	github.com/containerd/log v0.1.0
+	github.com/containerd/ttrpc v1.2.4
+)
+`,
+	})
+
+	replaced := map[string]replacedModule{}
+	deps, err := readDependencies("HEAD", "", replaced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"github.com/containerd/log":   "v0.1.1",
+		"github.com/containerd/ttrpc": "v1.2.4",
+	}
+	if len(deps) != len(expected) {
+		t.Fatalf("expected %d dependencies, got %+v", len(expected), deps)
+	}
+	for _, dep := range deps {
+		if expected[dep.Name] != dep.Ref {
+			t.Errorf("expected %s at %q, got %q", dep.Name, expected[dep.Name], dep.Ref)
+		}
+	}
+	if r, ok := replaced["github.com/containerd/log"]; !ok || r.Version != "v0.1.1" {
+		t.Errorf("expected workspace replacement, got %+v", replaced)
+	}
+}
+
+func TestMergeDependencies(t *testing.T) {
+	r := &release{SubPath: "api", SubPaths: []string{"api", "core", "cmd"}}
+	if subpaths := r.subPaths(); !reflect.DeepEqual(subpaths, []string{"api", "core", "cmd"}) {
+		t.Errorf("unexpected subpaths %v", subpaths)
+	}
+	if paths := (&release{}).modulePaths(); !reflect.DeepEqual(paths, []string{""}) {
+		t.Errorf("expected repository root, got %v", paths)
+	}
+
+	deps := mergeDependencies([][]dependency{
+		{
+			{Name: "github.com/containerd/containerd/api", Ref: "v1.0.0"},
+			{Name: "github.com/containerd/ttrpc", Ref: "v1.2.4"},
+			{Name: "github.com/containerd/log", Ref: "abcdef123456", Sha: "abcdef123456"},
+		},
+		{
+			{Name: "github.com/containerd/ttrpc", Ref: "v1.2.2"},
+			{Name: "github.com/containerd/log", Ref: "v0.1.0"},
+			{Name: "github.com/containerd/errdefs", Ref: "v0.1.0"},
+		},
+	}, map[string]struct{}{"github.com/containerd/containerd/api": {}})
+	expected := []dependency{
+		{Name: "github.com/containerd/errdefs", Ref: "v0.1.0"},
+		{Name: "github.com/containerd/log", Ref: "abcdef123456", Sha: "abcdef123456"},
+		{Name: "github.com/containerd/ttrpc", Ref: "v1.2.4"},
+	}
+	if !reflect.DeepEqual(deps, expected) {
+		t.Errorf("expected %+v, got %+v", expected, deps)
+	}
+}
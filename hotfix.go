@@ -0,0 +1,120 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+var hotfixCommand = &cli.Command{
+	Name:      "hotfix",
+	Usage:     "write minimal security release notes for an advisory",
+	ArgsUsage: "<previous> <commit>",
+	Description: `Writes the notes of a security release fixing a single advisory, with the
+advisory summary, the affected versions and the commits between the two refs.
+The advisory is read from the GitHub repository security advisories.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "github-repo",
+			Usage:    "github repository of the project",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "advisory",
+			Usage:    "GitHub security advisory ID fixed by the release, such as GHSA-xxxx-xxxx-xxxx",
+			Required: true,
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 2 {
+			return errors.New("please specify the previous release and the commit as arguments")
+		}
+		ghsa := context.String("advisory")
+		if !strings.HasPrefix(ghsa, "GHSA-") {
+			return fmt.Errorf("invalid advisory %q, expected a GitHub security advisory ID", ghsa)
+		}
+		cache, _, err := openCache(context.String("cache"))
+		if err != nil {
+			return err
+		}
+		repo := context.String("github-repo")
+		p := &githubChangeProcessor{repo: repo, cache: cache}
+		info, err := p.getAdvisoryInfo(repo, ghsa)
+		if err != nil {
+			return fmt.Errorf("failed to get advisory %s: %w", ghsa, err)
+		}
+		changes, err := changelog(context.Args().Get(0), context.Args().Get(1))
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if err := p.process(c); err != nil {
+				return err
+			}
+		}
+		writeHotfixNotes(os.Stdout, repo, ghsa, info, changes)
+		return nil
+	},
+}
+
+// writeHotfixNotes writes the advisory and the fixing changes
+func writeHotfixNotes(w io.Writer, repo, ghsa string, info advisoryInfo, changes []*change) {
+	link := info.Link
+	if link == "" {
+		link = fmt.Sprintf("https://github.com/%s/security/advisories/%s", repo, ghsa)
+	}
+	summary := info.Summary
+	if summary == "" {
+		summary = "Github Security Advisory"
+	}
+	fmt.Fprintf(w, "## %s\n\n", summary)
+	fmt.Fprintf(w, "* Advisory: [%s](%s)\n", ghsa, link)
+	if info.CVE != "" {
+		fmt.Fprintf(w, "* CVE: %s\n", info.CVE)
+	}
+	if info.Severity != "" {
+		fmt.Fprintf(w, "* Severity: %s\n", strings.ToLower(info.Severity))
+	}
+
+	if len(info.Vulnerabilities) > 0 {
+		fmt.Fprintf(w, "\n### Affected versions\n\n")
+		for _, v := range info.Vulnerabilities {
+			fmt.Fprintf(w, "* **%s** %s", v.Package.Name, v.VulnerableVersions)
+			if v.PatchedVersions != "" {
+				fmt.Fprintf(w, ", fixed in %s", v.PatchedVersions)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	fmt.Fprintf(w, "\n### Changes\n\n")
+	for _, c := range changes {
+		if c.Formatted == "" {
+			continue
+		}
+		if !c.IsMerge {
+			fmt.Fprint(w, "  ")
+		}
+		fmt.Fprintf(w, "* %s\n", c.Formatted)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteHotfixNotes(t *testing.T) {
+	info := advisoryInfo{
+		CVE:      "CVE-2023-25153",
+		Summary:  "OCI image importer memory exhaustion",
+		Severity: "MODERATE",
+	}
+	info.Vulnerabilities = make([]advisoryVulnerability, 1)
+	info.Vulnerabilities[0].Package.Name = "github.com/containerd/containerd"
+	info.Vulnerabilities[0].VulnerableVersions = "< 1.6.18"
+	info.Vulnerabilities[0].PatchedVersions = "1.6.18"
+	changes := []*change{
+		{IsMerge: true, Formatted: "Merge import fix ([#8080](https://github.com/containerd/containerd/pull/8080))"},
+		{Formatted: "Limit image import size"},
+	}
+
+	var b strings.Builder
+	writeHotfixNotes(&b, "containerd/containerd", "GHSA-259w-8hf6-59c2", info, changes)
+	expected := `## OCI image importer memory exhaustion
+
+* Advisory: [GHSA-259w-8hf6-59c2](https://github.com/containerd/containerd/security/advisories/GHSA-259w-8hf6-59c2)
+* CVE: CVE-2023-25153
+* Severity: moderate
+
+### Affected versions
+
+* **github.com/containerd/containerd** < 1.6.18, fixed in 1.6.18
+
+### Changes
+
+* Merge import fix ([#8080](https://github.com/containerd/containerd/pull/8080))
+  * Limit image import size
+`
+	if b.String() != expected {
+		t.Errorf("unexpected notes:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// httpClient is used for all outbound http requests
+var httpClient = &http.Client{Transport: &retryTransport{http.DefaultTransport}}
+
+// offline forbids all network access, remote resources must be answered
+// from the cache
+var offline bool
+
+// errNotCached is returned for a remote resource which is not cached when
+// network access is forbidden
+var errNotCached = errors.New("not cached, network access is disabled by --offline")
+
+// offlineTransport fails every request
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errNotCached
+}
+
+// goOffline forbids network access by the http client and git
+func goOffline() {
+	offline = true
+	httpClient = &http.Client{Transport: offlineTransport{}}
+	gitConfigs["protocol.allow"] = "never"
+}
+
+// httpRetries is the number of times a failed request is retried
+const httpRetries = 3
+
+// retryTransport retries GET and HEAD requests which fail from network
+// errors or transient server errors, waiting longer between each attempt
+type retryTransport struct {
+	http.RoundTripper
+}
+
+func (rt *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return rt.RoundTripper.RoundTrip(req)
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := rt.RoundTripper.RoundTrip(req)
+		if attempt > httpRetries || !retryable(resp, err) {
+			return resp, err
+		}
+		if err != nil {
+			logrus.WithError(err).Debugf("Retrying %s", req.URL)
+		} else {
+			logrus.Debugf("Retrying %s after status code %d", req.URL, resp.StatusCode)
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(retryDelay * time.Duration(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryDelay is multiplied by the attempt number to wait before retrying
+var retryDelay = time.Second
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// configureHTTP sets up the http client and git to use the given proxy
+// and trust the certificates in the given CA file, in addition to the
+// system roots. An empty proxy uses the standard environment variables.
+func configureHTTP(proxy, caCert string) error {
+	if proxy == "" && caCert == "" {
+		return nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %q: %w", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+		gitConfigs["http.proxy"] = proxy
+	}
+	if caCert != "" {
+		b, err := os.ReadFile(caCert)
+		if err != nil {
+			return fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no certificates found in %s", caCert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		// git uses the file in place of the system roots, so it is given
+		// a bundle of the system roots and the certificate
+		bundle, err := writeCABundle(b)
+		if err != nil {
+			return fmt.Errorf("failed to write git CA bundle: %w", err)
+		}
+		gitConfigs["http.sslCAInfo"] = bundle
+	}
+	httpClient = &http.Client{Transport: &retryTransport{transport}}
+	return nil
+}
+
+// systemCAFiles are the common locations of the system CA bundle, the
+// SSL_CERT_FILE environment variable takes precedence
+var systemCAFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// caBundle is the temporary CA bundle given to git, removed by
+// removeCABundle when the run completes
+var caBundle string
+
+// writeCABundle writes the system CA bundle followed by the certificates to
+// a temporary file, returning its path
+func writeCABundle(certs []byte) (string, error) {
+	files := systemCAFiles
+	if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+		files = []string{f}
+	}
+	var system []byte
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err == nil {
+			system = b
+			break
+		}
+	}
+	if system == nil {
+		logrus.Warn("No system CA bundle found, git only trusts the given CA certificate")
+	}
+	f, err := os.CreateTemp("", "release-tool-ca-*.pem")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if len(system) > 0 && system[len(system)-1] != '\n' {
+		system = append(system, '\n')
+	}
+	if _, err := f.Write(append(system, certs...)); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	removeCABundle()
+	caBundle = f.Name()
+	return caBundle, nil
+}
+
+// removeCABundle removes the temporary CA bundle given to git
+func removeCABundle() {
+	if caBundle != "" {
+		os.Remove(caBundle)
+		caBundle = ""
+	}
+}
@@ -0,0 +1,120 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigureHTTPCACert(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	defer func(client *http.Client) {
+		httpClient = client
+		delete(gitConfigs, "http.sslCAInfo")
+		removeCABundle()
+	}(httpClient)
+	system := filepath.Join(t.TempDir(), "system.pem")
+	systemCert := "-----BEGIN CERTIFICATE-----\nc3lzdGVt\n-----END CERTIFICATE-----"
+	if err := os.WriteFile(system, []byte(systemCert), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSL_CERT_FILE", system)
+
+	if _, err := httpClient.Get(ts.URL); err == nil {
+		t.Fatal("expected certificate error before configuring CA")
+	}
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caCert, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := configureHTTP("", caCert); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := httpClient.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// git is given the system roots along with the certificate
+	bundle, err := os.ReadFile(gitConfigs["http.sslCAInfo"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := systemCert + "\n" + string(b); string(bundle) != expected {
+		t.Errorf("expected git CA bundle %q, got %q", expected, bundle)
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	defer func(delay time.Duration) {
+		retryDelay = delay
+	}(retryDelay)
+	retryDelay = time.Millisecond
+
+	resp, err := httpClient.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts != 3 {
+		t.Errorf("unexpected status code %d after %d attempts", resp.StatusCode, attempts)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	defer func(client *http.Client) {
+		httpClient = client
+		offline = false
+		delete(gitConfigs, "protocol.allow")
+	}(httpClient)
+	goOffline()
+
+	var info releaseInfo
+	if err := getGithubJSON("https://api.github.com/repos/containerd/containerd/releases/tags/v2.0.0", &info); !errors.Is(err, errNotCached) {
+		t.Errorf("expected not cached error, got %v", err)
+	}
+
+	cache := &dirCache{root: t.TempDir()}
+	if _, err := getSha("https://github.com/containerd/log", "v0.1.0", cache); !errors.Is(err, errNotCached) {
+		t.Errorf("expected not cached error, got %v", err)
+	}
+	key := "git ls-remote https://github.com/containerd/log v0.1.0 v0.1.0^{}"
+	if err := cache.Put(key, []byte("7c9e7ee3bd45d0e5e7c8fc1af7dafcc6d8af0ca1")); err != nil {
+		t.Fatal(err)
+	}
+	if sha, err := getSha("https://github.com/containerd/log", "v0.1.0", cache); err != nil || sha != "7c9e7ee3bd45d0e5e7c8fc1af7dafcc6d8af0ca1" {
+		t.Errorf("expected cached sha, got %q: %v", sha, err)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
+)
+
+var initCommand = &cli.Command{
+	Name:      "init",
+	Usage:     "write a starter release file for a tag",
+	ArgsUsage: "<tag>",
+	Description: `Writes a release file for the tag with the project name and GitHub
+repository of the remote, the previous release detected from the latest
+earlier version tag and the current HEAD commit, followed by commented
+examples of notes, breaking changes and ignored dependencies.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "remote",
+			Usage: "git remote to detect the GitHub repository from",
+			Value: "origin",
+		},
+		&cli.StringFlag{
+			Name:  "github-repo",
+			Usage: "github repository of the project, detected from the remote by default",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "file to write the release file to instead of stdout",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "overwrite the output file when it already exists",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the tag as the first argument")
+		}
+		tag := context.Args().First()
+		output := context.String("output")
+		if err := checkOverwrite(output, context.Bool("force")); err != nil {
+			return err
+		}
+		repo := context.String("github-repo")
+		if repo == "" {
+			out, err := git("remote", "get-url", context.String("remote"))
+			if err != nil {
+				return fmt.Errorf("unable to detect the GitHub repository, use --github-repo: %w", err)
+			}
+			repo = githubRepoFromURL(strings.TrimSpace(string(out)))
+			if repo == "" {
+				return fmt.Errorf("remote %s is not a GitHub repository, use --github-repo", context.String("remote"))
+			}
+		}
+		commit, err := resolveCommit("HEAD")
+		if err != nil {
+			return err
+		}
+		out, err := git("tag", "--merged", commit)
+		if err != nil {
+			return err
+		}
+		b, err := initRelease(tag, repo, commit, strings.Fields(string(out)))
+		if err != nil {
+			return err
+		}
+		if output != "" {
+			if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+				return err
+			}
+			return os.WriteFile(output, b, 0644)
+		}
+		_, err = os.Stdout.Write(b)
+		return err
+	},
+}
+
+// checkOverwrite returns an error when the output file exists, unless it is
+// forced to be overwritten
+func checkOverwrite(output string, force bool) error {
+	if output == "" || force {
+		return nil
+	}
+	if _, err := os.Stat(output); err == nil {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// githubURLRegexp matches the https and ssh urls of GitHub repositories
+var githubURLRegexp = regexp.MustCompile(`^(?:https://|ssh://git@|git@)github\.com[:/]([\w.-]+/[\w.-]+?)(?:\.git)?/?$`)
+
+// githubRepoFromURL returns the GitHub repository of the remote url, or an
+// empty string when the url is not of a GitHub repository
+func githubRepoFromURL(u string) string {
+	if matches := githubURLRegexp.FindStringSubmatch(u); matches != nil {
+		return matches[1]
+	}
+	return ""
+}
+
+// initRelease returns the starter release file for the tag at the commit,
+// the previous release is the latest earlier version of the tags
+func initRelease(tag, repo, commit string, tags []string) ([]byte, error) {
+	version := tagVersion(tag)
+	b, err := toml.Marshal(releasePlan{
+		Commit:      commit,
+		ProjectName: path.Base(repo),
+		GithubRepo:  repo,
+		Previous:    previousTag(tags, version),
+		PreRelease:  version != "" && semver.Prerelease(version) != "",
+		Preface:     fmt.Sprintf("Welcome to the %s release of %s!", tag, path.Base(repo)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	b = append([]byte(fmt.Sprintf("# Release file for %s, generated by release-tool init\n", tag)), b...)
+	return append(b, initExamples...), nil
+}
+
+// initExamples are the commented examples appended to a starter release file
+const initExamples = `
+# ignore_deps are dependencies left out of the dependency changes
+# ignore_deps = ["github.com/example/unused"]
+
+# match_deps is a pattern of dependencies whose changes are included in the
+# changelog
+# match_deps = "^github.com/(containerd/[a-zA-Z0-9-]+)$"
+
+# notes are extra notes listed after the highlights, keyed by a name
+# [notes.upgrade]
+# title = "Upgrading"
+# description = """\
+# Describe the steps needed when upgrading."""
+
+# breaking are changes marked as breaking, keyed by a name, the commit must be
+# part of the release
+# [breaking.config]
+# commit = "0123456789ab"
+# description = "Describe the breaking change."
+`
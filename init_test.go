@@ -0,0 +1,103 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestGithubRepoFromURL(t *testing.T) {
+	for u, expected := range map[string]string{
+		"https://github.com/containerd/containerd.git":     "containerd/containerd",
+		"https://github.com/containerd/release-tool":       "containerd/release-tool",
+		"git@github.com:containerd/go-cni.git":             "containerd/go-cni",
+		"ssh://git@github.com/containerd/nerdctl.git":      "containerd/nerdctl",
+		"https://gitlab.com/containerd/containerd.git":     "",
+		"https://github.com/containerd/containerd/pull/42": "",
+	} {
+		if repo := githubRepoFromURL(u); repo != expected {
+			t.Errorf("%s: expected %q, got %q", u, expected, repo)
+		}
+	}
+}
+
+func TestInitRelease(t *testing.T) {
+	b, err := initRelease("v2.1.0-rc.0", "containerd/containerd", "0123456789abcdef0123456789abcdef01234567", []string{"v2.0.0", "v2.0.1", "v1.7.20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "# [breaking.config]") || !strings.Contains(string(b), "# ignore_deps") {
+		t.Errorf("expected commented examples in:\n%s", b)
+	}
+	p := filepath.Join(t.TempDir(), "v2.1.0-rc.0.toml")
+	if err := os.WriteFile(p, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := loadRelease(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ProjectName != "containerd" || r.GithubRepo != "containerd/containerd" || r.Previous != "v2.0.1" || !r.PreRelease || r.Commit != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("unexpected release %+v", r)
+	}
+}
+
+func TestInitCommand(t *testing.T) {
+	tr := newTestRepo(t, map[string]string{"README.md": "# example\n"})
+	tr.git("remote", "add", "origin", "git@github.com:containerd/nerdctl.git")
+	for _, tag := range []string{"v1.0.0", "v1.1.0-beta.0", "v1.1.0"} {
+		tr.git("tag", tag)
+	}
+	commit, err := resolveCommit("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(tr.dir, "releases", "v1.2.0.toml")
+	run := func(args ...string) error {
+		app := &cli.App{Commands: []*cli.Command{initCommand}}
+		return app.Run(append([]string{"release-tool", "init", "--output", output}, args...))
+	}
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"v1.2.0"}, ""},
+		{[]string{"v1.2.0"}, output + " already exists, use --force to overwrite it"},
+		{[]string{"--force", "v1.2.0"}, ""},
+	} {
+		err := run(tc.args...)
+		if tc.err == "" && err != nil {
+			t.Fatalf("unexpected error for %v: %v", tc.args, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Fatalf("expected error %q for %v, got %v", tc.err, tc.args, err)
+		}
+	}
+
+	r, err := loadRelease(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ProjectName != "nerdctl" || r.GithubRepo != "containerd/nerdctl" || r.Previous != "v1.1.0" || r.PreRelease || r.Commit != commit {
+		t.Errorf("unexpected release %+v", r)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+var linkRegexp = regexp.MustCompile(`https?://[^\s)<>\]"']+`)
+
+// brokenLink is a link in the notes which could not be verified
+type brokenLink struct {
+	URL    string
+	Reason string
+}
+
+// extractLinks returns the unique urls in the notes in sorted order
+func extractLinks(notes string) []string {
+	seen := map[string]struct{}{}
+	var links []string
+	for _, u := range linkRegexp.FindAllString(notes, -1) {
+		u = strings.TrimRight(u, ".,;:")
+		if _, ok := seen[u]; ok {
+			continue
+		}
+		seen[u] = struct{}{}
+		links = append(links, u)
+	}
+	sort.Strings(links)
+	return links
+}
+
+// checkLinks verifies each url in the notes responds with a non-error status
+// using up to concurrency requests at a time, urls under one of the skipped
+// prefixes are not checked. Verified links are cached so they are
+// not checked again on later runs.
+func checkLinks(notes string, concurrency int, skip []string, cache Cache) []brokenLink {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		links  []string
+		broken []brokenLink
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		work   = make(chan string)
+	)
+	for _, u := range extractLinks(notes) {
+		if !underAnyPrefix(u, skip) {
+			links = append(links, u)
+		}
+	}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range work {
+				if err := checkLink(u, cache); err != nil {
+					mu.Lock()
+					broken = append(broken, brokenLink{URL: u, Reason: err.Error()})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, u := range links {
+		work <- u
+	}
+	close(work)
+	wg.Wait()
+
+	sort.Slice(broken, func(i, j int) bool {
+		return broken[i].URL < broken[j].URL
+	})
+	return broken
+}
+
+func checkLink(u string, cache Cache) error {
+	key := "link-check " + u
+	if _, ok := cache.Get(key); ok {
+		logrus.WithField("cache", "hit").Debug(key)
+		return nil
+	}
+	logrus.WithField("cache", "miss").Debug(key)
+
+	status, err := linkStatus("HEAD", u)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		// Some servers do not support HEAD requests
+		status, err = linkStatus("GET", u)
+	}
+	if err != nil {
+		return err
+	}
+	if status >= 400 {
+		return fmt.Errorf("unexpected status code %d", status)
+	}
+	cache.Put(key, []byte(fmt.Sprint(status)))
+	return nil
+}
+
+func linkStatus(method, u string) (int, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// underAnyPrefix returns whether the url is one of the prefixes or a path,
+// query or fragment below one of them
+func underAnyPrefix(u string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		rest := strings.TrimPrefix(u, prefix)
+		if rest == u {
+			continue
+		}
+		if rest == "" || strings.HasSuffix(prefix, "/") || strings.ContainsAny(rest[:1], "/?#") {
+			return true
+		}
+	}
+	return false
+}
+
+// unpublishedLinks returns the url prefixes of the release page and
+// downloads of the release, which only exist once it is published
+func unpublishedLinks(r *release) []string {
+	if r.GithubRepo == "" {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("https://github.com/%s/releases/tag/%s", r.GithubRepo, r.Tag),
+		fmt.Sprintf("https://github.com/%s/releases/download/%s/", r.GithubRepo, r.Tag),
+	}
+}
@@ -0,0 +1,78 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/ok":
+		case "/get-only":
+			if r.Method != "GET" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	notes := fmt.Sprintf("* Fix ([#1](%[1]s/ok))\n* See %[1]s/get-only.\n* Gone [#2](%[1]s/missing)\n* Download %[1]s/releases/download/v1.7.1/containerd.tar.gz\n\n[1]: %[1]s/ok\n", ts.URL)
+	cache := &dirCache{root: t.TempDir()}
+	skip := []string{ts.URL + "/releases/download/v1.7.1/"}
+	broken := checkLinks(notes, 2, skip, cache)
+	if len(broken) != 1 || broken[0].URL != ts.URL+"/missing" {
+		t.Fatalf("unexpected broken links %v", broken)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	if broken := checkLinks(notes, 2, skip, cache); len(broken) != 1 {
+		t.Fatalf("unexpected broken links %v", broken)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected only the broken link to be checked again, got %d requests", n)
+	}
+}
+
+func TestUnpublishedLinks(t *testing.T) {
+	r := &release{GithubRepo: "containerd/containerd", Tag: "v1.7.1"}
+	for u, skipped := range map[string]bool{
+		"https://github.com/containerd/containerd/releases/tag/v1.7.1":                                true,
+		"https://github.com/containerd/containerd/releases/download/v1.7.1/containerd-1.7.1.tar.gz":   true,
+		"https://github.com/containerd/containerd/releases/tag/v1.7.1#downloads":                      true,
+		"https://github.com/containerd/containerd/releases/tag/v1.7.0":                                false,
+		"https://github.com/containerd/containerd/releases/tag/v1.7.10":                               false,
+		"https://github.com/containerd/containerd/releases/download/v1.7.10/containerd-1.7.10.tar.gz": false,
+		"https://github.com/containerd/containerd/pull/42":                                            false,
+	} {
+		if underAnyPrefix(u, unpublishedLinks(r)) != skipped {
+			t.Errorf("expected %s skipped %v", u, skipped)
+		}
+	}
+	if prefixes := unpublishedLinks(&release{GitlabRepo: "containerd/containerd", Tag: "v1.7.1"}); prefixes != nil {
+		t.Errorf("unexpected prefixes %v", prefixes)
+	}
+}
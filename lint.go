@@ -0,0 +1,166 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultMisspellings are common misspellings found in pull request titles
+var defaultMisspellings = map[string]string{
+	"accomodate":    "accommodate",
+	"acheive":       "achieve",
+	"adress":        "address",
+	"agressive":     "aggressive",
+	"alot":          "a lot",
+	"arguement":     "argument",
+	"begining":      "beginning",
+	"calender":      "calendar",
+	"compatability": "compatibility",
+	"compatibilty":  "compatibility",
+	"concurent":     "concurrent",
+	"consistant":    "consistent",
+	"definately":    "definitely",
+	"dependancy":    "dependency",
+	"dependancies":  "dependencies",
+	"enviroment":    "environment",
+	"existant":      "existent",
+	"occured":       "occurred",
+	"occurence":     "occurrence",
+	"paramter":      "parameter",
+	"persistant":    "persistent",
+	"recieve":       "receive",
+	"recieved":      "received",
+	"reponse":       "response",
+	"seperate":      "separate",
+	"seperately":    "separately",
+	"succesful":     "successful",
+	"successfull":   "successful",
+	"supress":       "suppress",
+	"teh":           "the",
+	"untill":        "until",
+	"writting":      "writing",
+}
+
+// spellDictionary maps misspelled words to their correction
+type spellDictionary map[string]string
+
+// loadSpellDictionary returns the default misspellings combined with the
+// project dictionary. Each line of the project dictionary is either a word
+// accepted as correctly spelled or a "misspelling=correction" pair.
+func loadSpellDictionary(path string) (spellDictionary, error) {
+	dict := spellDictionary{}
+	for k, v := range defaultMisspellings {
+		dict[k] = v
+	}
+	if path == "" {
+		return dict, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		ln := sanitizeLine(s.Text(), "#")
+		if ln == "" {
+			continue
+		}
+		if wrong, right, ok := strings.Cut(ln, "="); ok {
+			dict[strings.ToLower(strings.TrimSpace(wrong))] = strings.TrimSpace(right)
+		} else {
+			delete(dict, strings.ToLower(ln))
+		}
+	}
+	return dict, s.Err()
+}
+
+type lintIssue struct {
+	Line    int
+	Message string
+}
+
+func (li lintIssue) String() string {
+	return fmt.Sprintf("line %d: %s", li.Line, li.Message)
+}
+
+var (
+	referenceUseRegexp = regexp.MustCompile(`\[([^\]]+)\]\[([^\]]+)\]`)
+	referenceDefRegexp = regexp.MustCompile(`^\s{0,3}\[([^\]]+)\]:\s*\S+`)
+	inlineCodeRegexp   = regexp.MustCompile("`[^`]*`")
+	linkTargetRegexp   = regexp.MustCompile(`\]\([^)\s]*\)`)
+	bareURLRegexp      = regexp.MustCompile(`https?://\S+`)
+	wordRegexp         = regexp.MustCompile(`[A-Za-z][A-Za-z0-9]*(?:'[A-Za-z]+)?`)
+)
+
+// lintNotes checks the rendered markdown for unbalanced code fences,
+// reference links without a definition, lines longer than maxLine and
+// misspelled words. Lines within code fences and HTML comments are only
+// checked for balanced fences. A maxLine of 0 disables the length check.
+func lintNotes(md string, maxLine int, dict spellDictionary) []lintIssue {
+	var (
+		issues    []lintIssue
+		lines     = strings.Split(md, "\n")
+		defs      = map[string]struct{}{}
+		fenceLine int
+	)
+	for _, ln := range lines {
+		if matches := referenceDefRegexp.FindStringSubmatch(ln); matches != nil {
+			defs[strings.ToLower(matches[1])] = struct{}{}
+		}
+	}
+	for i, ln := range lines {
+		n := i + 1
+		trimmed := strings.TrimSpace(ln)
+		if strings.HasPrefix(trimmed, "```") {
+			if fenceLine == 0 {
+				fenceLine = n
+			} else {
+				fenceLine = 0
+			}
+			continue
+		}
+		if fenceLine != 0 || strings.HasPrefix(trimmed, "<!--") || referenceDefRegexp.MatchString(ln) {
+			continue
+		}
+
+		text := inlineCodeRegexp.ReplaceAllString(ln, "")
+		for _, matches := range referenceUseRegexp.FindAllStringSubmatch(text, -1) {
+			if _, ok := defs[strings.ToLower(matches[2])]; !ok {
+				issues = append(issues, lintIssue{n, fmt.Sprintf("reference link %q has no definition", matches[2])})
+			}
+		}
+		text = bareURLRegexp.ReplaceAllString(linkTargetRegexp.ReplaceAllString(text, "]"), "")
+		if maxLine > 0 && len(text) > maxLine {
+			issues = append(issues, lintIssue{n, fmt.Sprintf("line is %d characters, longer than %d", len(text), maxLine)})
+		}
+		for _, word := range wordRegexp.FindAllString(text, -1) {
+			if correction, ok := dict[strings.ToLower(word)]; ok {
+				issues = append(issues, lintIssue{n, fmt.Sprintf("%q is misspelled, did you mean %q", word, correction)})
+			}
+		}
+	}
+	if fenceLine != 0 {
+		issues = append(issues, lintIssue{fenceLine, "code fence is not closed"})
+	}
+	return issues
+}
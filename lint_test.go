@@ -0,0 +1,67 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintNotes(t *testing.T) {
+	dictFile := filepath.Join(t.TempDir(), "dictionary")
+	if err := os.WriteFile(dictFile, []byte("# project words\nteh\nshimv2=shim v2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dict, err := loadSpellDictionary(dictFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md := strings.Join([]string{
+		"### Highlights",
+		"* Fix seperate mounts ([#1](https://github.com/containerd/containerd/pull/1))",
+		"* Update teh shimv2 `recieve` handling [#2][1]",
+		"* See [docs][missing]",
+		"* " + strings.Repeat("x", 30) + " ([#3](https://github.com/containerd/containerd/pull/" + strings.Repeat("3", 50) + "))",
+		"```",
+		"recieve in code",
+		"",
+		"[1]: https://github.com/containerd/containerd/pull/2",
+	}, "\n")
+
+	issues := lintNotes(md, 40, dict)
+	expected := []lintIssue{
+		{2, `"seperate" is misspelled, did you mean "separate"`},
+		{3, `"shimv2" is misspelled, did you mean "shim v2"`},
+		{4, `reference link "missing" has no definition`},
+		{6, "code fence is not closed"},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("unexpected issues %v", issues)
+	}
+	for i := range expected {
+		if issues[i] != expected[i] {
+			t.Errorf("[%d] unexpected issue %q, expected %q", i, issues[i], expected[i])
+		}
+	}
+
+	if issues := lintNotes(md, 30, dict); len(issues) != len(expected)+2 {
+		t.Errorf("expected long lines to be reported, got %v", issues)
+	}
+}
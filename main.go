@@ -17,102 +17,304 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
-	"text/tabwriter"
-	"text/template"
+	"time"
 	"unicode"
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
 )
 
 type note struct {
-	Title       string `toml:"title"`
-	Description string `toml:"description"`
+	Title       string `toml:"title" json:"title"`
+	Description string `toml:"description" json:"description"`
 }
 
 type change struct {
-	Commit      string `toml:"commit"`
-	Description string `toml:"description"`
+	Commit      string `toml:"commit" json:"commit"`
+	Description string `toml:"description" json:"description"`
 
-	Title    string
-	Category string
-	Link     string
+	// AuthorName and AuthorEmail are the author of the commit after
+	// applying the mailmap
+	AuthorName  string `json:"author_name"`
+	AuthorEmail string `json:"author_email"`
 
-	IsMerge       bool
-	IsHighlight   bool
-	IsBreaking    bool
-	IsDeprecation bool
-	IsSecurity    bool
+	Title    string   `json:"title"`
+	Category string   `json:"category"`
+	Link     string   `json:"link"`
+	Labels   []string `json:"labels"`
 
-	Formatted string
+	// CategoryList is the sorted list of all categories from the
+	// area labels of the change
+	CategoryList []string `json:"category_list"`
+
+	// Areas are the sorted names of the area labels of the change without
+	// the "area/" prefix, such as "cri", used to tag changes in the
+	// commit lists
+	Areas []string `json:"areas"`
+
+	// Severity and CVE are set from the advisory for security changes
+	Severity string `json:"severity"`
+	CVE      string `json:"cve"`
+
+	// ReleaseNote is the text from the release-note block of the pull
+	// request, used as the title of highlighted changes when present
+	ReleaseNote string `json:"release_note"`
+
+	// UpgradeNote is the text from the upgrade-note block of the pull
+	// request describing steps needed when upgrading
+	UpgradeNote string `json:"upgrade_note"`
+
+	// Backport is the reference to the original pull request or commit
+	// when the change is a backport
+	Backport     string `json:"backport"`
+	BackportLink string `json:"backport_link"`
+
+	// PullRequest is the number of the pull request merging the change
+	PullRequest int64 `json:"pull_request"`
+
+	// Audiences are the audiences of the change from the audience labels
+	// of the pull request, such as "operator" or "developer"
+	Audiences []string `json:"audiences"`
+
+	// DependencyUpdate is the dependency bumped by a Dependabot or
+	// Renovate pull request
+	DependencyUpdate *dependencyUpdate `json:"dependency_update"`
+
+	IsMerge       bool `json:"is_merge"`
+	IsHighlight   bool `json:"is_highlight"`
+	IsBreaking    bool `json:"is_breaking"`
+	IsDeprecation bool `json:"is_deprecation"`
+	IsSecurity    bool `json:"is_security"`
+
+	// IsActionRequired is set for changes requiring action when upgrading,
+	// from an "ACTION REQUIRED" release note or the
+	// release-note-action-required label
+	IsActionRequired bool `json:"is_action_required"`
+
+	Formatted string `json:"formatted"`
+}
+
+// ForAudience returns whether the change is relevant to the audience,
+// changes without audience labels are relevant to every audience
+func (c *change) ForAudience(audience string) bool {
+	if audience == "" || len(c.Audiences) == 0 {
+		return true
+	}
+	for _, a := range c.Audiences {
+		if a == audience {
+			return true
+		}
+	}
+	return false
 }
 
 type dependency struct {
-	Name     string
-	Ref      string
-	Sha      string
-	Previous string
-	GitURL   string
-	New      bool
+	Name     string `json:"name"`
+	Ref      string `json:"ref"`
+	Sha      string `json:"sha"`
+	Previous string `json:"previous"`
+	GitURL   string `json:"git_url"`
+	New      bool   `json:"new"`
+
+	// Unresolved is the error for a dependency which could not be
+	// resolved when running in best effort mode
+	Unresolved string `json:"unresolved"`
+
+	// Usage is "tool" or "test" for a dependency only used by tools or
+	// tests rather than the build, empty otherwise
+	Usage string `json:"usage"`
+
+	// ReleaseNotesLink and CompareLink link to the upstream release notes
+	// and changes, taken from the pull request which bumped the dependency
+	ReleaseNotesLink string `json:"release_notes_link"`
+	CompareLink      string `json:"compare_link"`
 }
 
 type download struct {
-	Filename string
-	Hash     string
+	Filename string `json:"filename"`
+	Hash     string `json:"hash"`
 }
 
 type projectChange struct {
-	Name    string
-	Changes []*change
+	Name    string    `json:"name"`
+	Changes []*change `json:"changes"`
+
+	// Truncated is the number of changes omitted from Changes due to the
+	// dependency changes limit or the size of the notes, with CompareLink
+	// linking to all changes
+	Truncated   int    `json:"truncated"`
+	CompareLink string `json:"compare_link"`
+}
+
+// Total returns the number of changes including truncated changes
+func (pc projectChange) Total() int {
+	return len(pc.Changes) + pc.Truncated
 }
 
 type projectRename struct {
-	Old string `toml:"old"`
-	New string `toml:"new"`
+	Old string `toml:"old" json:"old"`
+	New string `toml:"new" json:"new"`
 }
 
 type dependencyOverride struct {
-	Previous string `toml:"previous"`
+	Previous string `toml:"previous" json:"previous"`
+}
+
+// replacePolicy configures which replace directives are expected in the
+// release
+type replacePolicy struct {
+	// Allow are module paths or path patterns, such as
+	// "github.com/containerd/*", which are expected to be replaced
+	Allow []string `toml:"allow" json:"allow"`
+	// Fail fails the release when a module not allowed is replaced
+	Fail bool `toml:"fail" json:"fail"`
+}
+
+// retraction is a retract directive of the project's go.mod
+type retraction struct {
+	Low       string `json:"low"`
+	High      string `json:"high"`
+	Rationale string `json:"rationale"`
+}
+
+// Versions returns the retracted version or version interval
+func (r retraction) Versions() string {
+	if r.Low == r.High {
+		return r.Low
+	}
+	return fmt.Sprintf("[%s, %s]", r.Low, r.High)
+}
+
+// buildInfo describes how the release artifacts were built
+type buildInfo struct {
+	// GoVersion is the Go toolchain version, such as "1.21.5"
+	GoVersion string `toml:"go_version" json:"go_version"`
+	// CGO is whether cgo was enabled, not shown when unset
+	CGO *bool `toml:"cgo" json:"cgo"`
+	// Flags are notable build flags, such as "-trimpath"
+	Flags []string `toml:"flags" json:"flags"`
+}
+
+// CGOStatus returns "enabled" or "disabled", or empty when not configured
+func (b buildInfo) CGOStatus() string {
+	if b.CGO == nil {
+		return ""
+	}
+	if *b.CGO {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+type licenseChange struct {
+	File string `json:"file"`
+	// Status is one of "added", "modified" or "deleted"
+	Status string `json:"status"`
+}
+
+// replacedModule is a module replaced by a replace directive of go.mod
+type replacedModule struct {
+	Old string `json:"old"`
+	// New is the path of the replacement module or local directory
+	New string `json:"new"`
+	// Version is the version of the replacement module, empty when
+	// replaced by a local directory
+	Version string `json:"version"`
 }
 
 type contributor struct {
-	Name    string
-	Email   string
-	Commits int
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Commits int    `json:"commits"`
 
 	// OtherNames are names seen in the change log associated with
 	// the same email
-	OtherNames []string
+	OtherNames []string `json:"other_names"`
+}
+
+type provenance struct {
+	ToolVersion string `json:"tool_version"`
+	// ConfigHash is the hex encoded sha256 of the release file
+	ConfigHash string `json:"config_hash"`
+	// GeneratedAt is the committer date of the release commit, or the
+	// time from SOURCE_DATE_EPOCH when provided
+	GeneratedAt time.Time `json:"generated_at"`
 }
 
 type highlightChange struct {
-	Project string
-	Change  *change
+	Project string  `json:"project"`
+	Change  *change `json:"change"`
+}
+
+// highlightSection defines a custom highlight category which collects
+// changes by label or by matching the change title.
+type highlightSection struct {
+	Name string `toml:"name" json:"name"`
+	// Labels are pull request labels which place a change in this section
+	Labels []string `toml:"labels" json:"labels"`
+	// Match is a regex matched against the change title
+	Match string `toml:"match" json:"match"`
+	// Order determines the position of the section, lower values first.
+	// Custom sections are always listed before area categories.
+	Order int `toml:"order" json:"order"`
+
+	re *regexp.Regexp
+}
+
+// sectionPositions are the positions in the release notes where custom
+// sections can be placed
+var sectionPositions = []string{"after-preface", "after-highlights", "after-notes", "after-contributors", "after-changes", "after-dependencies", "end"}
+
+// customSection is an extra section of the release notes, the body is
+// read from the file when set
+type customSection struct {
+	Title string `toml:"title" json:"title"`
+	Body  string `toml:"body" json:"body"`
+	File  string `toml:"file" json:"file"`
+	// Position is where the section is placed, defaults to "end"
+	Position string `toml:"position" json:"position"`
 }
 
 type highlightCategory struct {
-	Name    string
-	Changes []highlightChange
+	Name    string            `json:"name"`
+	Changes []highlightChange `json:"changes"`
+}
+
+// comparison is the release compared to an additional previous release,
+// such as the last patch release of the previous minor version
+type comparison struct {
+	Previous    string `json:"previous"`
+	PreviousSha string `json:"previous_sha"`
+
+	Dependencies []dependency        `json:"dependencies"`
+	Highlights   []highlightCategory `json:"highlights"`
 }
 
 type release struct {
-	ProjectName     string             `toml:"project_name"`
-	GithubRepo      string             `toml:"github_repo"`
-	SubPath         string             `toml:"sub_path"`
-	Commit          string             `toml:"commit"`
-	Previous        string             `toml:"previous"`
-	PreRelease      bool               `toml:"pre_release"`
-	Preface         string             `toml:"preface"`
-	Postface        string             `toml:"postface"`
-	Notes           map[string]note    `toml:"notes"`
-	BreakingChanges map[string]*change `toml:"breaking"`
+	ProjectName     string             `toml:"project_name" json:"project_name"`
+	GithubRepo      string             `toml:"github_repo" json:"github_repo"`
+	GitlabRepo      string             `toml:"gitlab_repo" json:"gitlab_repo"`
+	GiteaRepo       string             `toml:"gitea_repo" json:"gitea_repo"`
+	SubPath         string             `toml:"sub_path" json:"sub_path"`
+	SubPaths        []string           `toml:"sub_paths" json:"sub_paths"`
+	Commit          string             `toml:"commit" json:"commit"`
+	Previous        string             `toml:"previous" json:"previous"`
+	PreRelease      bool               `toml:"pre_release" json:"pre_release"`
+	Preface         string             `toml:"preface" json:"preface"`
+	Postface        string             `toml:"postface" json:"postface"`
+	Notes           map[string]note    `toml:"notes" json:"notes"`
+	Sections        []customSection    `toml:"sections" json:"sections"`
+	BreakingChanges map[string]*change `toml:"breaking" json:"breaking"`
 
 	// highlight options
 	//HighlightLabel string   `toml:"highlight_label"`
@@ -120,30 +322,259 @@ type release struct {
 
 	// MatchDeps provides a regex string to match dependencies to be
 	// included as part of the changelog.
-	MatchDeps string `toml:"match_deps"`
+	MatchDeps string `toml:"match_deps" json:"match_deps"`
 	// RenameDeps provides a way to match dependencies which have been
 	// renamed from the old name to the new name.
-	RenameDeps map[string]projectRename `toml:"rename_deps"`
+	RenameDeps map[string]projectRename `toml:"rename_deps" json:"rename_deps"`
 	// IgnoreDeps are dependencies to ignore from the output.
-	IgnoreDeps []string `toml:"ignore_deps"`
+	IgnoreDeps []string `toml:"ignore_deps" json:"ignore_deps"`
+	// ToolDeps and TestDeps are patterns of dependencies only used by
+	// tools or tests, in addition to the modules imported by tools.go
+	ToolDeps []string `toml:"tool_deps" json:"tool_deps"`
+	TestDeps []string `toml:"test_deps" json:"test_deps"`
 	// OverrideDeps is used to override the current dependency calculated
 	// from the dependency list. This can be used to set the previous version
 	// which could be missing for new or moved dependencies.
-	OverrideDeps map[string]dependencyOverride `toml:"override_deps"`
+	OverrideDeps map[string]dependencyOverride `toml:"override_deps" json:"override_deps"`
+	// DepChangesLimit is the maximum number of changes listed for each
+	// matched dependency, the remaining changes are summarized.
+	DepChangesLimit int `toml:"dep_changes_limit" json:"dep_changes_limit"`
+	// ReplacePolicy determines which replace directives are allowed
+	ReplacePolicy replacePolicy `toml:"replace_policy" json:"replace_policy"`
+
+	// HighlightSections are custom highlight categories collecting
+	// matching changes independently of area labels.
+	HighlightSections []highlightSection `toml:"highlight_sections" json:"highlight_sections"`
+	// HighlightDuplicates determines how changes listed in a highlight
+	// category and as breaking or deprecated are shown, one of "repeat"
+	// (the default), "reference" or "annotate".
+	HighlightDuplicates string `toml:"highlight_duplicates" json:"highlight_duplicates"`
+	// AreaTags shows the areas of each change as tags, such as "[cri]",
+	// in the commit lists
+	AreaTags bool `toml:"area_tags" json:"area_tags"`
+
+	// Mailmap is a path, relative to the release file, or url of a mailmap
+	// with canonical names used along with the mailmap of the repository,
+	// which takes precedence, including for dependency contributors
+	Mailmap string `toml:"mailmap" json:"mailmap"`
+
+	// ExcludeContributors are the names or emails of commit authors left
+	// out of the contributors, such as release automation accounts
+	ExcludeContributors []string `toml:"exclude_contributors" json:"exclude_contributors"`
+
+	// ReleaseManagers and Approvers are the people who cut and approved
+	// the release, validated against the project's maintainers file.
+	ReleaseManagers []string `toml:"release_managers" json:"release_managers"`
+	Approvers       []string `toml:"approvers" json:"approvers"`
+
+	// DiscussionCategory is the GitHub Discussions category to announce
+	// the release in when publishing
+	DiscussionCategory string `toml:"discussion_category" json:"discussion_category"`
+
+	// GiteaHosts are the hosts of self-hosted Gitea or Forgejo instances,
+	// in addition to codeberg.org, which serve the project or dependencies
+	GiteaHosts []string `toml:"gitea_hosts" json:"gitea_hosts"`
+
+	// CommitStatus configures the commit status or check run created on
+	// the release commit when publishing, linking to the release notes
+	CommitStatus *commitStatusConfig `toml:"commit_status" json:"commit_status"`
+
+	// Announce configures integrations to announce the release with after
+	// it has been published
+	Announce announceConfig `toml:"announce" json:"announce"`
+
+	// Blog configures the blog post written with --blog
+	Blog blogConfig `toml:"blog" json:"blog"`
+
+	// Build is the build information of the release artifacts
+	Build buildInfo `toml:"build" json:"build"`
+
+	// Artifacts are the release binaries to compare the sizes of with the
+	// previous release
+	Artifacts []artifactConfig `toml:"artifacts" json:"artifacts"`
+
+	// Advisories are the security advisories fixed by the release, listed
+	// with their affected and patched versions
+	Advisories []advisoryConfig `toml:"advisories" json:"advisories"`
 
 	// generated fields
-	Changes      []projectChange
-	Highlights   []highlightCategory
-	Contributors []contributor
-	Dependencies []dependency
-	Tag          string
-	Version      string
-	Downloads    []download
+	Changes      []projectChange     `json:"changes"`
+	Highlights   []highlightCategory `json:"highlights"`
+	UpgradeNotes []highlightChange   `json:"upgrade_notes"`
+	Contributors []contributor       `json:"contributors"`
+	Dependencies []dependency        `json:"dependencies"`
+	Replaced     []replacedModule    `json:"replaced"`
+	Tag          string              `json:"tag"`
+	Version      string              `json:"version"`
+	Downloads    []download          `json:"downloads"`
+
+	// Attestations are the attestations stored on GitHub for the
+	// downloads, such as build provenance from GitHub Actions
+	Attestations []attestation `json:"attestations"`
+
+	// Audience is the audience the notes are generated for, templates
+	// filter changes with it to write notes for a specific audience
+	Audience string `json:"audience"`
+
+	// Comparisons are the dependency changes and highlights compared to
+	// the additional previous releases when previous is given as a list
+	Comparisons []comparison `json:"comparisons"`
+
+	// Retractions are versions of the project retracted since the
+	// previous release
+	Retractions []retraction `json:"retractions"`
+
+	// AdvisoryVersions are the affected and patched versions of the
+	// advisories fixed by the release
+	AdvisoryVersions []advisoryVersions `json:"advisory_versions"`
+
+	// ArtifactSizes are the sizes of the release binaries compared to
+	// the previous release
+	ArtifactSizes []artifactSize `json:"artifact_sizes"`
+
+	// LicenseChanges are the license and notice files of the project
+	// changed since the previous release
+	LicenseChanges []licenseChange `json:"license_changes"`
+
+	// CommitSha and PreviousSha are the full commit shas the commit and
+	// previous refs resolved to when generating the release
+	CommitSha   string `json:"commit_sha"`
+	PreviousSha string `json:"previous_sha"`
+
+	// Provenance records the inputs used to generate the release
+	Provenance *provenance `json:"provenance"`
+
+	// CommitDate and PreviousDate are the commit dates of the commit and
+	// previous refs, GeneratedAt is the time recorded as the generation
+	// time, see generationTime
+	CommitDate   time.Time `json:"commit_date"`
+	PreviousDate time.Time `json:"previous_date"`
+	GeneratedAt  time.Time `json:"generated_at"`
+
+	// PreviousNotes is the published body of the previous release and
+	// PreviousDependencies the dependency versions parsed from it
+	PreviousNotes        string            `json:"previous_notes"`
+	PreviousDependencies map[string]string `json:"previous_dependencies"`
+
+	// Maintenance is set for a release without changes other than from
+	// bots or excluded contributors, rendered with the maintenance template
+	Maintenance bool `json:"maintenance"`
+
+	// cache looks up the pull requests referenced with the pr template
+	// function
+	cache Cache
+}
+
+// SectionsAt returns the custom sections placed at the position
+func (r *release) SectionsAt(position string) []customSection {
+	var sections []customSection
+	for _, section := range r.Sections {
+		if section.Position == position || (section.Position == "" && position == "end") {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// tagVersion returns the semantic version of the tag, ignoring any path
+// prefix such as "api/", or an empty string when it is not a version
+func tagVersion(tag string) string {
+	v := path.Base(tag)
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	return semver.Canonical(v)
+}
+
+// releaseType returns "major", "minor" or "patch" by comparing the versions
+// of the tag and the previous release. When the previous release is not a
+// version or has the same version number, such as an earlier release
+// candidate, the type is determined from the tag alone.
+func (r *release) releaseType() string {
+	current := tagVersion(r.Tag)
+	if current == "" {
+		return ""
+	}
+	core := func(v string) string {
+		return strings.TrimSuffix(v, semver.Prerelease(v))
+	}
+	if previous := tagVersion(r.Previous); previous != "" && core(previous) != core(current) {
+		switch {
+		case semver.Major(previous) != semver.Major(current):
+			return "major"
+		case semver.MajorMinor(previous) != semver.MajorMinor(current):
+			return "minor"
+		}
+		return "patch"
+	}
+	switch {
+	case strings.HasSuffix(core(current), ".0.0"):
+		return "major"
+	case strings.HasSuffix(core(current), ".0"):
+		return "minor"
+	}
+	return "patch"
+}
+
+// subPaths returns the subpaths of the go modules of the release, from
+// sub_path and sub_paths
+func (r *release) subPaths() []string {
+	var subpaths []string
+	seen := map[string]struct{}{}
+	for _, subpath := range append([]string{r.SubPath}, r.SubPaths...) {
+		if _, ok := seen[subpath]; ok || subpath == "" {
+			continue
+		}
+		seen[subpath] = struct{}{}
+		subpaths = append(subpaths, subpath)
+	}
+	return subpaths
+}
+
+// modulePaths returns the subpaths of the go modules of the release, or the
+// root of the repository when no subpath is set
+func (r *release) modulePaths() []string {
+	if subpaths := r.subPaths(); len(subpaths) > 0 {
+		return subpaths
+	}
+	return []string{""}
+}
+
+// changeRepo returns the repository the changes are formatted for, the
+// GitLab or Gitea repository when set instead of the GitHub repository
+func (r *release) changeRepo() string {
+	if r.GitlabRepo != "" {
+		return gitlabPrefix + r.GitlabRepo
+	} else if r.GiteaRepo != "" {
+		return r.GiteaRepo
+	}
+	return r.GithubRepo
+}
+
+// IsMajor returns whether the release changes the major version
+func (r *release) IsMajor() bool {
+	return r.releaseType() == "major"
+}
+
+// IsMinor returns whether the release changes the minor version
+func (r *release) IsMinor() bool {
+	return r.releaseType() == "minor"
+}
+
+// IsPatch returns whether the release is a patch release
+func (r *release) IsPatch() bool {
+	return r.releaseType() == "patch"
+}
+
+// IsRC returns whether the release is a release candidate
+func (r *release) IsRC() bool {
+	return strings.HasPrefix(semver.Prerelease(tagVersion(r.Tag)), "-rc")
 }
 
 func main() {
 	app := cli.NewApp()
 	app.Name = "release-tool"
+	app.Version = toolVersion()
 	app.Description = `release tooling to create annotated GitHub release notes.
 
 This tool should run from the root of the project repository for a new release.
@@ -179,6 +610,15 @@ This tool should run from the root of the project repository for a new release.
 			Aliases: []string{"g"},
 			Usage:   "use highlights based on pull request",
 		},
+		&cli.StringFlag{
+			Name:  "link-style",
+			Usage: "style of the links to pull requests and commits: markdown, url, reference (org/repo#123) or short (#123 and commit shas)",
+			Value: "markdown",
+		},
+		&cli.BoolFlag{
+			Name:  "area-tags",
+			Usage: "tag changes in the commit lists with the areas from their area labels",
+		},
 		&cli.BoolFlag{
 			Name:    "short",
 			Aliases: []string{"s"},
@@ -193,21 +633,235 @@ This tool should run from the root of the project repository for a new release.
 			Usage:   "cache directory for static remote resources",
 			EnvVars: []string{"RELEASE_TOOL_CACHE"},
 		},
+		&cli.StringFlag{
+			Name:    "remote-cache",
+			Usage:   "url of a shared cache server accepting GET and PUT requests, the cache directory is used as a local layer",
+			EnvVars: []string{"RELEASE_TOOL_REMOTE_CACHE"},
+		},
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "forbid network access, remote resources must be answered from the cache",
+		},
+		&cli.StringFlag{
+			Name:    "cache-backend",
+			Usage:   "how objects are stored in the cache directory: dir stores a file per object, file stores all objects in a single file",
+			Value:   "dir",
+			EnvVars: []string{"RELEASE_TOOL_CACHE_BACKEND"},
+		},
 		&cli.BoolFlag{
 			Name:    "refresh-cache",
 			Aliases: []string{"r"},
 			Usage:   "refreshes cache",
 		},
+		&cli.BoolFlag{
+			Name:  "check-vendor",
+			Usage: "check the vendor directory at the commit is consistent with go.mod",
+		},
+		&cli.BoolFlag{
+			Name:  "auto-fetch",
+			Usage: "fetch the commit or previous release from origin when it does not exist locally",
+		},
+		&cli.BoolFlag{
+			Name:  "verify-commits",
+			Usage: "check the number of commits matches the GitHub comparison of the same range",
+		},
+		&cli.BoolFlag{
+			Name:  "best-effort",
+			Usage: "continue when a dependency cannot be resolved, marking it as unresolved in the notes",
+		},
+		&cli.BoolFlag{
+			Name:  "resume",
+			Usage: "resume an interrupted run, reusing results it stored in the cache even when refreshing",
+		},
+		&cli.BoolFlag{
+			Name:  "exclude-dev-deps",
+			Usage: "exclude dependencies only used by tools or tests from the dependency changes",
+		},
+		&cli.BoolFlag{
+			Name:  "exclude-dep-contributors",
+			Usage: "only list contributors to the project, excluding contributors to matched dependencies",
+		},
+		&cli.BoolFlag{
+			Name:  "reference-links",
+			Usage: "render links as reference-style markdown collected at the bottom of the notes",
+		},
+		&cli.BoolFlag{
+			Name:  "lint",
+			Usage: "check the rendered notes for markdown issues and misspellings",
+		},
+		&cli.StringFlag{
+			Name:  "lint-dictionary",
+			Usage: "project dictionary of accepted words and misspelling=correction pairs for linting",
+		},
+		&cli.IntFlag{
+			Name:  "lint-max-line",
+			Usage: "maximum length of a line when linting, excluding link urls, 0 to disable",
+			Value: 200,
+		},
+		&cli.BoolFlag{
+			Name:  "check-links",
+			Usage: "verify every link in the rendered notes responds without an error",
+		},
+		&cli.IntFlag{
+			Name:  "check-links-concurrency",
+			Usage: "number of links to check at a time",
+			Value: 8,
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "output format, markdown renders the template and json writes the release data without publishing",
+			Value: "markdown",
+		},
+		&cli.StringFlag{
+			Name:  "checksums",
+			Usage: "checksums file of the release artifacts, as written by the checksums command, to list the downloads from",
+		},
+		&cli.IntFlag{
+			Name:  "max-bytes",
+			Usage: "collapse the largest commit lists into comparison links until the notes fit in the size, 0 to disable",
+			Value: githubReleaseBodyLimit,
+		},
+		&cli.StringFlag{
+			Name:  "audience",
+			Usage: "generate the notes for an audience, such as operator or developer, omitting highlights labeled only for other audiences",
+		},
+		&cli.StringFlag{
+			Name:  "blog",
+			Usage: "write a blog post scaffold with the release highlights to the file",
+		},
+		&cli.StringFlag{
+			Name:  "highlight-overrides",
+			Usage: "TOML file of overrides to include, exclude, retitle or reorder highlighted changes",
+		},
+		&cli.BoolFlag{
+			Name:  "strict",
+			Usage: "fail rather than warn when highlighted pull requests are missing release notes, the notes have lint issues, vendor has drifted or the go version does not match go.mod",
+		},
+		&cli.StringFlag{
+			Name:  "warnings-file",
+			Usage: "write a JSON report of the warnings found to the file",
+		},
+		&cli.BoolFlag{
+			Name:  "maintenance",
+			Usage: "generate a maintenance release when there are no changes other than from bots or excluded contributors, instead of failing",
+		},
+		&cli.BoolFlag{
+			Name:  "preface-from-tag",
+			Usage: "use the message of the existing annotated tag as the preface, when regenerating the notes of a tagged release",
+		},
+		&cli.BoolFlag{
+			Name:  "previous-notes",
+			Usage: "fetch the published release notes of the previous release for use in templates",
+		},
+		&cli.BoolFlag{
+			Name:  "no-keep-clones",
+			Usage: "clone matched dependencies into a temporary directory rather than the cache",
+		},
+		&cli.StringFlag{
+			Name:    "git-cache-max-size",
+			Usage:   "maximum size of the cached dependency clones (e.g. 10G), least recently used clones are removed",
+			EnvVars: []string{"RELEASE_TOOL_GIT_CACHE_MAX_SIZE"},
+		},
+		&cli.StringSliceFlag{
+			Name:  "refresh",
+			Usage: "refreshes only the given cache phases: prs, commit-prs, mrs, gitea-prs, advisories, releases, git, deps, goget, goproxy or links",
+		},
+		&cli.StringSliceFlag{
+			Name:    "cache-ttl",
+			Usage:   "maximum age of cached entries as phase=duration (e.g. prs=24h, goget=7d), or a duration for all phases, older entries are fetched again",
+			EnvVars: []string{"RELEASE_TOOL_CACHE_TTL"},
+		},
+		&cli.IntFlag{
+			Name:  "github-batch-size",
+			Usage: "fetch pull requests in batches of the size using GraphQL, which requires GITHUB_TOKEN, 0 fetches each separately",
+		},
+		&cli.IntFlag{
+			Name:  "github-concurrency",
+			Usage: "number of pull requests and advisories fetched at a time, the changes are listed in their original order",
+			Value: 1,
+		},
+		&cli.BoolFlag{
+			Name:  "github-commit-prs",
+			Usage: "look up the pull request of each commit without one in its message, for rebase merged pull requests",
+		},
+		&cli.StringFlag{
+			Name:  "github-squash-prs",
+			Usage: "whether commit subjects ending in (#N) are squash merged pull requests: auto, always or never, auto treats them as issue references when pull requests are merged with merge commits",
+			Value: "auto",
+		},
+		&cli.IntFlag{
+			Name:  "github-page-size",
+			Usage: "number of items requested per page from the GitHub API, at most 100",
+			Value: 100,
+		},
+		&cli.StringFlag{
+			Name:    "proxy",
+			Usage:   "proxy url for http requests and git, defaults to the HTTPS_PROXY and HTTP_PROXY environment",
+			EnvVars: []string{"RELEASE_TOOL_PROXY"},
+		},
+		&cli.StringFlag{
+			Name:    "ca-cert",
+			Usage:   "PEM file of additional CA certificates to trust, added to the system roots for both HTTP requests and git",
+			EnvVars: []string{"RELEASE_TOOL_CA_CERT"},
+		},
+	}
+	app.After = func(context *cli.Context) error {
+		removeCABundle()
+		return nil
+	}
+	app.Before = func(context *cli.Context) error {
+		if size := context.Int("github-page-size"); size < 1 || size > 100 {
+			return fmt.Errorf("github page size must be between 1 and 100, got %d", size)
+		}
+		githubPageSize = context.Int("github-page-size")
+		githubBatchSize = context.Int("github-batch-size")
+		githubConcurrency = context.Int("github-concurrency")
+		githubCommitPulls = context.Bool("github-commit-prs")
+		githubSquashPulls = context.String("github-squash-prs")
+		if !contains([]string{"auto", "always", "never"}, githubSquashPulls) {
+			return fmt.Errorf("unknown squash merge mode %q, expected auto, always or never", githubSquashPulls)
+		}
+		cacheBackend = context.String("cache-backend")
+		linkStyle = context.String("link-style")
+		if !contains(linkStyles, linkStyle) {
+			return fmt.Errorf("unknown link style %q, expected one of %s", linkStyle, strings.Join(linkStyles, ", "))
+		}
+		if err := configureHTTP(context.String("proxy"), context.String("ca-cert")); err != nil {
+			return err
+		}
+		if context.Bool("offline") {
+			if context.String("cache") == "" {
+				return errors.New("offline mode requires a cache directory")
+			}
+			goOffline()
+		}
+		return nil
+	}
+	app.Commands = []*cli.Command{
+		renderFixtureCommand,
+		cacheCommand,
+		depsCommand,
+		changelogCommand,
+		contributorsCommand,
+		depsSeriesCommand,
+		branchSummaryCommand,
+		checksumsCommand,
+		hotfixCommand,
+		planCommand,
+		initCommand,
+		umbrellaCommand,
+		backportCheckCommand,
+		schemaCommand,
+		versionCommand,
 	}
 	app.Action = func(context *cli.Context) error {
 		var (
-			releasePath  = context.Args().First()
-			tag          = context.String("tag")
-			linkify      = context.Bool("linkify")
-			highlights   = context.Bool("highlights")
-			short        = context.Bool("short")
-			skipCommits  = context.Bool("skip-commits")
-			refreshCache = context.Bool("refresh-cache")
+			releasePath = context.Args().First()
+			tag         = context.String("tag")
+			linkify     = context.Bool("linkify")
+			highlights  = context.Bool("highlights")
+			short       = context.Bool("short")
+			skipCommits = context.Bool("skip-commits")
 		)
 		if tag == "" {
 			tag = parseTag(releasePath)
@@ -216,105 +870,311 @@ This tool should run from the root of the project repository for a new release.
 		if context.Bool("debug") {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
+		if report := context.String("warnings-file"); report != "" {
+			defer func() {
+				if err := warnings.write(report); err != nil {
+					logrus.WithError(err).Error("Failed to write warnings report")
+				}
+			}()
+		}
 
-		var (
-			cache   Cache
-			gitRoot string
-		)
-
-		if cd := context.String("cache"); cd == "" {
-			cache = nilCache{}
-		} else if cd, err := filepath.Abs(cd); err != nil {
+		cache, gitRoot, err := openCache(context.String("cache"))
+		if err != nil {
 			return err
-		} else if _, err = os.Stat(cd); err != nil {
-			return fmt.Errorf("unable to use cache dir: %w", err)
-		} else {
-			gitRoot = filepath.Join(cd, "git")
-			cacheRoot := filepath.Join(cd, "object")
-			if err := os.MkdirAll(gitRoot, 0755); err != nil {
-				return fmt.Errorf("unable to mkdir %s: %w", gitRoot, err)
+		}
+		if context.Bool("no-keep-clones") {
+			gitRoot = ""
+		} else if maxSize := context.String("git-cache-max-size"); maxSize != "" && gitRoot != "" {
+			size, err := parseSize(maxSize)
+			if err != nil {
+				return fmt.Errorf("invalid git cache max size: %w", err)
 			}
-			if err := os.MkdirAll(cacheRoot, 0755); err != nil {
-				return fmt.Errorf("unable to mkdir: %s: %w", cacheRoot, err)
+			defer func(gitRoot string) {
+				if err := pruneMirrors(gitRoot, size); err != nil {
+					logrus.WithError(err).Warn("Failed to prune git cache")
+				}
+			}(gitRoot)
+		}
+		if offline && (context.String("remote-cache") != "" || context.Bool("refresh-cache") || len(context.StringSlice("refresh")) > 0) {
+			return errors.New("offline mode cannot use a remote cache or refresh the cache")
+		}
+		if remote := context.String("remote-cache"); remote != "" {
+			cache = newHTTPCache(remote, os.Getenv("RELEASE_TOOL_REMOTE_CACHE_TOKEN"), cache)
+		}
+		if ttls := context.StringSlice("cache-ttl"); len(ttls) > 0 && !offline {
+			maxAges, err := parseCacheTTLs(ttls)
+			if err != nil {
+				return err
 			}
-			cache = &dirCache{
-				root: cacheRoot,
+			cache = expireCache(cache, maxAges)
+		}
+		baseCache := cache
+		if context.Bool("refresh-cache") {
+			cache = refreshCache(cache, refreshPhases...)
+		} else if phases := context.StringSlice("refresh"); len(phases) > 0 {
+			for _, phase := range phases {
+				if _, ok := refreshNamespaces[phase]; !ok {
+					return fmt.Errorf("unknown cache refresh phase %q", phase)
+				}
 			}
+			cache = refreshCache(cache, phases...)
 		}
 
 		r, err := loadRelease(releasePath)
 		if err != nil {
 			return err
 		}
+		if context.Bool("area-tags") {
+			r.AreaTags = true
+		}
+		if r.AreaTags && !linkify && !highlights {
+			logrus.Warn("Area tags require the labels fetched with --linkify or --highlights")
+		}
+		for i, section := range r.HighlightSections {
+			if section.Match == "" {
+				continue
+			}
+			re, err := regexp.Compile(section.Match)
+			if err != nil {
+				return fmt.Errorf("unable to compile 'match' regexp for highlight section %q: %w", section.Name, err)
+			}
+			r.HighlightSections[i].re = re
+		}
+		switch format := context.String("format"); format {
+		case "markdown", "json":
+		default:
+			return fmt.Errorf("unknown format %q, must be markdown or json", format)
+		}
+		switch r.HighlightDuplicates {
+		case "", "repeat", "reference", "annotate":
+		default:
+			return fmt.Errorf("unknown highlight_duplicates %q, must be repeat, reference or annotate", r.HighlightDuplicates)
+		}
+		if r.CommitStatus != nil {
+			switch r.CommitStatus.Kind {
+			case "", "status", "check":
+			default:
+				return fmt.Errorf("unknown commit_status kind %q, must be status or check", r.CommitStatus.Kind)
+			}
+		}
+		for _, host := range r.GiteaHosts {
+			giteaHosts[host] = struct{}{}
+		}
+		if r.GiteaRepo != "" {
+			giteaHosts[strings.SplitN(r.GiteaRepo, "/", 2)[0]] = struct{}{}
+		}
+		if err := loadSections(r.Sections, filepath.Dir(releasePath)); err != nil {
+			return err
+		}
+		var overrides []highlightOverride
+		if p := context.String("highlight-overrides"); p != "" {
+			if overrides, err = loadHighlightOverrides(p); err != nil {
+				return err
+			}
+		}
 		logrus.Infof("Welcome to the %s release tool...", r.ProjectName)
 
-		if r.SubPath != "" {
-			gitSubpaths = append(gitSubpaths, r.SubPath)
-		}
+		gitSubpaths = append(gitSubpaths, r.subPaths()...)
 
 		mailmapPath, err := filepath.Abs(".mailmap")
 		if err != nil {
 			return fmt.Errorf("failed to resolve mailmap: %w", err)
 		}
+		if r.Mailmap != "" {
+			source := r.Mailmap
+			if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") && !filepath.IsAbs(source) {
+				source = filepath.Join(filepath.Dir(releasePath), source)
+			}
+			merged, err := mergeMailmaps(source, mailmapPath)
+			if err != nil {
+				return fmt.Errorf("failed to merge mailmap: %w", err)
+			}
+			defer os.Remove(merged)
+			mailmapPath = merged
+		}
 		gitConfigs["mailmap.file"] = mailmapPath
 
 		var (
 			contributors   = map[string]contributor{}
 			projectChanges = []projectChange{}
+			// highlightChanges are the changes of each project used for
+			// grouping highlights
+			highlightChanges = []projectChange{}
 		)
 
-		changes, err := changelog(r.Previous, r.Commit)
+		// Resolve the refs up front so the release is generated for a
+		// consistent commit even when a branch is moved during the run
+		resolve := func(ref string) (string, error) {
+			sha, err := resolveCommit(ref)
+			if err != nil && context.Bool("auto-fetch") {
+				return fetchCommit("origin", ref)
+			} else if err != nil {
+				return "", fmt.Errorf("%w or use --auto-fetch to fetch it from origin", err)
+			}
+			return sha, nil
+		}
+		r.CommitSha, err = resolve(r.Commit)
+		if err != nil {
+			return err
+		}
+		if r.Previous != "" {
+			r.PreviousSha, err = resolve(r.Previous)
+			if err != nil {
+				return err
+			}
+		}
+		r.CommitDate, err = commitDate(r.CommitSha)
 		if err != nil {
 			return err
 		}
-		if linkify || highlights {
-			for _, change := range changes {
-				if err := githubChange(r.GithubRepo, "", cache, refreshCache).process(change); err != nil {
+		if r.PreviousSha != "" {
+			r.PreviousDate, err = commitDate(r.PreviousSha)
+			if err != nil {
+				return err
+			}
+		}
+		for i := range r.Comparisons {
+			r.Comparisons[i].PreviousSha, err = resolve(r.Comparisons[i].Previous)
+			if err != nil {
+				return err
+			}
+		}
+		if err := validateBreakingChanges(r.PreviousSha, r.CommitSha, r.BreakingChanges); err != nil {
+			return err
+		}
+		if err := validateMaintainers(r.CommitSha, append(r.ReleaseManagers, r.Approvers...)); err != nil {
+			return err
+		}
+		for _, subpath := range r.modulePaths() {
+			if err := validateGoVersion(r.CommitSha, subpath, r.Build.GoVersion); err != nil {
+				if context.Bool("strict") {
 					return err
 				}
-				if !change.IsMerge {
-					if skipCommits {
-						change.Formatted = ""
-					} else if short {
-						change.Formatted = change.Title
-					}
+				warnings.warn(warningBuild, logrus.Fields{"go_version": r.Build.GoVersion}, err.Error())
+			}
+		}
+		if !strings.HasPrefix(r.CommitSha, r.Commit) {
+			logrus.Infof("Resolved %s to %s", r.Commit, r.CommitSha)
+		}
+
+		if context.Bool("check-vendor") {
+			var drift []string
+			for _, subpath := range r.modulePaths() {
+				d, err := checkVendorDrift(r.CommitSha, subpath)
+				if err != nil {
+					return fmt.Errorf("failed to check vendor: %w", err)
 				}
+				drift = append(drift, d...)
+			}
+			for _, d := range drift {
+				warnings.warn(warningVendor, nil, "Vendor drift: "+d)
 			}
-		} else {
-			for _, change := range changes {
-				change.Formatted = fmt.Sprintf("* %s %s", change.Commit, change.Description)
+			if len(drift) > 0 && context.Bool("strict") {
+				return fmt.Errorf("vendor directory is inconsistent with go.mod, found %d discrepancies", len(drift))
 			}
 		}
-		if err := addContributors(r.Previous, r.Commit, contributors); err != nil {
+
+		var progress *runProgress
+		if dir := context.String("cache"); dir != "" {
+			dir, err = filepath.Abs(dir)
+			if err != nil {
+				return err
+			}
+			progress, err = openProgress(dir, tag, r.CommitSha, context.Bool("resume"))
+			if err != nil {
+				return fmt.Errorf("unable to open run progress: %w", err)
+			}
+			cache = &resumingCache{
+				Cache:    cache,
+				base:     baseCache,
+				progress: progress,
+			}
+		} else if context.Bool("resume") {
+			return errors.New("resuming requires a cache directory")
+		}
+
+		changes, err := changelog(r.PreviousSha, r.CommitSha)
+		if err != nil {
 			return err
 		}
+		if r.PreviousSha != "" && !hasContributedChanges(changes, r.ExcludeContributors) {
+			if !context.Bool("maintenance") {
+				if len(changes) == 0 {
+					return fmt.Errorf("no changes since %s, use --maintenance to generate a maintenance release", r.Previous)
+				}
+				return fmt.Errorf("no changes since %s other than %s from bots or excluded contributors, use --maintenance to generate a maintenance release", r.Previous, pluralize(len(changes), "commit"))
+			}
+			r.Maintenance = true
+		}
+		if context.Bool("verify-commits") && r.PreviousSha != "" && r.GithubRepo != "" && r.GitlabRepo == "" && r.GiteaRepo == "" {
+			// The changes of a release limited to sub-paths leave out commits
+			// GitHub compares, so all commits in the range are counted
+			count := len(changes)
+			if len(gitSubpaths) > 0 {
+				if count, err = commitCount(r.PreviousSha, r.CommitSha); err != nil {
+					return err
+				}
+			}
+			if err := verifyCommitCount(r.GithubRepo, r.PreviousSha, r.CommitSha, count); err != nil {
+				if context.Bool("strict") {
+					return err
+				}
+				warnings.warn(warningGithub, logrus.Fields{"previous": r.Previous, "commit": r.Commit}, err.Error())
+			}
+		}
+		if err := formatChanges(changes, r.changeRepo(), "", cache, linkify || highlights, short, skipCommits); err != nil {
+			return err
+		}
+		addContributors(changes, contributors, r.ExcludeContributors)
+		var compareLink string
+		if r.Previous != "" && r.GitlabRepo != "" {
+			compareLink = fmt.Sprintf("https://gitlab.com/%s/-/compare/%s...%s", r.GitlabRepo, r.Previous, tag)
+		} else if r.Previous != "" && r.GiteaRepo != "" {
+			compareLink = fmt.Sprintf("https://%s/compare/%s...%s", r.GiteaRepo, r.Previous, tag)
+		} else if r.Previous != "" {
+			compareLink = fmt.Sprintf("https://github.com/%s/compare/%s...%s", r.GithubRepo, r.Previous, tag)
+		}
 		projectChanges = append(projectChanges, projectChange{
-			Name:    "",
-			Changes: changes,
+			Name:        "",
+			Changes:     changes,
+			CompareLink: compareLink,
 		})
+		highlightChanges = append(highlightChanges, projectChanges[0])
 
 		logrus.Infof("creating new release %s with %d new changes...", tag, len(changes))
-		replacedDeps := make(map[string]string)
-		current, err := parseDependencies(r.Commit, r.SubPath, replacedDeps)
+		replacedDeps := make(map[string]replacedModule)
+		current, err := currentDependencies(r, replacedDeps, cache)
 		if err != nil {
 			return err
 		}
-		overrideDependencies(current, r.OverrideDeps)
-
-		previous, err := parseDependencies(r.Previous, r.SubPath, nil)
-		if err != nil {
-			return err
+		var unexpectedReplaces []replacedModule
+		r.Replaced, unexpectedReplaces = checkReplaces(replacedDeps, r.ReplacePolicy)
+		if len(unexpectedReplaces) > 0 && r.ReplacePolicy.Fail {
+			names := make([]string, len(unexpectedReplaces))
+			for i, m := range unexpectedReplaces {
+				names[i] = m.Old
+			}
+			return fmt.Errorf("unexpected replaced modules: %s", strings.Join(names, ", "))
 		}
-		renameDependencies(previous, r.RenameDeps)
 
-		updatedDeps, err := getUpdatedDeps(previous, current, r.IgnoreDeps, cache)
+		var (
+			bestEffort     = context.Bool("best-effort")
+			excludeDevDeps = context.Bool("exclude-dev-deps")
+		)
+		updatedDeps, err := dependencyChanges(r, r.PreviousSha, current, excludeDevDeps, cache, bestEffort)
 		if err != nil {
 			return err
 		}
-
-		sort.Slice(updatedDeps, func(i, j int) bool {
-			return updatedDeps[i].Name < updatedDeps[j].Name
-		})
+		// skipDependency returns the error unless running in best effort
+		// mode, where the dependency is marked unresolved and skipped
+		skipDependency := func(dep *dependency, err error) error {
+			if !bestEffort {
+				return err
+			}
+			warnings.warn(warningDependency, logrus.Fields{"name": dep.Name, "error": err}, "Skipping changes of unresolved dependency")
+			dep.Unresolved = err.Error()
+			return nil
+		}
 
 		if r.MatchDeps != "" && len(updatedDeps) > 0 {
 			re, err := regexp.Compile(r.MatchDeps)
@@ -334,9 +1194,9 @@ This tool should run from the root of the project repository for a new release.
 			if err != nil {
 				return fmt.Errorf("unable to get cwd: %w", err)
 			}
-			for _, dep := range updatedDeps {
+			for i, dep := range updatedDeps {
 				matches := re.FindStringSubmatch(dep.Name)
-				if matches == nil {
+				if matches == nil || dep.Unresolved != "" {
 					continue
 				}
 				logrus.Debugf("Matched dependency %s with %s", dep.Name, r.MatchDeps)
@@ -346,69 +1206,65 @@ This tool should run from the root of the project repository for a new release.
 				} else {
 					name = matches[1]
 				}
-				if err := os.Chdir(gitRoot); err != nil {
-					return fmt.Errorf("unable to chdir to temp clone directory: %w", err)
-				}
-
-				var cloned bool
-				if _, err := os.Stat(name); err != nil && os.IsNotExist(err) {
-					logrus.Debugf("git clone %s %s", dep.GitURL, name)
-					if _, err := git("clone", dep.GitURL, name); err != nil {
-						return fmt.Errorf("failed to clone: %w", err)
+				repo, err := mirrorDependency(gitRoot, name, dep.GitURL, dep.Ref)
+				if err != nil {
+					if err := skipDependency(&updatedDeps[i], err); err != nil {
+						return err
 					}
-					cloned = true
-				} else if err != nil {
-					return fmt.Errorf("unable to stat: %w", err)
-				}
-
-				if err := os.Chdir(name); err != nil {
-					return fmt.Errorf("unable to chdir to cloned %s directory: %w", name, err)
+					continue
 				}
-
-				if !cloned {
-					if _, err := git("show", dep.Ref); err != nil {
-						logrus.WithField("name", name).Debugf("git fetch origin")
-						if _, err := git("fetch", "origin"); err != nil {
-							return fmt.Errorf("failed to fetch: %w", err)
-						}
-					}
+				if err := os.Chdir(repo); err != nil {
+					return fmt.Errorf("unable to chdir to %s mirror: %w", name, err)
 				}
 
 				changes, err := changelog(dep.Previous, dep.Ref)
 				if err != nil {
-					return fmt.Errorf("failed to get changelog for %s: %w", name, err)
+					if err := skipDependency(&updatedDeps[i], fmt.Errorf("failed to get changelog for %s: %w", name, err)); err != nil {
+						return err
+					}
+					continue
 				}
-				if err := addContributors(dep.Previous, dep.Ref, contributors); err != nil {
-					return fmt.Errorf("failed to get authors for %s: %w", name, err)
+				if !context.Bool("exclude-dep-contributors") {
+					addContributors(changes, contributors, r.ExcludeContributors)
 				}
-				if linkify || highlights {
-					if !strings.HasPrefix(dep.Name, "github.com/") {
-						logrus.Debugf("linkify only supported for Github, skipping %s", dep.Name)
-					} else {
-						ghname := dep.Name[11:]
-						for _, change := range changes {
-							if err := githubChange(ghname, ghname, cache, refreshCache).process(change); err != nil {
-								return err
-							}
-							if !change.IsMerge {
-								if skipCommits {
-									change.Formatted = ""
-								} else if short {
-									change.Formatted = change.Title
-								}
-							}
-						}
-					}
+				_, _, gitea := giteaRepo(dep.Name)
+				if (linkify || highlights) && !strings.HasPrefix(dep.Name, "github.com/") && !strings.HasPrefix(dep.Name, gitlabPrefix) && !gitea {
+					logrus.Debugf("linkify only supported for Github, GitLab and Gitea, skipping %s", dep.Name)
 				} else {
-					for _, change := range changes {
-						change.Formatted = fmt.Sprintf("* %s %s", change.Commit, change.Description)
+					ghname := strings.TrimPrefix(dep.Name, "github.com/")
+					if err := formatChanges(changes, ghname, ghname, cache, linkify || highlights, short, skipCommits); err != nil {
+						if err := skipDependency(&updatedDeps[i], err); err != nil {
+							return err
+						}
+						continue
 					}
 				}
 
-				projectChanges = append(projectChanges, projectChange{
+				// Highlights are grouped from all changes of the dependency,
+				// including those truncated from the change list
+				highlightChanges = append(highlightChanges, projectChange{
 					Name:    name,
 					Changes: changes,
 				})
+				var truncated int
+				if r.DepChangesLimit > 0 && len(changes) > r.DepChangesLimit {
+					truncated = len(changes) - r.DepChangesLimit
+					changes = changes[:r.DepChangesLimit]
+					logrus.Debugf("Truncated %d changes from %s", truncated, name)
+				}
+				pc := projectChange{
+					Name:      name,
+					Changes:   changes,
+					Truncated: truncated,
+				}
+				if repo, ok := githubModuleRepo(dep.Name); ok {
+					pc.CompareLink = fmt.Sprintf("https://github.com/%s/compare/%s...%s", repo, dep.Previous, dep.Ref)
+				} else if strings.HasPrefix(dep.Name, gitlabPrefix) {
+					pc.CompareLink = fmt.Sprintf("https://gitlab.com/%s/-/compare/%s...%s", strings.TrimPrefix(dep.Name, gitlabPrefix), dep.Previous, dep.Ref)
+				} else if host, repo, ok := giteaRepo(dep.Name); ok {
+					pc.CompareLink = fmt.Sprintf("https://%s/%s/compare/%s...%s", host, repo, dep.Previous, dep.Ref)
+				}
+				projectChanges = append(projectChanges, pc)
 
 			}
 			if err := os.Chdir(cwd); err != nil {
@@ -416,44 +1272,214 @@ This tool should run from the root of the project repository for a new release.
 			}
 		}
 
+		for _, subpath := range r.modulePaths() {
+			retractions, err := newRetractions(r.PreviousSha, r.CommitSha, subpath)
+			if err != nil {
+				return fmt.Errorf("failed to get retractions: %w", err)
+			}
+			r.Retractions = append(r.Retractions, retractions...)
+		}
+
+		r.LicenseChanges, err = licenseChanges(r.PreviousSha, r.CommitSha, r.subPaths())
+		if err != nil {
+			return fmt.Errorf("failed to check license files: %w", err)
+		}
+		for _, lc := range r.LicenseChanges {
+			warnings.warn(warningLicense, logrus.Fields{"file": lc.File, "status": lc.Status}, "License file changed, make sure the change is intended")
+		}
+
+		if len(r.Advisories) > 0 {
+			r.AdvisoryVersions, err = getAdvisoryVersions(r.Advisories, r.GithubRepo, cache)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(r.Artifacts) > 0 {
+			r.ArtifactSizes, err = getArtifactSizes(r.Artifacts, filepath.Dir(releasePath), r.GithubRepo, r.Previous, tag, cache)
+			if err != nil {
+				return fmt.Errorf("failed to get artifact sizes: %w", err)
+			}
+		}
+
+		for i := range r.Comparisons {
+			if err := compareBaseline(r, &r.Comparisons[i], current, highlights, excludeDevDeps, cache, bestEffort); err != nil {
+				return fmt.Errorf("failed to compare with %s: %w", r.Comparisons[i].Previous, err)
+			}
+		}
+
 		// update the release fields with generated data
 		r.Contributors = orderContributors(contributors)
 		r.Dependencies = updatedDeps
+		linkDependencyUpdates(r.Dependencies, projectChanges[0].Changes)
+		r.UpgradeNotes = upgradeNotes(highlightChanges)
 		if highlights {
-			r.Highlights = groupHighlights(projectChanges)
+			if missing := missingReleaseNotes(highlightChanges); len(missing) > 0 {
+				if context.Bool("strict") {
+					return fmt.Errorf("highlighted pull requests missing release notes: %s", strings.Join(missing, ", "))
+				}
+				warnings.warn(warningReleaseNote, logrus.Fields{"pulls": strings.Join(missing, ", ")}, "Highlighted pull requests missing release notes, using pull request titles")
+			}
+			breaking := applyBreakingChanges(changes, r.CommitSha, r.BreakingChanges)
+			highlightChanges = append(highlightChanges, projectChange{Changes: breaking})
+			r.Highlights = applyHighlightOverrides(groupHighlights(highlightChanges, r.HighlightSections), highlightChanges, overrides)
+			r.Highlights = dedupeHighlights(r.Highlights, r.HighlightDuplicates)
 		}
 		if !highlights || !skipCommits {
 			r.Changes = projectChanges
 		}
 		r.Tag = tag
+		if context.Bool("preface-from-tag") {
+			if r.Preface, err = tagMessage(tag); err != nil {
+				return fmt.Errorf("failed to use tag message as preface: %w", err)
+			}
+		}
+		if sums := context.String("checksums"); sums != "" {
+			r.Downloads, err = readChecksums(sums)
+			if err != nil {
+				return fmt.Errorf("failed to read checksums: %w", err)
+			}
+			if r.GithubRepo != "" {
+				r.Attestations, err = getAttestations(r.GithubRepo, r.Downloads)
+				if err != nil {
+					warnings.warn(warningGithub, logrus.Fields{"repo": r.GithubRepo}, fmt.Sprintf("Failed to get attestations: %v", err))
+				}
+			}
+		}
+		r.Audience = context.String("audience")
 		r.Version = version
+		r.Provenance.ToolVersion = toolVersion()
+		r.GeneratedAt, err = generationTime(r.CommitSha)
+		if err != nil {
+			return err
+		}
+		r.Provenance.GeneratedAt = r.GeneratedAt
+
+		if context.Bool("previous-notes") && r.Previous != "" {
+			info, err := getReleaseInfo(r.GithubRepo, r.Previous, cache)
+			if err != nil {
+				return fmt.Errorf("failed to get previous release notes: %w", err)
+			}
+			r.PreviousNotes = info.Body
+			r.PreviousDependencies = parseNotesDependencies(info.Body)
+		}
 
 		// Log warnings at end for higher visibility
-		for o, n := range replacedDeps {
-			logrus.WithFields(logrus.Fields{"old": o, "new": n}).Warn("Dependency replace found, consider removing before tagged release")
+		for _, m := range unexpectedReplaces {
+			warnings.warn(warningReplace, logrus.Fields{"old": m.Old, "new": m.New}, "Dependency replace found, consider removing before tagged release")
+		}
+
+		r.cache = cache
+		if r.Preface, err = renderText("preface", r.Preface, r); err != nil {
+			return fmt.Errorf("failed to expand preface: %w", err)
+		}
+		if r.Postface, err = renderText("postface", r.Postface, r); err != nil {
+			return fmt.Errorf("failed to expand postface: %w", err)
 		}
 
 		// Remove trailing new lines
 		r.Preface = strings.TrimRightFunc(r.Preface, unicode.IsSpace)
 		r.Postface = strings.TrimRightFunc(r.Postface, unicode.IsSpace)
+		for i := range r.Sections {
+			r.Sections[i].Body = strings.TrimRightFunc(r.Sections[i].Body, unicode.IsSpace)
+		}
+
+		// complete removes the run progress once the output is written or
+		// the release published, a failure before keeps it for --resume
+		complete := func() {
+			if progress != nil {
+				if err := progress.complete(); err != nil {
+					logrus.WithError(err).Debug("unable to remove run progress")
+				}
+			}
+		}
+
+		if context.String("format") == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+			complete()
+			return nil
+		}
 
 		tmpl, err := getTemplate(context)
 		if err != nil {
 			return err
 		}
+		if r.Maintenance && tmpl == releaseNotes {
+			tmpl = maintenanceNotes
+		}
 
-		if context.Bool("dry") {
-			t, err := template.New("release-notes").Parse(tmpl)
+		if blog := context.String("blog"); blog != "" {
+			if err := writeBlogPost(blog, r); err != nil {
+				return fmt.Errorf("failed to write blog post: %w", err)
+			}
+			logrus.Infof("Wrote blog post to %s", blog)
+		}
+
+		render := func() (string, error) {
+			var b bytes.Buffer
+			if err := renderTemplate(&b, tmpl, r); err != nil {
+				return "", err
+			}
+			if context.Bool("reference-links") {
+				return referenceLinks(b.String()), nil
+			}
+			return b.String(), nil
+		}
+		rendered, trimmed, err := renderWithinBudget(r, context.Int("max-bytes"), render)
+		if err != nil {
+			return err
+		}
+		for _, name := range trimmed {
+			warnings.warn(warningSize, logrus.Fields{"max_bytes": context.Int("max-bytes")}, "Collapsed "+name+" to fit the notes size")
+		}
+		if limit := context.Int("max-bytes"); limit > 0 && len(rendered) > limit {
+			warnings.warn(warningSize, logrus.Fields{"bytes": len(rendered), "max_bytes": limit}, "Release notes are larger than the maximum size")
+		}
+		var notes bytes.Buffer
+		notes.WriteString(rendered)
+		if context.Bool("lint") {
+			dict, err := loadSpellDictionary(context.String("lint-dictionary"))
 			if err != nil {
-				return err
+				return fmt.Errorf("failed to load lint dictionary: %w", err)
+			}
+			issues := lintNotes(notes.String(), context.Int("lint-max-line"), dict)
+			for _, issue := range issues {
+				warnings.warn(warningLint, logrus.Fields{"line": issue.Line}, issue.Message)
+			}
+			if len(issues) > 0 && context.Bool("strict") {
+				return fmt.Errorf("found %d lint issues in the release notes", len(issues))
+			}
+		}
+		if context.Bool("check-links") {
+			broken := checkLinks(notes.String(), context.Int("check-links-concurrency"), unpublishedLinks(r), cache)
+			for _, link := range broken {
+				warnings.warn(warningLink, logrus.Fields{"url": link.URL}, "Broken link: "+link.Reason)
+			}
+			if len(broken) > 0 && context.Bool("strict") {
+				return fmt.Errorf("found %d broken links in the release notes", len(broken))
 			}
+		}
 
-			w := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
-			if err := t.Execute(w, r); err != nil {
+		if context.Bool("dry") {
+			if _, err := notes.WriteTo(os.Stdout); err != nil {
 				return err
 			}
-			return w.Flush()
+			complete()
+			writeTodos(os.Stderr, releaseTodos(r, warnings))
+			return nil
+		}
+		if err := publishRelease(r, notes.String()); err != nil {
+			return err
+		}
+		if err := announceRelease(r); err != nil {
+			return fmt.Errorf("failed to announce release: %w", err)
 		}
+		complete()
 		logrus.Info("release complete!")
 		return nil
 	}
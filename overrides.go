@@ -0,0 +1,182 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// highlightOverride gives editorial control over a single highlighted
+// change, matched by pull request number or commit.
+type highlightOverride struct {
+	// PR is the pull request number of the change
+	PR int64 `toml:"pr"`
+	// Commit is the commit of the change, abbreviated or full
+	Commit string `toml:"commit"`
+	// Project is the name of the dependency the change is from, empty for
+	// changes to the project itself
+	Project string `toml:"project"`
+
+	// Category moves the change into the named highlight category, the
+	// category is added when it does not exist
+	Category string `toml:"category"`
+	// Exclude removes the change from the highlights
+	Exclude bool `toml:"exclude"`
+	// Title replaces the display text of the change
+	Title string `toml:"title"`
+	// Order positions the change within its category, lower values first.
+	// Changes without an override have order 0.
+	Order int `toml:"order"`
+}
+
+type highlightOverrides struct {
+	Overrides []highlightOverride `toml:"override"`
+}
+
+// loadHighlightOverrides reads the highlight overrides from a TOML file
+func loadHighlightOverrides(path string) ([]highlightOverride, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ho highlightOverrides
+	if err := toml.Unmarshal(b, &ho); err != nil {
+		return nil, fmt.Errorf("unable to parse highlight overrides %s: %w", path, err)
+	}
+	for i, o := range ho.Overrides {
+		if o.PR == 0 && o.Commit == "" {
+			return nil, fmt.Errorf("highlight override %d must set pr or commit", i+1)
+		}
+	}
+	return ho.Overrides, nil
+}
+
+func (o *highlightOverride) matches(project string, c *change) bool {
+	if o.Project != project {
+		return false
+	}
+	if o.PR != 0 {
+		return c.PullRequest == o.PR
+	}
+	return c.Commit != "" && (strings.HasPrefix(o.Commit, c.Commit) || strings.HasPrefix(c.Commit, o.Commit))
+}
+
+// applyHighlightOverrides applies the overrides to the grouped highlights.
+// Overrides are matched against all changes so that changes without a
+// highlight label can be forced into a category.
+func applyHighlightOverrides(highlights []highlightCategory, changes []projectChange, overrides []highlightOverride) []highlightCategory {
+	if len(overrides) == 0 {
+		return highlights
+	}
+	var (
+		matched = map[*change]*highlightOverride{}
+		used    = make([]bool, len(overrides))
+		moved   []highlightChange
+	)
+	for _, project := range changes {
+		for _, c := range project.Changes {
+			for i := range overrides {
+				if !overrides[i].matches(project.Name, c) {
+					continue
+				}
+				matched[c] = &overrides[i]
+				used[i] = true
+				if overrides[i].Category != "" && !overrides[i].Exclude {
+					moved = append(moved, getHighlightChange(project.Name, c))
+				}
+				break
+			}
+		}
+	}
+	for i, o := range overrides {
+		if !used[i] {
+			warnings.warn(warningOverride, logrus.Fields{"pr": o.PR, "commit": o.Commit, "project": o.Project}, "Highlight override does not match any change")
+		}
+	}
+
+	result := make([]highlightCategory, 0, len(highlights)+len(moved))
+	for _, category := range highlights {
+		filtered := make([]highlightChange, 0, len(category.Changes))
+		for _, hc := range category.Changes {
+			if o, ok := matched[hc.Change]; ok && (o.Exclude || o.Category != "") {
+				continue
+			}
+			filtered = append(filtered, hc)
+		}
+		result = append(result, highlightCategory{
+			Name:    category.Name,
+			Changes: filtered,
+		})
+	}
+	for _, hc := range moved {
+		name := matched[hc.Change].Category
+		idx := -1
+		for i := range result {
+			if result[i].Name == name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			result = append(result, highlightCategory{Name: name})
+			idx = len(result) - 1
+		}
+		result[idx].Changes = append(result[idx].Changes, hc)
+	}
+
+	order := func(c *change) int {
+		if o, ok := matched[c]; ok {
+			return o.Order
+		}
+		return 0
+	}
+	highlights = result[:0]
+	for _, category := range result {
+		if len(category.Changes) == 0 {
+			continue
+		}
+		sort.SliceStable(category.Changes, func(i, j int) bool {
+			return order(category.Changes[i].Change) < order(category.Changes[j].Change)
+		})
+		for i, hc := range category.Changes {
+			if o, ok := matched[hc.Change]; ok && o.Title != "" {
+				category.Changes[i].Change = retitleChange(hc.Change, o.Title)
+			}
+		}
+		highlights = append(highlights, category)
+	}
+	return highlights
+}
+
+// retitleChange returns a copy of the change using the title as its display
+// text, leaving the change listed in the changelog unmodified
+func retitleChange(c *change, title string) *change {
+	retitled := *c
+	if c.Title != "" && strings.Contains(c.Formatted, c.Title) {
+		retitled.Formatted = strings.Replace(c.Formatted, c.Title, title, 1)
+	} else {
+		retitled.Formatted = title
+	}
+	retitled.Title = title
+	return &retitled
+}
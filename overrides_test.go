@@ -0,0 +1,51 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+func TestApplyHighlightOverrides(t *testing.T) {
+	changes := []projectChange{{Changes: []*change{
+		{Commit: "aaaaaaaaaaaa", Title: "Add snapshotter", Formatted: "Add snapshotter (#1)", PullRequest: 1, IsMerge: true, IsHighlight: true, Category: "Snapshotters"},
+		{Commit: "bbbbbbbbbbbb", Title: "Update runc", Formatted: "Update runc (#2)", PullRequest: 2, IsMerge: true, IsHighlight: true, Category: "Runtime"},
+		{Commit: "cccccccccccc", Title: "Faster startup", Formatted: "Faster startup (#3)", PullRequest: 3, IsMerge: true},
+		{Commit: "dddddddddddd", Title: "Fix shim leak", Formatted: "Fix shim leak (#4)", PullRequest: 4, IsMerge: true, IsHighlight: true, Category: "Runtime"},
+	}}}
+	overrides := []highlightOverride{
+		{PR: 1, Exclude: true},
+		{Commit: "cccccccccccc0123", Category: "Runtime", Title: "Much faster startup", Order: -1},
+		{PR: 4, Order: 1},
+		{PR: 99, Category: "Runtime"},
+	}
+
+	highlights := applyHighlightOverrides(groupHighlights(changes, nil), changes, overrides)
+	if len(highlights) != 1 || highlights[0].Name != "Runtime" {
+		t.Fatalf("unexpected highlights %v", highlights)
+	}
+	expected := []string{"Much faster startup (#3)", "Update runc (#2)", "Fix shim leak (#4)"}
+	if len(highlights[0].Changes) != len(expected) {
+		t.Fatalf("unexpected changes %v", highlights[0].Changes)
+	}
+	for i, formatted := range expected {
+		if f := highlights[0].Changes[i].Change.Formatted; f != formatted {
+			t.Errorf("[%d] unexpected change %q, expected %q", i, f, formatted)
+		}
+	}
+	if changes[0].Changes[2].Title != "Faster startup" {
+		t.Errorf("retitled change modified in changelog: %q", changes[0].Changes[2].Title)
+	}
+}
@@ -0,0 +1,220 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
+)
+
+var planCommand = &cli.Command{
+	Name:      "plan",
+	Usage:     "propose the release file for a GitHub milestone",
+	ArgsUsage: "<milestone>",
+	Description: `Writes a release file to start a release cycle from a GitHub milestone.
+The commit is the release branch of the milestone version when it exists, the
+previous release is the latest earlier version tag, the preface is drafted
+from the milestone description and each pinned issue seeds a section.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "github-repo",
+			Usage:    "github repository of the project",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "file to write the release file to instead of stdout",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "overwrite the output file when it already exists",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the milestone as the first argument")
+		}
+		output := context.String("output")
+		if err := checkOverwrite(output, context.Bool("force")); err != nil {
+			return err
+		}
+		repo := context.String("github-repo")
+		m, err := findMilestone(repo, context.Args().First())
+		if err != nil {
+			return err
+		}
+		issues, err := pinnedIssues(repo)
+		if err != nil {
+			return fmt.Errorf("failed to get pinned issues: %w", err)
+		}
+
+		version := tagVersion(m.Title)
+		commit := proposeCommit(version)
+		out, err := git("tag", "--merged", commit)
+		if err != nil {
+			return err
+		}
+		p := releasePlan{
+			Commit:      commit,
+			ProjectName: path.Base(repo),
+			GithubRepo:  repo,
+			Previous:    previousTag(strings.Fields(string(out)), version),
+			PreRelease:  version != "" && semver.Prerelease(version) != "",
+			Preface:     strings.TrimSpace(m.Description),
+		}
+		for _, issue := range issues {
+			p.Sections = append(p.Sections, planSection{
+				Title: issue.Title,
+				Body:  fmt.Sprintf("%s\n\nSee [#%d](%s).\n", strings.TrimSpace(issue.Body), issue.Number, issue.URL),
+			})
+		}
+
+		b, err := toml.Marshal(p)
+		if err != nil {
+			return err
+		}
+		b = append([]byte(fmt.Sprintf("# Proposed from the %q milestone of %s\n", m.Title, repo)), b...)
+		if output != "" {
+			return os.WriteFile(output, b, 0644)
+		}
+		_, err = os.Stdout.Write(b)
+		return err
+	},
+}
+
+// releasePlan is the release file proposed from a milestone
+type releasePlan struct {
+	Commit      string        `toml:"commit"`
+	ProjectName string        `toml:"project_name"`
+	GithubRepo  string        `toml:"github_repo"`
+	Previous    string        `toml:"previous,omitempty"`
+	PreRelease  bool          `toml:"pre_release"`
+	Preface     string        `toml:"preface,multiline"`
+	Sections    []planSection `toml:"sections,omitempty"`
+}
+
+type planSection struct {
+	Title string `toml:"title"`
+	Body  string `toml:"body,multiline"`
+}
+
+type milestone struct {
+	Number      int64  `json:"number"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// findMilestone returns the open or closed milestone with the title
+func findMilestone(repo, title string) (milestone, error) {
+	for page := 1; ; page++ {
+		u := fmt.Sprintf("https://api.github.com/repos/%s/milestones?state=all&per_page=%d&page=%d", repo, githubPageSize, page)
+		var milestones []milestone
+		if err := getGithubJSON(u, &milestones); err != nil {
+			return milestone{}, err
+		}
+		for _, m := range milestones {
+			if m.Title == title {
+				return m, nil
+			}
+		}
+		if len(milestones) < githubPageSize {
+			return milestone{}, fmt.Errorf("milestone %q not found in %s", title, repo)
+		}
+	}
+}
+
+type pinnedIssue struct {
+	Number int64  `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	URL    string `json:"url"`
+}
+
+// pinnedIssues returns the issues pinned in the repository
+func pinnedIssues(repo string) ([]pinnedIssue, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid github repository %q", repo)
+	}
+	var result struct {
+		Repository struct {
+			PinnedIssues struct {
+				Nodes []struct {
+					Issue pinnedIssue `json:"issue"`
+				} `json:"nodes"`
+			} `json:"pinnedIssues"`
+		} `json:"repository"`
+	}
+	query := `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    pinnedIssues(first: 3) { nodes { issue { number title body url } } }
+  }
+}`
+	if err := githubGraphQL(query, map[string]interface{}{"owner": owner, "name": name}, &result); err != nil {
+		return nil, err
+	}
+	var issues []pinnedIssue
+	for _, node := range result.Repository.PinnedIssues.Nodes {
+		issues = append(issues, node.Issue)
+	}
+	return issues, nil
+}
+
+// proposeCommit returns the release branch of the version, such as
+// release/1.7 for v1.7.3, when it exists and HEAD otherwise
+func proposeCommit(version string) string {
+	if version == "" {
+		return "HEAD"
+	}
+	branch := "release/" + strings.TrimPrefix(semver.MajorMinor(version), "v")
+	for _, ref := range []string{branch, "origin/" + branch} {
+		if _, err := git("rev-parse", "--verify", "--quiet", ref); err == nil {
+			return ref
+		}
+	}
+	return "HEAD"
+}
+
+// previousTag returns the latest version tag before the version, ignoring
+// pre-releases unless the version is a pre-release of the same version.
+// Without a version, the latest release tag is returned.
+func previousTag(tags []string, version string) string {
+	var previous, previousVersion string
+	for _, tag := range tags {
+		v := tagVersion(tag)
+		if v == "" || path.Base(tag) != tag {
+			continue
+		}
+		if pre := semver.Prerelease(v); pre != "" && (semver.Prerelease(version) == "" || strings.TrimSuffix(v, pre) != strings.TrimSuffix(version, semver.Prerelease(version))) {
+			continue
+		}
+		if version != "" && semver.Compare(v, version) >= 0 {
+			continue
+		}
+		if previousVersion == "" || semver.Compare(v, previousVersion) > 0 {
+			previous, previousVersion = tag, v
+		}
+	}
+	return previous
+}
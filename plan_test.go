@@ -0,0 +1,89 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/urfave/cli/v2"
+)
+
+func TestPreviousTag(t *testing.T) {
+	tags := []string{"v1.6.9", "v1.7.0-rc.1", "v1.7.0", "v1.7.1", "v1.8.0-beta.0", "v1.8.0-rc.1", "api/v1.8.0", "untagged"}
+	for _, tc := range []struct {
+		version  string
+		expected string
+	}{
+		{"v1.7.2", "v1.7.1"},
+		{"v1.8.0", "v1.7.1"},
+		{"v1.8.0-rc.2", "v1.8.0-rc.1"},
+		{"v1.7.0", "v1.6.9"},
+		{"", "v1.7.1"},
+	} {
+		if previous := previousTag(tags, tc.version); previous != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.version, tc.expected, previous)
+		}
+	}
+}
+
+func TestReleasePlanLoads(t *testing.T) {
+	b, err := toml.Marshal(releasePlan{
+		Commit:      "release/1.7",
+		ProjectName: "containerd",
+		GithubRepo:  "containerd/containerd",
+		Previous:    "v1.7.1",
+		Preface:     "The second patch release.\n\nIt fixes \"quoted\" issues.",
+		Sections: []planSection{
+			{Title: "Known issues", Body: "Some issue\n\nSee [#1](https://github.com/containerd/containerd/issues/1).\n"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "release.toml")
+	if err := os.WriteFile(p, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := loadRelease(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Commit != "release/1.7" || r.Previous != "v1.7.1" || r.Preface != "The second patch release.\n\nIt fixes \"quoted\" issues." {
+		t.Errorf("unexpected release %+v", r)
+	}
+	if len(r.Sections) != 1 || r.Sections[0].Title != "Known issues" {
+		t.Errorf("unexpected sections %+v", r.Sections)
+	}
+}
+
+func TestPlanCommandExistingOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "v1.7.2.toml")
+	if err := os.WriteFile(output, []byte("# edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := &cli.App{Commands: []*cli.Command{planCommand}}
+	err := app.Run([]string{"release-tool", "plan", "--github-repo", "containerd/containerd", "--output", output, "1.7.2"})
+	if expected := output + " already exists, use --force to overwrite it"; err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+	if b, err := os.ReadFile(output); err != nil || string(b) != "# edited\n" {
+		t.Errorf("expected the release file to be kept, got %q: %v", b, err)
+	}
+}
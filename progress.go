@@ -0,0 +1,146 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runProgress journals the cache keys stored by a run, so a run which is
+// interrupted while refreshing the cache can be resumed without fetching
+// the same data again
+type runProgress struct {
+	path string
+
+	mu   sync.Mutex
+	f    *os.File
+	done map[string]struct{}
+}
+
+// openProgress opens the progress journal for the release in the cache
+// directory. When resuming, the keys stored by the previous run are loaded,
+// otherwise any previous progress is discarded.
+func openProgress(cacheDir, tag, commit string, resume bool) (*runProgress, error) {
+	dir := filepath.Join(cacheDir, "progress")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	rp := &runProgress{
+		path: filepath.Join(dir, fmt.Sprintf("%s-%s", strings.ReplaceAll(tag, "/", "_"), commit)),
+		done: map[string]struct{}{},
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if resume {
+		if err := rp.load(); err != nil {
+			return nil, err
+		}
+		logrus.Infof("Resuming run with %d results from the interrupted run", len(rp.done))
+	} else {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(rp.path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	rp.f = f
+	return rp, nil
+}
+
+func (rp *runProgress) load() error {
+	f, err := os.Open(rp.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if key := s.Text(); key != "" {
+			rp.done[key] = struct{}{}
+		}
+	}
+	return s.Err()
+}
+
+func (rp *runProgress) has(key string) bool {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	_, ok := rp.done[key]
+	return ok
+}
+
+func (rp *runProgress) add(key string) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if _, ok := rp.done[key]; ok {
+		return
+	}
+	rp.done[key] = struct{}{}
+	if _, err := fmt.Fprintln(rp.f, key); err != nil {
+		logrus.WithError(err).Debug("unable to record progress")
+	}
+}
+
+// complete removes the journal once the run no longer needs to be resumed
+func (rp *runProgress) complete() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.f.Close()
+	return os.Remove(rp.path)
+}
+
+// resumingCache records the keys stored during the run and reads keys
+// stored by the interrupted run from the base cache, bypassing refresh
+type resumingCache struct {
+	Cache
+	base     Cache
+	progress *runProgress
+}
+
+func (rc *resumingCache) Get(key string) ([]byte, bool) {
+	if rc.progress.has(key) {
+		return rc.base.Get(key)
+	}
+	return rc.Cache.Get(key)
+}
+
+func (rc *resumingCache) Put(key string, value []byte) error {
+	if err := rc.Cache.Put(key, value); err != nil {
+		return err
+	}
+	rc.progress.add(key)
+	return nil
+}
+
+func (rc *resumingCache) Stale(key string) ([]byte, bool) {
+	if rc.progress.has(key) {
+		return rc.base.Get(key)
+	}
+	return staleValue(rc.Cache, key)
+}
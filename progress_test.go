@@ -0,0 +1,60 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResumingCache(t *testing.T) {
+	dir := t.TempDir()
+	base := &dirCache{root: t.TempDir()}
+	const (
+		fetched = "https://api.github.com/repos/containerd/containerd/pulls/1 title body labels"
+		pending = "https://api.github.com/repos/containerd/containerd/pulls/2 title body labels"
+	)
+	base.Put(pending, []byte("stale"))
+
+	// interrupted run refreshing pull requests
+	progress, err := openProgress(dir, "v1.0.0", "0123456789abcdef", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &resumingCache{Cache: refreshCache(base, "prs"), base: base, progress: progress}
+	cache.Put(fetched, []byte("fresh"))
+	progress.f.Close()
+
+	progress, err = openProgress(dir, "v1.0.0", "0123456789abcdef", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache = &resumingCache{Cache: refreshCache(base, "prs"), base: base, progress: progress}
+	if b, ok := cache.Get(fetched); !ok || string(b) != "fresh" {
+		t.Errorf("expected result of interrupted run, got %q", b)
+	}
+	if _, ok := cache.Get(pending); ok {
+		t.Error("expected pending key to be refreshed")
+	}
+
+	if err := progress.complete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(progress.path); !os.IsNotExist(err) {
+		t.Errorf("expected progress to be removed: %v", err)
+	}
+}
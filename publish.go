@@ -0,0 +1,185 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// createRelease creates the GitHub release for the tag with the rendered
+// release notes, returning the url of the release. The tag is created from
+// the commit when it has not been pushed.
+//
+// See https://docs.github.com/en/rest/releases/releases?apiVersion=2022-11-28#create-a-release
+func createRelease(repo, tag, commit, name, body string, prerelease bool) (string, error) {
+	var created struct {
+		URL string `json:"html_url"`
+	}
+	err := postGithubJSON(fmt.Sprintf("https://api.github.com/repos/%s/releases", repo), map[string]interface{}{
+		"tag_name":         tag,
+		"target_commitish": commit,
+		"name":             name,
+		"body":             body,
+		"prerelease":       prerelease,
+	}, &created)
+	if err != nil {
+		return "", err
+	}
+	return created.URL, nil
+}
+
+// publishRelease creates the GitHub release with the notes, along with the
+// announcement discussion and commit status when configured. Releases are
+// only published to GitHub.
+func publishRelease(r *release, notes string) error {
+	if r.GithubRepo == "" {
+		return fmt.Errorf("publishing is only supported for GitHub releases, set github_repo or use --dry to render the notes for %s", r.changeRepo())
+	}
+	link, err := createRelease(r.GithubRepo, r.Tag, r.CommitSha, fmt.Sprintf("%s %s", r.ProjectName, r.Version), notes, r.PreRelease)
+	if err != nil {
+		return fmt.Errorf("failed to create release: %w", err)
+	}
+	logrus.Infof("Created release %s", link)
+	if r.DiscussionCategory != "" {
+		u, err := createDiscussion(r.GithubRepo, r.DiscussionCategory, fmt.Sprintf("%s %s", r.ProjectName, r.Version), notes)
+		if err != nil {
+			return fmt.Errorf("failed to create discussion: %w", err)
+		}
+		logrus.Infof("Created release announcement discussion %s", u)
+	}
+	if r.CommitStatus != nil {
+		if err := createCommitStatus(r.GithubRepo, r.CommitSha, link, *r.CommitStatus); err != nil {
+			return fmt.Errorf("failed to create commit status: %w", err)
+		}
+		logrus.Infof("Marked %s as having release notes", r.CommitSha)
+	}
+	return nil
+}
+
+// createDiscussion creates a discussion in the repository category with the
+// rendered release notes, returning the url of the discussion
+//
+// See https://docs.github.com/en/graphql/reference/mutations#creatediscussion
+func createDiscussion(repo, category, title, body string) (string, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok {
+		return "", fmt.Errorf("invalid github repo %q", repo)
+	}
+	var info struct {
+		Repository struct {
+			ID                   string `json:"id"`
+			DiscussionCategories struct {
+				Nodes []struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"nodes"`
+			} `json:"discussionCategories"`
+		} `json:"repository"`
+	}
+	err := githubGraphQL(`query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    id
+    discussionCategories(first: 100) { nodes { id name } }
+  }
+}`, map[string]interface{}{"owner": owner, "name": name}, &info)
+	if err != nil {
+		return "", err
+	}
+	var categoryID string
+	for _, c := range info.Repository.DiscussionCategories.Nodes {
+		if strings.EqualFold(c.Name, category) {
+			categoryID = c.ID
+			break
+		}
+	}
+	if categoryID == "" {
+		return "", fmt.Errorf("discussion category %q not found in %s", category, repo)
+	}
+
+	var created struct {
+		CreateDiscussion struct {
+			Discussion struct {
+				URL string `json:"url"`
+			} `json:"discussion"`
+		} `json:"createDiscussion"`
+	}
+	err = githubGraphQL(`mutation($repo: ID!, $category: ID!, $title: String!, $body: String!) {
+  createDiscussion(input: {repositoryId: $repo, categoryId: $category, title: $title, body: $body}) {
+    discussion { url }
+  }
+}`, map[string]interface{}{
+		"repo":     info.Repository.ID,
+		"category": categoryID,
+		"title":    title,
+		"body":     body,
+	}, &created)
+	if err != nil {
+		return "", err
+	}
+	return created.CreateDiscussion.Discussion.URL, nil
+}
+
+// commitStatusConfig configures the commit status or check run created on
+// the release commit when publishing
+type commitStatusConfig struct {
+	// Kind is either "status" (the default) or "check", check runs can only
+	// be created with a GitHub App token
+	Kind string `toml:"kind" json:"kind"`
+
+	// Context is the name the status or check run is shown with,
+	// "release-notes" by default
+	Context string `toml:"context" json:"context"`
+}
+
+// createCommitStatus marks the release commit with a successful status or
+// check run linking to the published release notes
+//
+// See https://docs.github.com/en/rest/commits/statuses?apiVersion=2022-11-28#create-a-commit-status
+// and https://docs.github.com/en/rest/checks/runs?apiVersion=2022-11-28#create-a-check-run
+func createCommitStatus(repo, sha, link string, config commitStatusConfig) error {
+	name := config.Context
+	if name == "" {
+		name = "release-notes"
+	}
+	description := "Release notes generated"
+	switch config.Kind {
+	case "", "status":
+		return postGithubJSON(fmt.Sprintf("https://api.github.com/repos/%s/statuses/%s", repo, sha), map[string]interface{}{
+			"state":       "success",
+			"target_url":  link,
+			"description": description,
+			"context":     name,
+		}, nil)
+	case "check":
+		return postGithubJSON(fmt.Sprintf("https://api.github.com/repos/%s/check-runs", repo), map[string]interface{}{
+			"name":        name,
+			"head_sha":    sha,
+			"status":      "completed",
+			"conclusion":  "success",
+			"details_url": link,
+			"output": map[string]interface{}{
+				"title":   description,
+				"summary": fmt.Sprintf("Release notes: %s", link),
+			},
+		}, nil)
+	default:
+		return fmt.Errorf("unknown commit status kind %q", config.Kind)
+	}
+}
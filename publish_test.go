@@ -0,0 +1,142 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCreateRelease(t *testing.T) {
+	var request map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/repos/containerd/containerd/releases" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url": "https://github.com/containerd/containerd/releases/tag/v1.7.0-rc.1"}`))
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	u, err := createRelease("containerd/containerd", "v1.7.0-rc.1", "0123456789abcdef", "containerd 1.7.0-rc.1", "notes", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != "https://github.com/containerd/containerd/releases/tag/v1.7.0-rc.1" {
+		t.Errorf("unexpected release url %q", u)
+	}
+	if request["tag_name"] != "v1.7.0-rc.1" || request["target_commitish"] != "0123456789abcdef" || request["body"] != "notes" || request["prerelease"] != true {
+		t.Errorf("unexpected request %v", request)
+	}
+}
+
+func TestCreateDiscussion(t *testing.T) {
+	var created map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if r.Method != "POST" || r.URL.Path != "/graphql" || json.NewDecoder(r.Body).Decode(&request) != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.HasPrefix(request.Query, "mutation") {
+			created = request.Variables
+			w.Write([]byte(`{"data": {"createDiscussion": {"discussion": {"url": "https://github.com/containerd/containerd/discussions/42"}}}}`))
+			return
+		}
+		if request.Variables["owner"] != "containerd" || request.Variables["name"] != "containerd" {
+			w.Write([]byte(`{"errors": [{"message": "Could not resolve to a Repository"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"repository": {"id": "R_1", "discussionCategories": {"nodes": [{"id": "DC_1", "name": "General"}, {"id": "DC_2", "name": "Announcements"}]}}}}`))
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	for _, tc := range []struct {
+		repo     string
+		category string
+		url      string
+		err      string
+	}{
+		{"containerd/containerd", "announcements", "https://github.com/containerd/containerd/discussions/42", ""},
+		{"containerd/containerd", "Releases", "", `discussion category "Releases" not found in containerd/containerd`},
+		{"containerd/missing", "Announcements", "", "graphql error: Could not resolve to a Repository"},
+		{"containerd", "Announcements", "", `invalid github repo "containerd"`},
+	} {
+		created = nil
+		u, err := createDiscussion(tc.repo, tc.category, "containerd 1.7.0", "notes")
+		if tc.err == "" && err != nil {
+			t.Errorf("%s %s: unexpected error: %v", tc.repo, tc.category, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("%s %s: expected error %q, got %v", tc.repo, tc.category, tc.err, err)
+		}
+		if u != tc.url {
+			t.Errorf("%s %s: unexpected discussion url %q", tc.repo, tc.category, u)
+		}
+		if tc.url != "" && (created["repo"] != "R_1" || created["category"] != "DC_2" || created["title"] != "containerd 1.7.0" || created["body"] != "notes") {
+			t.Errorf("unexpected discussion %v", created)
+		} else if tc.url == "" && created != nil {
+			t.Errorf("%s %s: unexpected discussion %v", tc.repo, tc.category, created)
+		}
+	}
+}
+
+func TestPublishReleaseWithoutGithub(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+	defer func(client *http.Client) {
+		httpClient = client
+	}(httpClient)
+	httpClient = &http.Client{Transport: rewriteTransport{target}}
+
+	for _, tc := range []struct {
+		r   release
+		err string
+	}{
+		{release{GitlabRepo: "containerd/nerdbox"}, "publishing is only supported for GitHub releases, set github_repo or use --dry to render the notes for gitlab.com/containerd/nerdbox"},
+		{release{GiteaRepo: "codeberg.org/containerd/nerdbox"}, "publishing is only supported for GitHub releases, set github_repo or use --dry to render the notes for codeberg.org/containerd/nerdbox"},
+	} {
+		if err := publishRelease(&tc.r, "notes"); err == nil || err.Error() != tc.err {
+			t.Errorf("expected error %q, got %v", tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// typeSources are the files declaring the release data types, parsed for
+// the field documentation
+//
+//go:embed main.go announce.go artifacts.go attestations.go blog.go depupdate.go publish.go security.go
+var typeSources embed.FS
+
+var schemaCommand = &cli.Command{
+	Name:  "schema",
+	Usage: "print the release data available to templates",
+	Description: `Prints the data model passed to templates and read from fixtures, as a
+JSON Schema or as a commented example listing each field with its type.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "output format, json-schema or example",
+			Value: "json-schema",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		docs, err := typeDocs()
+		if err != nil {
+			return err
+		}
+		t := reflect.TypeOf(release{})
+		switch format := context.String("format"); format {
+		case "json-schema":
+			b, err := json.MarshalIndent(jsonSchema(t, docs), "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(os.Stdout, string(b))
+			return err
+		case "example":
+			writeExample(os.Stdout, t, docs, "", map[string]bool{})
+			return nil
+		default:
+			return fmt.Errorf("unknown schema format %q", format)
+		}
+	},
+}
+
+// typeDocs returns the doc comments of the struct types and their fields,
+// keyed by type name and by type and field name separated by a dot
+func typeDocs() (map[string]string, error) {
+	docs := map[string]string{}
+	entries, err := typeSources.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		b, err := typeSources.ReadFile(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, entry.Name(), b, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if text := docText(doc); text != "" {
+					docs[ts.Name.Name] = text
+				}
+				for _, field := range st.Fields.List {
+					text := docText(field.Doc)
+					if text == "" {
+						continue
+					}
+					for _, name := range field.Names {
+						docs[ts.Name.Name+"."+name.Name] = text
+					}
+				}
+			}
+		}
+	}
+	return docs, nil
+}
+
+func docText(cg *ast.CommentGroup) string {
+	return strings.Join(strings.Fields(cg.Text()), " ")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema returns the JSON Schema of the struct type, with the nested
+// struct types as definitions
+func jsonSchema(t reflect.Type, docs map[string]string) map[string]interface{} {
+	defs := map[string]interface{}{}
+	schema := structSchema(t, docs, defs)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = t.Name()
+	schema["$defs"] = defs
+	return schema
+}
+
+func structSchema(t reflect.Type, docs map[string]string, defs map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		prop := typeSchema(f.Type, docs, defs)
+		if doc := docs[t.Name()+"."+f.Name]; doc != "" {
+			prop["description"] = doc
+		}
+		properties[jsonName(f)] = prop
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if doc := docs[t.Name()]; doc != "" {
+		schema["description"] = doc
+	}
+	return schema
+}
+
+// jsonName returns the name of the field in JSON, from its json tag
+func jsonName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func typeSchema(t reflect.Type, docs map[string]string, defs map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), docs, defs)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), docs, defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), docs, defs)}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return structSchema(t, docs, defs)
+		}
+		if _, ok := defs[t.Name()]; !ok {
+			// Set before building to stop recursive types
+			defs[t.Name()] = nil
+			defs[t.Name()] = structSchema(t, docs, defs)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// writeExample writes each field of the struct type with its type and doc
+// comment, nested struct types are written indented below their field
+func writeExample(w io.Writer, t reflect.Type, docs map[string]string, indent string, seen map[string]bool) {
+	seen[t.Name()] = true
+	defer delete(seen, t.Name())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if doc := docs[t.Name()+"."+f.Name]; doc != "" {
+			fmt.Fprintf(w, "%s# %s\n", indent, doc)
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", indent, f.Name, strings.ReplaceAll(f.Type.String(), "main.", ""))
+
+		elem := f.Type
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Slice || elem.Kind() == reflect.Map {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct && elem != timeType && !seen[elem.Name()] {
+			writeExample(w, elem, docs, indent+"  ", seen)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	docs, err := typeDocs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := docs["change.PullRequest"]; doc != "PullRequest is the number of the pull request merging the change" {
+		t.Errorf("unexpected doc %q", doc)
+	}
+
+	schema := jsonSchema(reflect.TypeOf(release{}), docs)
+	defs := schema["$defs"].(map[string]interface{})
+	for _, name := range []string{"change", "projectChange", "contributor", "dependency"} {
+		if defs[name] == nil {
+			t.Errorf("missing definition for %s", name)
+		}
+	}
+	properties := schema["properties"].(map[string]interface{})
+	changes := properties["changes"].(map[string]interface{})
+	if ref := changes["items"].(map[string]interface{})["$ref"]; ref != "#/$defs/projectChange" {
+		t.Errorf("unexpected changes item reference %v", ref)
+	}
+	if date := properties["commit_date"].(map[string]interface{}); date["format"] != "date-time" {
+		t.Errorf("unexpected commit date schema %v", date)
+	}
+	if _, ok := properties["provenance"].(map[string]interface{})["$ref"]; !ok {
+		t.Errorf("expected provenance reference")
+	}
+}
+
+func TestTypeSourcesDeclareReleaseTypes(t *testing.T) {
+	declared := map[string]bool{}
+	entries, err := typeSources.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		b, err := typeSources.ReadFile(entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parser.ParseFile(fset, entry.Name(), b, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, obj := range f.Scope.Objects {
+			if obj.Kind == ast.Typ {
+				declared[name] = true
+			}
+		}
+	}
+
+	root := reflect.TypeOf(release{})
+	seen := map[reflect.Type]bool{}
+	var walk func(reflect.Type)
+	walk = func(typ reflect.Type) {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || typ.PkgPath() != root.PkgPath() || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		if !declared[typ.Name()] {
+			t.Errorf("%s is not declared in the embedded type sources", typ.Name())
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			walk(typ.Field(i).Type)
+		}
+	}
+	walk(root)
+
+	docs, err := typeDocs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docs["attestation.Predicates"] == "" {
+		t.Errorf("missing documentation for attestation.Predicates")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// advisoryConfig is a security advisory fixed by the release, with the tags
+// of each release branch fixing the advisory
+type advisoryConfig struct {
+	ID string `toml:"id" json:"id"`
+
+	// Patched maps release branches to the tag patching the advisory on
+	// the branch, the patched versions of the advisory are used otherwise
+	Patched map[string]string `toml:"patched" json:"patched"`
+}
+
+// advisoryVersions are the affected and patched versions of an advisory
+type advisoryVersions struct {
+	ID       string   `json:"id"`
+	Link     string   `json:"link"`
+	CVE      string   `json:"cve"`
+	Summary  string   `json:"summary"`
+	Affected []string `json:"affected"`
+	Patched  []string `json:"patched"`
+}
+
+// getAdvisoryVersions returns the affected versions of each advisory from
+// the GitHub advisory with the patched versions from the configuration
+func getAdvisoryVersions(advisories []advisoryConfig, repo string, cache Cache) ([]advisoryVersions, error) {
+	p := &githubChangeProcessor{repo: repo, cache: cache}
+	var versions []advisoryVersions
+	for _, a := range advisories {
+		if !strings.HasPrefix(a.ID, "GHSA-") {
+			return nil, fmt.Errorf("invalid advisory %q, expected a GitHub security advisory ID", a.ID)
+		}
+		info, err := p.getAdvisoryInfo(repo, a.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get advisory %s: %w", a.ID, err)
+		}
+		versions = append(versions, advisoryVersionsOf(a, repo, info))
+	}
+	return versions, nil
+}
+
+func advisoryVersionsOf(a advisoryConfig, repo string, info advisoryInfo) advisoryVersions {
+	av := advisoryVersions{
+		ID:      a.ID,
+		Link:    info.Link,
+		CVE:     info.CVE,
+		Summary: info.Summary,
+	}
+	if av.Link == "" {
+		av.Link = fmt.Sprintf("https://github.com/%s/security/advisories/%s", repo, a.ID)
+	}
+	for _, v := range info.Vulnerabilities {
+		if v.VulnerableVersions != "" {
+			av.Affected = append(av.Affected, v.VulnerableVersions)
+		}
+		if len(a.Patched) == 0 && v.PatchedVersions != "" {
+			av.Patched = append(av.Patched, v.PatchedVersions)
+		}
+	}
+	branches := make([]string, 0, len(a.Patched))
+	for branch := range a.Patched {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	for _, branch := range branches {
+		av.Patched = append(av.Patched, a.Patched[branch])
+	}
+	return av
+}
@@ -0,0 +1,49 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdvisoryVersions(t *testing.T) {
+	info := advisoryInfo{CVE: "CVE-2023-25153", Vulnerabilities: make([]advisoryVulnerability, 2)}
+	info.Vulnerabilities[0].VulnerableVersions = "< 1.5.18"
+	info.Vulnerabilities[0].PatchedVersions = "1.5.18"
+	info.Vulnerabilities[1].VulnerableVersions = ">= 1.6.0, < 1.6.18"
+	info.Vulnerabilities[1].PatchedVersions = "1.6.18"
+
+	av := advisoryVersionsOf(advisoryConfig{ID: "GHSA-259w-8hf6-59c2"}, "containerd/containerd", info)
+	if av.Link != "https://github.com/containerd/containerd/security/advisories/GHSA-259w-8hf6-59c2" {
+		t.Errorf("unexpected link %q", av.Link)
+	}
+	if affected := strings.Join(av.Affected, "; "); affected != "< 1.5.18; >= 1.6.0, < 1.6.18" {
+		t.Errorf("unexpected affected versions %q", affected)
+	}
+	if patched := strings.Join(av.Patched, ", "); patched != "1.5.18, 1.6.18" {
+		t.Errorf("unexpected patched versions from advisory %q", patched)
+	}
+
+	av = advisoryVersionsOf(advisoryConfig{
+		ID:      "GHSA-259w-8hf6-59c2",
+		Patched: map[string]string{"release/1.6": "v1.6.18", "release/1.5": "v1.5.18"},
+	}, "containerd/containerd", info)
+	if patched := strings.Join(av.Patched, ", "); patched != "v1.5.18, v1.6.18" {
+		t.Errorf("unexpected patched versions from config %q", patched)
+	}
+}
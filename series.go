@@ -0,0 +1,142 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+)
+
+var depsSeriesCommand = &cli.Command{
+	Name:      "deps-series",
+	Usage:     "show dependency changes across all releases between two tags",
+	ArgsUsage: "<from> <to>",
+	Description: `Produces a consolidated dependency change table between two releases
+which are not adjacent, listing the version in each intermediate release
+where the dependency changed.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "sub-path",
+			Usage: "subpath of the go module in the repository",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 2 {
+			return errors.New("please specify the from and to tags")
+		}
+		from, to := context.Args().Get(0), context.Args().Get(1)
+		tags, err := seriesTags(from, to)
+		if err != nil {
+			return err
+		}
+		cache, _, err := openCache(context.String("cache"))
+		if err != nil {
+			return err
+		}
+		series, err := getDependencySeries(tags, context.String("sub-path"), cache)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "### Dependency Changes from %s\n\n", strings.Join(tags, ", "))
+		if len(series) == 0 {
+			fmt.Fprintln(w, "There are no dependency changes")
+		}
+		for _, dep := range series {
+			fmt.Fprintf(w, "* **%s**\t%s\n", dep.Name, strings.Join(dep.Versions, " -> "))
+		}
+		return w.Flush()
+	},
+}
+
+// seriesTags returns the tags released between from and to in version
+// order, including from and to
+func seriesTags(from, to string) ([]string, error) {
+	out, err := git("tag", "--merged", to, "--no-merged", from, "--sort=v:refname")
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{from}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		if tag := strings.TrimSpace(s.Text()); tag != "" && tag != to {
+			tags = append(tags, tag)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return append(tags, to), nil
+}
+
+type dependencySeries struct {
+	Name string
+	// Versions are the versions of the dependency in order of the releases
+	// it changed in, starting with the version in the first release
+	Versions []string
+}
+
+// getDependencySeries returns the dependencies which changed across the
+// releases, sorted by name
+func getDependencySeries(refs []string, subpath string, cache Cache) ([]dependencySeries, error) {
+	versions := map[string][]string{}
+	for i, ref := range refs {
+		deps, err := parseDependencies(ref, subpath, nil, cache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dependencies for %s: %w", ref, err)
+		}
+		current := toDepMap(deps)
+		for name, dep := range current {
+			v, ok := versions[name]
+			if !ok && i > 0 {
+				v = []string{"_new_"}
+			}
+			if len(v) == 0 || v[len(v)-1] != dep.Ref {
+				versions[name] = append(v, dep.Ref)
+			}
+		}
+		for name, v := range versions {
+			if _, ok := current[name]; !ok && v[len(v)-1] != "_removed_" {
+				versions[name] = append(v, "_removed_")
+			}
+		}
+	}
+
+	var series []dependencySeries
+	for name, v := range versions {
+		if len(v) < 2 {
+			continue
+		}
+		series = append(series, dependencySeries{
+			Name:     name,
+			Versions: v,
+		})
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Name < series[j].Name
+	})
+	return series, nil
+}
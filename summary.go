@@ -0,0 +1,109 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var branchSummaryCommand = &cli.Command{
+	Name:      "branch-summary",
+	Usage:     "summarize the highlights and security fixes of all tags on a release branch",
+	ArgsUsage: "<branch>",
+	Description: `Walks all tags on a release branch matching a pattern and produces a
+cumulative document with the highlights and security fixes of each patch
+release. The first matching tag is used as the base of the summary.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "github-repo",
+			Usage:    "github repository of the project",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "match",
+			Usage: "glob pattern of the tags to include, such as \"v1.7.*\"",
+			Value: "v*",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if context.NArg() != 1 {
+			return errors.New("please specify the release branch as the first argument")
+		}
+		branch := context.Args().First()
+		cache, _, err := openCache(context.String("cache"))
+		if err != nil {
+			return err
+		}
+		out, err := git("tag", "--list", context.String("match"), "--merged", branch, "--sort=v:refname")
+		if err != nil {
+			return err
+		}
+		var tags []string
+		s := bufio.NewScanner(bytes.NewReader(out))
+		for s.Scan() {
+			if tag := strings.TrimSpace(s.Text()); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if err := s.Err(); err != nil {
+			return err
+		}
+		if len(tags) < 2 {
+			return fmt.Errorf("not enough tags on %s to summarize", branch)
+		}
+		return writeBranchSummary(os.Stdout, branch, tags, githubChange(context.String("github-repo"), "", cache))
+	},
+}
+
+// writeBranchSummary writes the highlights and security fixes of each tag
+// compared to the tag before it, starting with the latest
+func writeBranchSummary(w io.Writer, branch string, tags []string, p changeProcessor) error {
+	fmt.Fprintf(w, "# Summary of %s since %s\n", branch, tags[0])
+	for i := len(tags) - 1; i > 0; i-- {
+		logrus.Debugf("Summarizing %s..%s", tags[i-1], tags[i])
+		changes, err := changelog(tags[i-1], tags[i])
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if err := p.process(c); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(w, "\n## %s\n\n%d changes since %s\n", tags[i], len(changes), tags[i-1])
+		for _, category := range groupHighlights([]projectChange{{Changes: changes}}, nil) {
+			name := category.Name
+			if name == "" {
+				name = "Highlights"
+			}
+			fmt.Fprintf(w, "\n### %s\n\n", name)
+			for _, hc := range category.Changes {
+				fmt.Fprintf(w, "* %s\n", hc.Change.Formatted)
+			}
+		}
+	}
+	return nil
+}
@@ -16,6 +16,332 @@
 
 package main
 
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
+
+var templateFuncs = template.FuncMap{
+	"hasCategory": hasCategory,
+	"hasLabel":    hasLabel,
+	"forAudience": forAudience,
+
+	"pluralize":        pluralize,
+	"humanizeDuration": humanizeDuration,
+	"humanizeBytes":    humanizeBytes,
+	"commaSep":         commaSep,
+	"join":             strings.Join,
+
+	// pr is replaced by the pull requests of the rendered release
+	"pr": (*release)(nil).pullRequest,
+}
+
+// forAudience returns the changes relevant to the audience
+func forAudience(audience string, changes []highlightChange) []highlightChange {
+	var relevant []highlightChange
+	for _, c := range changes {
+		if c.Change.ForAudience(audience) {
+			relevant = append(relevant, c)
+		}
+	}
+	return relevant
+}
+
+// renderTemplate executes the release notes template for the release,
+// referencing a field or map key missing from the release is an error
+func renderTemplate(w io.Writer, tmpl string, r *release) error {
+	t, err := newReleaseTemplate("release-notes", r).Parse(tmpl)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 8, 8, 2, ' ', 0)
+	if err := t.Execute(tw, r); err != nil {
+		return templateDiagnostic(err)
+	}
+	return tw.Flush()
+}
+
+// renderText executes text of the release file, such as the preface, as a
+// template for the release
+func renderText(name, text string, r *release) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, err := newReleaseTemplate(name, r).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, r); err != nil {
+		return "", templateDiagnostic(err)
+	}
+	return b.String(), nil
+}
+
+// newReleaseTemplate returns a template with the functions bound to the
+// release
+func newReleaseTemplate(name string, r *release) *template.Template {
+	return template.New(name).Funcs(templateFuncs).Funcs(template.FuncMap{
+		"pr": r.pullRequest,
+	}).Option("missingkey=error")
+}
+
+var (
+	missingFieldRegexp = regexp.MustCompile(`^template: [^:]+:(\d+):\d+: executing "[^"]*" at <([^>]*)>: can't evaluate field (\w+) in type \*?(?:main\.)?(\S+)$`)
+	missingKeyRegexp   = regexp.MustCompile(`^template: [^:]+:(\d+):\d+: executing "[^"]*" at <([^>]*)>: map has no entry for key "(.*)"$`)
+)
+
+// templateDiagnostic rewrites errors from referencing a missing field or
+// map key with the line of the template and the closest known field
+func templateDiagnostic(err error) error {
+	if matches := missingFieldRegexp.FindStringSubmatch(err.Error()); matches != nil {
+		msg := fmt.Sprintf("template line %s: %s references unknown field %s of %s", matches[1], matches[2], matches[3], matches[4])
+		if suggestion := closestField(matches[4], matches[3]); suggestion != "" {
+			msg = fmt.Sprintf("%s, did you mean %s?", msg, suggestion)
+		}
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	if matches := missingKeyRegexp.FindStringSubmatch(err.Error()); matches != nil {
+		return fmt.Errorf("template line %s: %s references missing key %q, use index to allow missing keys: %w", matches[1], matches[2], matches[3], err)
+	}
+	return err
+}
+
+// closestField returns the field of the release data type most similar to
+// the unknown field, or an empty string when none is close
+func closestField(typeName, field string) string {
+	t := findType(reflect.TypeOf(release{}), typeName, map[reflect.Type]bool{})
+	if t == nil {
+		return ""
+	}
+	var (
+		closest string
+		best    = len(field)/4 + 1
+	)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if strings.EqualFold(f.Name, field) {
+			return f.Name
+		}
+		if d := editDistance(strings.ToLower(f.Name), strings.ToLower(field)); d <= best {
+			closest, best = f.Name, d-1
+		}
+	}
+	return closest
+}
+
+// findType returns the named struct type reachable from the type
+func findType(t reflect.Type, name string, seen map[reflect.Type]bool) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	if t.Name() == name {
+		return t
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		if found := findType(t.Field(i).Type, name, seen); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// editDistance returns the Levenshtein distance between the strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// pullRequestRef is a pull request referenced with the pr template
+// function, written as its title and link
+type pullRequestRef struct {
+	*change
+}
+
+func (p pullRequestRef) String() string {
+	return p.Formatted
+}
+
+// pullRequest returns the pull request of the release repository with its
+// title and link, fetched when referenced and cached like the changes
+func (r *release) pullRequest(number int64) (pullRequestRef, error) {
+	if r == nil {
+		return pullRequestRef{}, fmt.Errorf("pull request %d referenced without a release", number)
+	}
+	var cache Cache = nilCache{}
+	if r.cache != nil {
+		cache = r.cache
+	}
+	c := &change{}
+	switch p := changeProcessorFor(r.changeRepo(), "", cache).(type) {
+	case *githubChangeProcessor:
+		info, err := p.getPRInfo(p.repo, number)
+		if err != nil {
+			return pullRequestRef{}, err
+		}
+		p.prChange(c, info, number)
+	case *gitlabChangeProcessor:
+		info, err := p.getMRInfo(p.repo, number)
+		if err != nil {
+			return pullRequestRef{}, err
+		}
+		p.mrChange(c, info, number)
+	case *giteaChangeProcessor:
+		info, err := p.getPRInfo(p.repo, number)
+		if err != nil {
+			return pullRequestRef{}, err
+		}
+		p.prChange(c, info, number)
+	}
+	return pullRequestRef{c}, nil
+}
+
+var inlineLinkRegexp = regexp.MustCompile(`\[([^\]]*)\]\((https?://[^)\s]+)\)`)
+
+// referenceLinks converts the inline links in the markdown to numbered
+// reference-style links with the link definitions collected at the bottom
+func referenceLinks(md string) string {
+	var (
+		refs  = map[string]int{}
+		links []string
+	)
+	converted := inlineLinkRegexp.ReplaceAllStringFunc(md, func(link string) string {
+		matches := inlineLinkRegexp.FindStringSubmatch(link)
+		n, ok := refs[matches[2]]
+		if !ok {
+			links = append(links, matches[2])
+			n = len(links)
+			refs[matches[2]] = n
+		}
+		return fmt.Sprintf("[%s][%d]", matches[1], n)
+	})
+	if len(links) == 0 {
+		return md
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(converted, "\n"))
+	b.WriteString("\n\n")
+	for i, link := range links {
+		fmt.Fprintf(&b, "[%d]: %s\n", i+1, link)
+	}
+	return b.String()
+}
+
+// hasCategory returns whether the change has the given category
+func hasCategory(c *change, category string) bool {
+	for _, cat := range c.CategoryList {
+		if cat == category {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLabel returns whether the change has the given label
+func hasLabel(c *change, label string) bool {
+	for _, l := range c.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// pluralize returns the count with the singular or plural form of the noun,
+// the plural defaults to the singular with an "s" suffix
+func pluralize(count int, singular string, plural ...string) string {
+	if count == 1 {
+		return "1 " + singular
+	}
+	p := singular + "s"
+	if len(plural) > 0 {
+		p = plural[0]
+	}
+	return commaSep(count) + " " + p
+}
+
+// humanizeDuration returns the duration in the largest whole unit of days,
+// hours or minutes, such as "47 days"
+func humanizeDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return pluralize(int(d/(24*time.Hour)), "day")
+	case d >= time.Hour:
+		return pluralize(int(d/time.Hour), "hour")
+	default:
+		return pluralize(int(d/time.Minute), "minute")
+	}
+}
+
+// humanizeBytes returns the size in the largest binary unit, such as
+// "45.2 MiB"
+func humanizeBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, unit := float64(n)/1024, "KiB"
+	for _, next := range []string{"MiB", "GiB", "TiB"} {
+		if value < 1024 {
+			break
+		}
+		value, unit = value/1024, next
+	}
+	return fmt.Sprintf("%.1f %s", value, unit)
+}
+
+// commaSep formats the number with comma separated thousands
+func commaSep(n int) string {
+	if n < 0 {
+		return "-" + commaSep(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
 const (
 	defaultTemplateFile = "TEMPLATE"
 	releaseNotes        = `{{.ProjectName}} {{.Version}}
@@ -26,22 +352,59 @@ Welcome to the {{.Tag}} release of {{.ProjectName}}!
 {{- end}}
 
 {{.Preface}}
+{{- template "sections" .SectionsAt "after-preface"}}
+
+{{- if .LicenseChanges}}
+
+### License changes
+
+The license files of {{.ProjectName}} changed in this release, review the
+changes before redistributing
+{{range $license := .LicenseChanges}}
+* {{$license.File}} {{$license.Status}}
+{{- end}}
+{{- end}}
+
+{{- if .AdvisoryVersions}}
+
+### Security advisories
+
+| Advisory | Affected versions | Patched versions |
+| --- | --- | --- |
+{{- range $advisory := .AdvisoryVersions}}
+| [{{$advisory.ID}}]({{$advisory.Link}}){{if $advisory.CVE}} ({{$advisory.CVE}}){{end}}{{if $advisory.Summary}}: {{$advisory.Summary}}{{end}} | {{join $advisory.Affected "<br>"}} | {{join $advisory.Patched ", "}} |
+{{- end}}
+{{- end}}
 
 {{- if .Highlights}}
 
 ### Highlights
 {{- range $highlight := .Highlights}}
+{{- with forAudience $.Audience $highlight.Changes}}
 
 {{- if $highlight.Name}}
 
 #### {{$highlight.Name}}
 {{- end}}
-{{ range $change := $highlight.Changes}}
-* {{ $change.Change.Formatted }}
+{{ range $change := .}}
+* {{if $change.Project}}{{$change.Project}}: {{end}}{{ $change.Change.Formatted }}
+{{- end}}
 {{- end}}
 {{- end}}
 {{- end}}
 
+{{- if .UpgradeNotes}}
+
+### Upgrade notes
+{{- range $note := .UpgradeNotes}}
+
+#### {{if $note.Project}}{{$note.Project}}: {{end}}[{{$note.Change.Title}}]({{$note.Change.Link}})
+
+{{$note.Change.UpgradeNote}}
+{{- end}}
+{{- end}}
+{{- template "sections" .SectionsAt "after-highlights"}}
+
 Please try out the release binaries and report any issues at
 https://github.com/{{.GithubRepo}}/issues.
 
@@ -51,30 +414,172 @@ https://github.com/{{.GithubRepo}}/issues.
 
 {{$note.Description}}
 {{- end}}
+{{- template "sections" .SectionsAt "after-notes"}}
 
 ### Contributors
 {{range $contributor := .Contributors}}
 * {{$contributor.Name}}
-{{- end -}}
+{{- end}}
+{{- template "sections" .SectionsAt "after-contributors"}}
 
-{{range $project := .Changes}}
+{{- range $project := .Changes}}
 
 ### Changes{{if $project.Name}} from {{$project.Name}}{{end}}
-<details><summary>{{len $project.Changes}} commit{{if gt (len $project.Changes) 1}}s{{end}}</summary>
+<details><summary>{{$project.Total}} commit{{if gt $project.Total 1}}s{{end}}</summary>
 <p>
 {{range $change := $project.Changes }}
 {{- if ne $change.Formatted "" }}
-{{if not $change.IsMerge}}  {{end}}* {{$change.Formatted}}
+{{if not $change.IsMerge}}  {{end}}* {{if $.AreaTags}}{{range $area := $change.Areas}}[{{$area}}] {{end}}{{end}}{{$change.Formatted}}
 {{- end}}
 {{- end}}
+{{- if $project.Truncated}}
+* ... and {{pluralize $project.Truncated "more commit"}}{{if $project.CompareLink}}, see the [full comparison]({{$project.CompareLink}}){{end}}
+{{- end}}
 </p>
 </details>
 {{- end}}
+{{- template "sections" .SectionsAt "after-changes"}}
+
+### Dependency Changes
+{{if .Dependencies}}
+{{- range $dep := .Dependencies}}
+* **{{$dep.Name}}**	{{if $dep.New}}{{$dep.Ref}} **_new_**{{else}}{{$dep.Previous}} -> {{$dep.Ref}}{{end}}{{if $dep.Unresolved}} _(unresolved)_{{end}}{{if $dep.Usage}} _({{$dep.Usage}})_{{end}}
+{{- if $dep.ReleaseNotesLink}} ([release notes]({{$dep.ReleaseNotesLink}}){{if $dep.CompareLink}}, [changes]({{$dep.CompareLink}}){{end}})
+{{- else if $dep.CompareLink}} ([changes]({{$dep.CompareLink}})){{end}}
+{{- end}}
+{{- else}}
+This release has no dependency changes
+{{- end}}
+
+{{- if .Replaced}}
+
+#### Replaced modules
+{{range $module := .Replaced}}
+* **{{$module.Old}}** => {{$module.New}}{{if $module.Version}} {{$module.Version}}{{end}}
+{{- end}}
+{{- end}}
+
+{{- range $comparison := .Comparisons}}
+
+#### Compared to {{$comparison.Previous}}
+{{- if $comparison.Highlights}}
+
+Highlights
+{{- range $highlight := $comparison.Highlights}}
+{{- range $change := $highlight.Changes}}
+* {{$change.Change.Title}}{{if $highlight.Name}} _({{$highlight.Name}})_{{end}}
+{{- end}}
+{{- end}}
+{{- end}}
+
+{{if $comparison.Dependencies}}Dependency changes
+{{- range $dep := $comparison.Dependencies}}
+* **{{$dep.Name}}**	{{if $dep.New}}{{$dep.Ref}} **_new_**{{else}}{{$dep.Previous}} -> {{$dep.Ref}}{{end}}{{if $dep.Unresolved}} _(unresolved)_{{end}}
+{{- end}}
+{{- else}}No dependency changes{{end}}
+{{- end}}
+{{- template "sections" .SectionsAt "after-dependencies"}}
+
+{{- if .Retractions}}
+
+### Retracted versions
+
+This release retracts the following versions of {{.ProjectName}}
+{{range $retraction := .Retractions}}
+* {{$retraction.Versions}}{{if $retraction.Rationale}}: {{$retraction.Rationale}}{{end}}
+{{- end}}
+{{- end}}
+
+{{- if or .Build.GoVersion .Build.CGOStatus .Build.Flags}}
+
+### Build information
+{{with .Build}}{{if .GoVersion}}
+* Go version: {{.GoVersion}}{{end}}{{if .CGOStatus}}
+* CGO: {{.CGOStatus}}{{end}}{{if .Flags}}
+* Build flags: {{range $i, $flag := .Flags}}{{if $i}} {{end}}` + "`{{$flag}}`" + `{{end}}{{end}}{{end}}
+{{- end}}
+
+{{- if .ArtifactSizes}}
+
+### Binary sizes
+
+| Platform | {{.Previous}} | {{.Tag}} | Change |
+| --- | --- | --- | --- |
+{{- range $artifact := .ArtifactSizes}}
+| {{$artifact.Platform}} | {{humanizeBytes $artifact.Previous}} | {{humanizeBytes $artifact.Current}} | {{$artifact.Change}} |
+{{- end}}
+{{- end}}
+
+{{- if .Downloads}}
+
+### Downloads
+
+| File | SHA256 |
+| --- | --- |
+{{- range $download := .Downloads}}
+| [{{$download.Filename}}](https://github.com/{{$.GithubRepo}}/releases/download/{{$.Tag}}/{{$download.Filename}}) | ` + "`{{$download.Hash}}`" + ` |
+{{- end}}
+{{- end}}
+
+{{- if .Attestations}}
+
+### Attestations
+
+The following downloads have [attestations](https://github.com/{{.GithubRepo}}/attestations) which can be verified with the [GitHub CLI](https://cli.github.com/):
+
+` + "```" + `
+gh attestation verify <file> --repo {{.GithubRepo}}
+` + "```" + `
+
+| File | Attestations |
+| --- | --- |
+{{- range $attestation := .Attestations}}
+| {{$attestation.Filename}} | {{join $attestation.Predicates ", "}} |
+{{- end}}
+{{- end}}
+
+{{- if .ReleaseManagers}}
+
+Release managed by {{join .ReleaseManagers ", "}}
+{{- if .Approvers}} and approved by {{join .Approvers ", "}}{{end}}
+{{- end}}
+
+{{- if .Previous}}
+
+Previous release can be found at [{{.Previous}}](https://github.com/{{.GithubRepo}}/releases/tag/{{.Previous}})
+{{- end}}
+{{- template "sections" .SectionsAt "end"}}
+{{.Postface}}
+{{- with .Provenance}}
+<!-- generated by release-tool {{.ToolVersion}} at {{.GeneratedAt.Format "2006-01-02T15:04:05Z07:00"}} from config sha256:{{.ConfigHash}} for {{$.Commit}} ({{$.CommitSha}}){{if $.Previous}} since {{$.Previous}} ({{$.PreviousSha}}){{end}} -->
+{{- end}}
+{{define "sections"}}
+{{- range $section := .}}
+
+### {{$section.Title}}
+
+{{$section.Body}}
+{{- end}}
+{{- end}}`
+
+	// maintenanceNotes is used in place of the default template for
+	// releases without changes other than from bots or excluded
+	// contributors
+	maintenanceNotes = `{{.ProjectName}} {{.Version}}
+
+Welcome to the {{.Tag}} maintenance release of {{.ProjectName}}!
+{{- if .Previous}} This release has no changes since
+{{.Previous}} other than automated updates.
+{{- end}}
+{{- if .Preface}}
+
+{{.Preface}}
+{{- end}}
 
 ### Dependency Changes
 {{if .Dependencies}}
 {{- range $dep := .Dependencies}}
-* **{{$dep.Name}}**	{{if $dep.New}}{{$dep.Ref}} **_new_**{{else}}{{$dep.Previous}} -> {{$dep.Ref}}{{end}}
+* **{{$dep.Name}}**	{{if $dep.New}}{{$dep.Ref}} **_new_**{{else}}{{$dep.Previous}} -> {{$dep.Ref}}{{end}}{{if $dep.Unresolved}} _(unresolved)_{{end}}
 {{- end}}
 {{- else}}
 This release has no dependency changes
@@ -85,5 +590,8 @@ This release has no dependency changes
 Previous release can be found at [{{.Previous}}](https://github.com/{{.GithubRepo}}/releases/tag/{{.Previous}})
 {{- end}}
 {{.Postface}}
+{{- with .Provenance}}
+<!-- generated by release-tool {{.ToolVersion}} at {{.GeneratedAt.Format "2006-01-02T15:04:05Z07:00"}} from config sha256:{{.ConfigHash}} for {{$.Commit}} ({{$.CommitSha}}){{if $.Previous}} since {{$.Previous}} ({{$.PreviousSha}}){{end}} -->
+{{- end}}
 `
 )
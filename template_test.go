@@ -0,0 +1,360 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanize(t *testing.T) {
+	for _, tc := range []struct {
+		actual   string
+		expected string
+	}{
+		{pluralize(1, "commit"), "1 commit"},
+		{pluralize(312, "commit"), "312 commits"},
+		{pluralize(0, "commit"), "0 commits"},
+		{pluralize(2, "dependency", "dependencies"), "2 dependencies"},
+		{pluralize(1234, "change"), "1,234 changes"},
+		{humanizeDuration(47*24*time.Hour + 3*time.Hour), "47 days"},
+		{humanizeDuration(25 * time.Hour), "1 day"},
+		{humanizeDuration(5 * time.Hour), "5 hours"},
+		{humanizeDuration(90 * time.Second), "1 minute"},
+		{commaSep(0), "0"},
+		{commaSep(999), "999"},
+		{commaSep(1000), "1,000"},
+		{commaSep(1234567), "1,234,567"},
+		{commaSep(-12345), "-12,345"},
+		{humanizeBytes(512), "512 B"},
+		{humanizeBytes(47395635), "45.2 MiB"},
+		{humanizeBytes(3 << 30), "3.0 GiB"},
+		{artifactSize{Previous: 1000, Current: 1100}.Change(), "+100 B (+10.0%)"},
+		{artifactSize{Previous: 2 << 20, Current: 1 << 20}.Change(), "-1.0 MiB (-50.0%)"},
+	} {
+		if tc.actual != tc.expected {
+			t.Errorf("unexpected %q, expected %q", tc.actual, tc.expected)
+		}
+	}
+}
+
+func TestReferenceLinks(t *testing.T) {
+	md := "* Fix leak ([#1](https://github.com/containerd/containerd/pull/1))\n" +
+		"* [`abc123`](https://github.com/containerd/containerd/commit/abc123) Update docs\n" +
+		"* Again ([#1](https://github.com/containerd/containerd/pull/1))\n"
+	expected := "* Fix leak ([#1][1])\n" +
+		"* [`abc123`][2] Update docs\n" +
+		"* Again ([#1][1])\n" +
+		"\n" +
+		"[1]: https://github.com/containerd/containerd/pull/1\n" +
+		"[2]: https://github.com/containerd/containerd/commit/abc123\n"
+	if actual := referenceLinks(md); actual != expected {
+		t.Fatalf("unexpected output:\n%s\nexpected:\n%s", actual, expected)
+	}
+	if actual := referenceLinks("no links\n"); actual != "no links\n" {
+		t.Fatalf("unexpected output %q", actual)
+	}
+}
+
+func TestReleaseType(t *testing.T) {
+	for _, tc := range []struct {
+		tag, previous string
+		expected      string
+		rc            bool
+	}{
+		{"v1.7.1", "v1.7.0", "patch", false},
+		{"v1.7.0", "v1.6.20", "minor", false},
+		{"v2.0.0", "v1.7.5", "major", false},
+		{"v2.0.0-rc.2", "v2.0.0-rc.1", "major", true},
+		{"v1.7.0-rc.0", "v1.6.20", "minor", true},
+		{"api/v1.2.3", "api/v1.2.2", "patch", false},
+		{"v1.7.0", "0123456789ab", "minor", false},
+		{"latest", "v1.7.0", "", false},
+	} {
+		r := &release{Tag: tc.tag, Previous: tc.previous}
+		if actual := r.releaseType(); actual != tc.expected || r.IsRC() != tc.rc {
+			t.Errorf("[%s...%s] unexpected release type %q (rc %t), expected %q (rc %t)", tc.previous, tc.tag, actual, r.IsRC(), tc.expected, tc.rc)
+		}
+	}
+
+	var b strings.Builder
+	r := &release{Tag: "v1.7.1", Previous: "v1.7.0"}
+	if err := renderTemplate(&b, "{{if .IsPatch}}patch{{else if .IsMinor}}minor{{end}}", r); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "patch" {
+		t.Errorf("unexpected render %q", b.String())
+	}
+}
+
+func TestForAudience(t *testing.T) {
+	changes := []highlightChange{
+		{Change: &change{Title: "Everyone"}},
+		{Change: &change{Title: "Operators", Audiences: []string{"operator"}}},
+		{Change: &change{Title: "Developers", Audiences: []string{"developer"}}},
+	}
+	for _, tc := range []struct {
+		audience string
+		expected int
+	}{
+		{"", 3},
+		{"operator", 2},
+		{"developer", 2},
+		{"user", 1},
+	} {
+		if relevant := forAudience(tc.audience, changes); len(relevant) != tc.expected {
+			t.Errorf("[%s] unexpected changes %v, expected %d", tc.audience, relevant, tc.expected)
+		}
+	}
+}
+
+func TestPullRequestTemplateFunc(t *testing.T) {
+	cache := &dirCache{root: t.TempDir()}
+	_, key := prInfoKey("containerd/containerd", 1234)
+	if err := cache.Put(key, []byte(`{"title":"[release/1.7] Fix shim cleanup"}`)); err != nil {
+		t.Fatal(err)
+	}
+	r := &release{GithubRepo: "containerd/containerd", Version: "v1.7.1", cache: cache}
+
+	for _, tc := range []struct {
+		text     string
+		expected string
+	}{
+		{
+			"Fixes a leak, see {{pr 1234}} and [the change]({{(pr 1234).Link}}).",
+			"Fixes a leak, see Fix shim cleanup ([#1234](https://github.com/containerd/containerd/pull/1234)) and [the change](https://github.com/containerd/containerd/pull/1234).",
+		},
+		{"{{.Version}} fixes {{(pr 1234).Title}}", "v1.7.1 fixes Fix shim cleanup"},
+		{"No references, {braces} are kept", "No references, {braces} are kept"},
+		{"Configure the `{{\"{{\"}}.Name}}` template", "Configure the `{{.Name}}` template"},
+	} {
+		expanded, err := renderText("preface", tc.text, r)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.text, err)
+		}
+		if expanded != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, expanded)
+		}
+	}
+
+	var b strings.Builder
+	if err := renderTemplate(&b, "See {{(pr 1234).Link}}", r); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "See https://github.com/containerd/containerd/pull/1234" {
+		t.Errorf("unexpected notes %q", b.String())
+	}
+
+	if _, err := renderText("preface", "See {{pr}}", r); err == nil {
+		t.Error("expected error for a reference without a number")
+	}
+}
+
+func TestTemplateDiagnostic(t *testing.T) {
+	for _, tc := range []struct {
+		tmpl     string
+		expected string
+	}{
+		{"{{.ProjectName}}\n{{.Versoin}}", "template line 2: .Versoin references unknown field Versoin of release, did you mean Version?"},
+		{"{{range .Contributors}}{{.Nmae}}{{end}}", "did you mean Name?"},
+		{"{{.Unrelated}}", "template line 1: .Unrelated references unknown field Unrelated of release:"},
+		{`{{.Notes.missing}}`, `template line 1: .Notes.missing references missing key "missing"`},
+	} {
+		r := &release{
+			Contributors: []contributor{{Name: "Jane"}},
+			Notes:        map[string]note{},
+		}
+		var b strings.Builder
+		err := renderTemplate(&b, tc.tmpl, r)
+		if err == nil {
+			t.Errorf("expected error rendering %q", tc.tmpl)
+		} else if !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("expected %q in error, got %q", tc.expected, err)
+		}
+	}
+}
+
+func TestMaintenanceNotes(t *testing.T) {
+	r := &release{
+		ProjectName:  "containerd",
+		Version:      "1.7.1",
+		Tag:          "v1.7.1",
+		Previous:     "v1.7.0",
+		GithubRepo:   "containerd/containerd",
+		Dependencies: []dependency{{Name: "golang.org/x/net", Previous: "v0.7.0", Ref: "v0.8.0"}},
+		Maintenance:  true,
+	}
+	var b strings.Builder
+	if err := renderTemplate(&b, maintenanceNotes, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Welcome to the v1.7.1 maintenance release of containerd! This release has no changes since\nv1.7.0 other than automated updates.\n",
+		"* **golang.org/x/net**  v0.7.0 -> v0.8.0\n",
+		"[v1.7.0](https://github.com/containerd/containerd/releases/tag/v1.7.0)",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("expected %q in notes:\n%s", expected, b.String())
+		}
+	}
+}
+
+func TestAreaTags(t *testing.T) {
+	c := &change{Formatted: "Add CDI device injection", IsMerge: true}
+	applyLabels(c, []pullRequestLabel{{Name: "area/runtime", Description: "Runtime"}, {Name: "kind/feature"}, {Name: "area/cri", Description: "CRI"}})
+	if strings.Join(c.Areas, ",") != "cri,runtime" || strings.Join(c.CategoryList, ",") != "CRI,Runtime" {
+		t.Fatalf("unexpected areas %v and categories %v", c.Areas, c.CategoryList)
+	}
+
+	r := &release{
+		ProjectName: "containerd",
+		Changes:     []projectChange{{Changes: []*change{c, {Formatted: "Fix typo", IsMerge: true}}}},
+	}
+	var b strings.Builder
+	if err := renderTemplate(&b, releaseNotes, r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\n* Add CDI device injection\n") {
+		t.Errorf("expected untagged change without area tags:\n%s", b.String())
+	}
+
+	r.AreaTags = true
+	b.Reset()
+	if err := renderTemplate(&b, releaseNotes, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"\n* [cri] [runtime] Add CDI device injection\n", "\n* Fix typo\n"} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("expected %q in notes:\n%s", expected, b.String())
+		}
+	}
+}
+
+func TestAdvisoryVersionsTable(t *testing.T) {
+	r := &release{
+		ProjectName: "containerd",
+		AdvisoryVersions: []advisoryVersions{{
+			ID:       "GHSA-259w-8hf6-59c2",
+			Link:     "https://github.com/containerd/containerd/security/advisories/GHSA-259w-8hf6-59c2",
+			Affected: []string{"< 1.5.18", ">= 1.6.0, < 1.6.18"},
+			Patched:  []string{"1.5.18", "1.6.18"},
+		}},
+	}
+	var b strings.Builder
+	if err := renderTemplate(&b, releaseNotes, r); err != nil {
+		t.Fatal(err)
+	}
+	expected := "| [GHSA-259w-8hf6-59c2](https://github.com/containerd/containerd/security/advisories/GHSA-259w-8hf6-59c2) | < 1.5.18<br>>= 1.6.0, < 1.6.18 | 1.5.18, 1.6.18 |\n"
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("expected %q in notes:\n%s", expected, b.String())
+	}
+}
+
+func TestReleaseManagers(t *testing.T) {
+	for _, tc := range []struct {
+		managers  []string
+		approvers []string
+		expected  string
+	}{
+		{nil, []string{"@jroe"}, ""},
+		{[]string{"@jdoe"}, nil, "\nRelease managed by @jdoe\n"},
+		{[]string{"@jdoe", "@jroe"}, []string{"@jsmith"}, "\nRelease managed by @jdoe, @jroe and approved by @jsmith\n"},
+	} {
+		r := &release{ProjectName: "containerd", ReleaseManagers: tc.managers, Approvers: tc.approvers}
+		var b strings.Builder
+		if err := renderTemplate(&b, releaseNotes, r); err != nil {
+			t.Fatal(err)
+		}
+		if tc.expected == "" && strings.Contains(b.String(), "Release managed by") {
+			t.Errorf("unexpected release managers in notes:\n%s", b.String())
+		} else if !strings.Contains(b.String(), tc.expected) {
+			t.Errorf("expected %q in notes:\n%s", tc.expected, b.String())
+		}
+	}
+}
+
+func TestWriteBlogPost(t *testing.T) {
+	dir := t.TempDir()
+	custom := filepath.Join(dir, "post.tmpl")
+	if err := os.WriteFile(custom, []byte("# {{.ProjectName}} {{.Version}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		blog     blogConfig
+		expected []string
+		err      string
+	}{
+		{
+			name: "default",
+			expected: []string{
+				"---\ntitle: \"containerd 1.7.1 released\"\ndate: 2023-03-01\n---\n",
+				"\n## Highlights\n\n* Fix shim cleanup ([#42](https://github.com/containerd/containerd/pull/42))\n",
+				"See the [full release notes](https://github.com/containerd/containerd/releases/tag/v1.7.1) for all changes.\n",
+			},
+		},
+		{
+			name:     "front matter",
+			blog:     blogConfig{Layout: "post", Tags: []string{"release", "containerd"}},
+			expected: []string{"date: 2023-03-01\nlayout: post\ntags:\n  - release\n  - containerd\n---\n"},
+		},
+		{
+			name:     "custom template",
+			blog:     blogConfig{Template: custom},
+			expected: []string{"# containerd 1.7.1\n"},
+		},
+		{
+			name: "missing template",
+			blog: blogConfig{Template: filepath.Join(dir, "missing.tmpl")},
+			err:  "unable to read blog template: open " + filepath.Join(dir, "missing.tmpl") + ": no such file or directory",
+		},
+	} {
+		r := &release{
+			ProjectName: "containerd",
+			GithubRepo:  "containerd/containerd",
+			Tag:         "v1.7.1",
+			Version:     "1.7.1",
+			GeneratedAt: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+			Highlights: []highlightCategory{{Changes: []highlightChange{{Change: &change{
+				Formatted: "Fix shim cleanup ([#42](https://github.com/containerd/containerd/pull/42))",
+			}}}}},
+			Blog: tc.blog,
+		}
+		post := filepath.Join(dir, tc.name+".md")
+		err := writeBlogPost(post, r)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%s: expected error %q, got %v", tc.name, tc.err, err)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		b, err := os.ReadFile(post)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range tc.expected {
+			if !strings.Contains(string(b), expected) {
+				t.Errorf("%s: expected %q in blog post:\n%s", tc.name, expected, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// todo is an outstanding item of the release checklist with the entries
+// which need attention
+type todo struct {
+	Title   string
+	Entries []string
+}
+
+// releaseTodos returns the outstanding items of the release found while
+// generating it, items without entries are omitted
+func releaseTodos(r *release, report *warningReport) []todo {
+	var unlabeled []string
+	for _, project := range r.Changes {
+		for _, c := range project.Changes {
+			if c.PullRequest != 0 && len(c.Labels) == 0 {
+				unlabeled = append(unlabeled, fmt.Sprintf("%s#%d %s", project.Name, c.PullRequest, c.Title))
+			}
+		}
+	}
+	var unresolved []string
+	for _, dep := range r.Dependencies {
+		if dep.Unresolved != "" {
+			unresolved = append(unresolved, fmt.Sprintf("%s: %s", dep.Name, dep.Unresolved))
+		}
+	}
+	var replaces []string
+	for _, w := range report.ofKind(warningReplace) {
+		replaces = append(replaces, fmt.Sprintf("%s => %s", w.Fields["old"], w.Fields["new"]))
+	}
+	var mailmap []string
+	for _, w := range report.ofKind(warningMailmap) {
+		mailmap = append(mailmap, w.Message)
+	}
+
+	var todos []todo
+	for _, t := range []todo{
+		{"Pull requests without labels", unlabeled},
+		{"Highlighted pull requests missing release notes", missingReleaseNotes(r.Changes)},
+		{"Unresolved dependencies", unresolved},
+		{"Replace directives", replaces},
+		{"Mailmap suggestions", mailmap},
+	} {
+		if len(t.Entries) > 0 {
+			todos = append(todos, t)
+		}
+	}
+	return todos
+}
+
+// writeTodos writes the outstanding items as a markdown checklist
+func writeTodos(w io.Writer, todos []todo) {
+	if len(todos) == 0 {
+		fmt.Fprintln(w, "Release checklist: nothing outstanding")
+		return
+	}
+	fmt.Fprintln(w, "Release checklist:")
+	for _, t := range todos {
+		fmt.Fprintf(w, "- [ ] %s (%d)\n", t.Title, len(t.Entries))
+		for _, entry := range t.Entries {
+			fmt.Fprintf(w, "  - %s\n", entry)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestReleaseTodos(t *testing.T) {
+	r := &release{
+		Changes: []projectChange{
+			{
+				Name: "containerd",
+				Changes: []*change{
+					{PullRequest: 1, Title: "Unlabeled change"},
+					{PullRequest: 2, Title: "Highlight", Labels: []string{"impact/changelog"}, IsHighlight: true},
+					{PullRequest: 3, Title: "Labeled", Labels: []string{"area/cri"}},
+					{Title: "Direct commit"},
+				},
+			},
+		},
+		Dependencies: []dependency{
+			{Name: "github.com/containerd/ttrpc", Unresolved: "unable to resolve"},
+			{Name: "github.com/containerd/log"},
+		},
+	}
+	report := &warningReport{}
+	report.record(warningReplace, logrus.Fields{"old": "github.com/foo/bar", "new": "../bar"}, "Dependency replace found")
+	report.record(warningLint, nil, "line too long")
+
+	todos := releaseTodos(r, report)
+	expected := []todo{
+		{"Pull requests without labels", []string{"containerd#1 Unlabeled change"}},
+		{"Highlighted pull requests missing release notes", []string{"containerd#2"}},
+		{"Unresolved dependencies", []string{"github.com/containerd/ttrpc: unable to resolve"}},
+		{"Replace directives", []string{"github.com/foo/bar => ../bar"}},
+	}
+	if len(todos) != len(expected) {
+		t.Fatalf("unexpected todos %v", todos)
+	}
+	for i := range expected {
+		if todos[i].Title != expected[i].Title || len(todos[i].Entries) != 1 || todos[i].Entries[0] != expected[i].Entries[0] {
+			t.Errorf("[%d] unexpected todo %v, expected %v", i, todos[i], expected[i])
+		}
+	}
+
+	var b bytes.Buffer
+	writeTodos(&b, nil)
+	if b.String() != "Release checklist: nothing outstanding\n" {
+		t.Errorf("unexpected empty checklist %q", b.String())
+	}
+}
@@ -0,0 +1,338 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"unicode"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var umbrellaCommand = &cli.Command{
+	Name:      "umbrella",
+	Usage:     "combine the release notes of several projects",
+	ArgsUsage: "<umbrella.toml> | <release file>...",
+	Description: `Generates the release notes of each release file in the repository
+containing it and combines them into a single document with a section per
+project, the contributors of all projects and a merged dependency table.
+Release files may also be given in an umbrella file listing them, with
+"file", "tag" and "dir" for each release, or as the output of a previous run
+with --format json. Global flags such as --cache, --linkify and --highlights
+are passed to each release.`,
+	Action: func(context *cli.Context) error {
+		if context.NArg() == 0 {
+			return errors.New("please specify an umbrella file or the release files")
+		}
+		u, err := loadUmbrella(context.Args().Slice())
+		if err != nil {
+			return err
+		}
+		var args []string
+		for _, name := range []string{"linkify", "highlights", "area-tags", "short", "skip-commits"} {
+			if context.Bool(name) {
+				args = append(args, "--"+name)
+			}
+		}
+		if cache := context.String("cache"); cache != "" {
+			args = append(args, "--cache", cache)
+		}
+		args = append(args, "--link-style", context.String("link-style"))
+		var releases []*release
+		for _, entry := range u.Releases {
+			r, err := umbrellaRelease(entry, args)
+			if err != nil {
+				return fmt.Errorf("failed to generate %s: %w", entry.File, err)
+			}
+			logrus.Infof("Generated %s %s", r.ProjectName, r.Tag)
+			releases = append(releases, r)
+		}
+		return renderUmbrella(os.Stdout, combineReleases(u, releases))
+	},
+}
+
+// umbrella combines the releases of several projects into one document
+type umbrella struct {
+	ProjectName string          `toml:"project_name"`
+	Preface     string          `toml:"preface"`
+	Postface    string          `toml:"postface"`
+	Releases    []umbrellaEntry `toml:"releases"`
+}
+
+// umbrellaEntry is a release file of the umbrella, generated in the
+// repository at dir which defaults to the repository containing the file
+type umbrellaEntry struct {
+	File string `toml:"file"`
+	Tag  string `toml:"tag"`
+	Dir  string `toml:"dir"`
+}
+
+// loadUmbrella reads the umbrella file, or an umbrella of the release files
+// when given more than one file or a release file
+func loadUmbrella(files []string) (*umbrella, error) {
+	if len(files) == 1 && filepath.Ext(files[0]) == ".toml" {
+		b, err := os.ReadFile(files[0])
+		if err != nil {
+			return nil, err
+		}
+		var u umbrella
+		if err := toml.Unmarshal(b, &u); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", files[0], err)
+		}
+		if len(u.Releases) > 0 {
+			// Paths are relative to the umbrella file
+			base := filepath.Dir(files[0])
+			for i, entry := range u.Releases {
+				if entry.File != "" && !filepath.IsAbs(entry.File) {
+					u.Releases[i].File = filepath.Join(base, entry.File)
+				}
+				if entry.Dir != "" && !filepath.IsAbs(entry.Dir) {
+					u.Releases[i].Dir = filepath.Join(base, entry.Dir)
+				}
+			}
+			return &u, nil
+		}
+	}
+	var u umbrella
+	for _, file := range files {
+		u.Releases = append(u.Releases, umbrellaEntry{File: file})
+	}
+	return &u, nil
+}
+
+// umbrellaRelease returns the release of the entry, decoding the output of
+// a previous run or running the release tool for the release file in its
+// repository with the arguments
+func umbrellaRelease(entry umbrellaEntry, args []string) (*release, error) {
+	if entry.File == "" {
+		return nil, errors.New("missing release file")
+	}
+	var r release
+	if filepath.Ext(entry.File) == ".json" {
+		b, err := os.ReadFile(entry.File)
+		if err != nil {
+			return nil, err
+		}
+		return &r, json.Unmarshal(b, &r)
+	}
+
+	file, err := filepath.Abs(entry.File)
+	if err != nil {
+		return nil, err
+	}
+	dir := entry.Dir
+	if dir == "" {
+		top, err := git("-C", filepath.Dir(file), "rev-parse", "--show-toplevel")
+		if err != nil {
+			return nil, fmt.Errorf("failed to find repository of %s: %w", entry.File, err)
+		}
+		dir = strings.TrimSpace(string(top))
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	args = append([]string{"--dry", "--format", "json"}, args...)
+	if entry.Tag != "" {
+		args = append(args, "--tag", entry.Tag)
+	}
+	cmd := exec.Command(executable, append(args, file)...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return &r, json.Unmarshal(out, &r)
+}
+
+// umbrellaNotes are the combined releases rendered by the umbrella template
+type umbrellaNotes struct {
+	ProjectName string
+	Preface     string
+	Postface    string
+	Releases    []*release
+
+	// Contributors are the contributors of all releases, merged by email
+	Contributors []contributor
+
+	// Dependencies are the dependency changes of all releases, the same
+	// change in several projects is listed once
+	Dependencies []umbrellaDependency
+}
+
+// umbrellaDependency is a dependency change and the projects with it
+type umbrellaDependency struct {
+	dependency
+	Projects []string
+}
+
+// combineReleases merges the contributors and dependency changes of the
+// releases
+func combineReleases(u *umbrella, releases []*release) *umbrellaNotes {
+	notes := &umbrellaNotes{
+		ProjectName: u.ProjectName,
+		Preface:     strings.TrimRightFunc(u.Preface, unicode.IsSpace),
+		Postface:    strings.TrimRightFunc(u.Postface, unicode.IsSpace),
+		Releases:    releases,
+	}
+	if notes.ProjectName == "" {
+		var names []string
+		for _, r := range releases {
+			names = append(names, r.ProjectName)
+		}
+		notes.ProjectName = strings.Join(names, ", ")
+	}
+
+	contributors := map[string]contributor{}
+	deps := map[string]*umbrellaDependency{}
+	for _, r := range releases {
+		for _, c := range r.Contributors {
+			existing, ok := contributors[c.Email]
+			if !ok {
+				contributors[c.Email] = c
+				continue
+			}
+			existing.Commits += c.Commits
+			for _, name := range append([]string{c.Name}, c.OtherNames...) {
+				if name != existing.Name && !contains(existing.OtherNames, name) {
+					existing.OtherNames = append(existing.OtherNames, name)
+				}
+			}
+			contributors[c.Email] = existing
+		}
+		for _, dep := range r.Dependencies {
+			key := dep.Name + " " + dep.Previous + " " + dep.Ref
+			if existing, ok := deps[key]; ok {
+				existing.Projects = append(existing.Projects, r.ProjectName)
+				continue
+			}
+			deps[key] = &umbrellaDependency{dependency: dep, Projects: []string{r.ProjectName}}
+		}
+	}
+	notes.Contributors = orderContributors(contributors)
+	for _, dep := range deps {
+		notes.Dependencies = append(notes.Dependencies, *dep)
+	}
+	sort.Slice(notes.Dependencies, func(i, j int) bool {
+		if notes.Dependencies[i].Name == notes.Dependencies[j].Name {
+			return notes.Dependencies[i].Ref < notes.Dependencies[j].Ref
+		}
+		return notes.Dependencies[i].Name < notes.Dependencies[j].Name
+	})
+	return notes
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// renderUmbrella executes the umbrella template for the combined releases
+func renderUmbrella(w io.Writer, notes *umbrellaNotes) error {
+	t, err := template.New("umbrella-notes").Funcs(templateFuncs).Option("missingkey=error").Parse(umbrellaTemplate)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 8, 8, 2, ' ', 0)
+	if err := t.Execute(tw, notes); err != nil {
+		return templateDiagnostic(err)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err = b.WriteTo(w)
+	return err
+}
+
+const umbrellaTemplate = `{{.ProjectName}}
+{{- if .Preface}}
+
+{{.Preface}}
+{{- end}}
+
+{{- range $release := .Releases}}
+
+## {{$release.ProjectName}} {{$release.Tag}}
+{{- if $release.Highlights}}
+
+### Highlights
+{{- range $highlight := $release.Highlights}}
+{{- if $highlight.Name}}
+
+#### {{$highlight.Name}}
+{{- end}}
+{{ range $change := $highlight.Changes}}
+* {{if $change.Project}}{{$change.Project}}: {{end}}{{ $change.Change.Formatted }}
+{{- end}}
+{{- end}}
+{{- end}}
+
+{{- range $project := $release.Changes}}
+
+### Changes{{if $project.Name}} from {{$project.Name}}{{end}}
+<details><summary>{{$project.Total}} commit{{if gt $project.Total 1}}s{{end}}</summary>
+<p>
+{{range $change := $project.Changes }}
+{{- if ne $change.Formatted "" }}
+{{if not $change.IsMerge}}  {{end}}* {{if $release.AreaTags}}{{range $area := $change.Areas}}[{{$area}}] {{end}}{{end}}{{$change.Formatted}}
+{{- end}}
+{{- end}}
+{{- if $project.Truncated}}
+* ... and {{pluralize $project.Truncated "more commit"}}{{if $project.CompareLink}}, see the [full comparison]({{$project.CompareLink}}){{end}}
+{{- end}}
+</p>
+</details>
+{{- end}}
+{{- end}}
+
+## Contributors
+{{range $contributor := .Contributors}}
+* {{$contributor.Name}}
+{{- end}}
+
+## Dependency Changes
+{{if .Dependencies}}
+{{- range $dep := .Dependencies}}
+* **{{$dep.Name}}**	{{if $dep.New}}{{$dep.Ref}} **_new_**{{else}}{{$dep.Previous}} -> {{$dep.Ref}}{{end}} _({{join $dep.Projects ", "}})_
+{{- end}}
+{{- else}}
+These releases have no dependency changes
+{{- end}}
+{{- if .Postface}}
+
+{{.Postface}}
+{{- end}}
+`
@@ -0,0 +1,101 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUmbrella(t *testing.T) {
+	dir := t.TempDir()
+	for name, r := range map[string]release{
+		"containerd.json": {
+			ProjectName:  "containerd",
+			Tag:          "v2.0.0",
+			Contributors: []contributor{{Name: "Jane Doe", Email: "jane@example.com", Commits: 3}},
+			Dependencies: []dependency{{Name: "github.com/opencontainers/runtime-spec", Previous: "v1.1.0", Ref: "v1.2.0"}},
+			Changes:      []projectChange{{Changes: []*change{{Formatted: "Add CDI support", IsMerge: true}}}},
+		},
+		"runc.json": {
+			ProjectName:  "runc",
+			Tag:          "v1.2.0",
+			Contributors: []contributor{{Name: "Jane D.", Email: "jane@example.com", Commits: 2}, {Name: "Alex Smith", Email: "alex@example.com", Commits: 1}},
+			Dependencies: []dependency{{Name: "github.com/opencontainers/runtime-spec", Previous: "v1.1.0", Ref: "v1.2.0"}, {Name: "golang.org/x/sys", Ref: "v0.20.0", New: true}},
+		},
+	} {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	umbrellaFile := filepath.Join(dir, "umbrella.toml")
+	if err := os.WriteFile(umbrellaFile, []byte(`project_name = "containerd ecosystem"
+
+[[releases]]
+file = "containerd.json"
+
+[[releases]]
+file = "runc.json"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := loadUmbrella([]string{umbrellaFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var releases []*release
+	for _, entry := range u.Releases {
+		r, err := umbrellaRelease(entry, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, r)
+	}
+	notes := combineReleases(u, releases)
+	if len(notes.Contributors) != 2 || notes.Contributors[0].Commits != 5 || notes.Contributors[0].OtherNames[0] != "Jane D." {
+		t.Errorf("unexpected contributors %+v", notes.Contributors)
+	}
+	if len(notes.Dependencies) != 2 || strings.Join(notes.Dependencies[0].Projects, ",") != "containerd,runc" {
+		t.Errorf("unexpected dependencies %+v", notes.Dependencies)
+	}
+
+	var b bytes.Buffer
+	if err := renderUmbrella(&b, notes); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"containerd ecosystem\n",
+		"## containerd v2.0.0\n",
+		"## runc v1.2.0\n",
+		"* Add CDI support\n",
+		"_(containerd, runc)_",
+		"v0.20.0 **_new_** _(runc)_",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("expected %q in notes:\n%s", expected, b.String())
+		}
+	}
+}
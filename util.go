@@ -19,21 +19,28 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
 	"golang.org/x/net/html"
 )
 
@@ -48,7 +55,6 @@ var (
 )
 
 func loadRelease(path string) (*release, error) {
-	var r release
 	b, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -56,44 +62,267 @@ func loadRelease(path string) (*release, error) {
 		}
 		return nil, err
 	}
-	if err = toml.Unmarshal(b, &r); err != nil {
+	var config struct {
+		release
+		// Previous is either a single ref or a list of refs, the first
+		// is the previous release and the rest are compared against
+		Previous interface{} `toml:"previous"`
+	}
+	if err = toml.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+	r := config.release
+	switch previous := config.Previous.(type) {
+	case nil:
+	case string:
+		r.Previous = previous
+	case []interface{}:
+		for i, p := range previous {
+			ref, ok := p.(string)
+			if !ok || ref == "" {
+				return nil, fmt.Errorf("invalid previous release %v", p)
+			}
+			if i == 0 {
+				r.Previous = ref
+			} else {
+				r.Comparisons = append(r.Comparisons, comparison{Previous: ref})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("previous must be a ref or a list of refs, got %v", previous)
+	}
+	if err := validateModulePatterns("replace_policy.allow", r.ReplacePolicy.Allow); err != nil {
 		return nil, err
 	}
+	if err := validateModulePatterns("tool_deps", r.ToolDeps); err != nil {
+		return nil, err
+	}
+	if err := validateModulePatterns("test_deps", r.TestDeps); err != nil {
+		return nil, err
+	}
+	r.Provenance = &provenance{
+		ConfigHash: fmt.Sprintf("%x", sha256.Sum256(b)),
+	}
 	return &r, nil
 }
 
+// currentDependencies returns the dependencies at the release commit with
+// the overrides applied and the development dependencies classified
+func currentDependencies(r *release, replaced map[string]replacedModule, cache Cache) ([]dependency, error) {
+	current, err := parseSubPathDependencies(r.CommitSha, r.modulePaths(), replaced, cache)
+	if err != nil {
+		return nil, err
+	}
+	overrideDependencies(current, r.OverrideDeps)
+	var tools, other []string
+	for _, subpath := range r.modulePaths() {
+		toolImports, otherImports, err := moduleImports(r.CommitSha, subpath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tool imports: %w", err)
+		}
+		tools = append(tools, toolImports...)
+		other = append(other, otherImports...)
+	}
+	classifyDependencies(current, tools, other, r.ToolDeps, r.TestDeps)
+	return current, nil
+}
+
+// dependencyChanges returns the changes of the current dependencies since
+// the previous commit sorted by name, leaving out development dependencies
+// when excluded
+func dependencyChanges(r *release, previousSha string, current []dependency, excludeDevDeps bool, cache Cache, bestEffort bool) ([]dependency, error) {
+	previous, err := parseSubPathDependencies(previousSha, r.modulePaths(), nil, cache)
+	if err != nil {
+		return nil, err
+	}
+	renameDependencies(previous, r.RenameDeps)
+	updated, err := getUpdatedDeps(previous, current, r.IgnoreDeps, cache, bestEffort)
+	if err != nil {
+		return nil, err
+	}
+	if excludeDevDeps {
+		updated = withoutDevDependencies(updated)
+	}
+	sort.Slice(updated, func(i, j int) bool {
+		return updated[i].Name < updated[j].Name
+	})
+	return updated, nil
+}
+
+// compareBaseline sets the dependency changes and highlights of the release
+// compared to the additional previous release, the current dependencies are
+// those of the release. Highlights are only collected from the changes of
+// the project.
+func compareBaseline(r *release, c *comparison, current []dependency, highlights, excludeDevDeps bool, cache Cache, bestEffort bool) error {
+	var err error
+	c.Dependencies, err = dependencyChanges(r, c.PreviousSha, current, excludeDevDeps, cache, bestEffort)
+	if err != nil {
+		return err
+	}
+
+	if !highlights {
+		return nil
+	}
+	changes, err := changelog(c.PreviousSha, r.CommitSha)
+	if err != nil {
+		return err
+	}
+	if err := formatChanges(changes, r.changeRepo(), "", cache, true, false, true); err != nil {
+		return err
+	}
+	c.Highlights = groupHighlights([]projectChange{{Changes: changes}}, r.HighlightSections)
+	return nil
+}
+
+// loadSections validates the positions of the custom sections and reads
+// the bodies from their files, relative to the release file directory
+func loadSections(sections []customSection, dir string) error {
+	for i, section := range sections {
+		if section.Position != "" {
+			var valid bool
+			for _, position := range sectionPositions {
+				if section.Position == position {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return fmt.Errorf("section %q has unknown position %q, must be one of %s", section.Title, section.Position, strings.Join(sectionPositions, ", "))
+			}
+		}
+		if section.File == "" {
+			continue
+		}
+		file := section.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read section %q: %w", section.Title, err)
+		}
+		sections[i].Body = string(b)
+	}
+	return nil
+}
+
+// generationTime returns the time from SOURCE_DATE_EPOCH when set or the
+// committer date of the commit, so generating the notes again gives the
+// same output
+//
+// See https://reproducible-builds.org/docs/source-date-epoch/
+func generationTime(commit string) (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		out, err := git("show", "-s", "--format=%ct", commit)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to get the date of %s: %w", commit, err)
+		}
+		epoch = strings.TrimSpace(string(out))
+	}
+	sec, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %w", err)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
 func parseTag(path string) string {
 	return strings.TrimSuffix(filepath.Base(path), ".toml")
 }
 
-func parseDependencies(commit, subpath string, replaced map[string]string) ([]dependency, error) {
-	rd, err := fileFromRev(commit, vendorConf)
-	if err == nil {
-		return parseVendorConfDependencies(rd)
+// parsedDependencies are the dependencies and replaced modules parsed at a
+// commit, cached by the commit sha
+type parsedDependencies struct {
+	Dependencies []dependency
+	Replaced     map[string]replacedModule
+}
+
+// parseDependencies returns the dependencies at the commit, adding the
+// replaced modules to replaced when not nil. The parsed dependencies are
+// cached by the sha of the commit.
+func parseDependencies(commit, subpath string, replaced map[string]replacedModule, cache Cache) ([]dependency, error) {
+	sha, err := git("rev-parse", "--verify", commit+"^{commit}")
+	if err != nil {
+		return nil, err
 	}
-	// Look for go module at subpath if provided
-	if subpath != "" {
-		rd, err = fileFromRev(commit, filepath.Join(subpath, modulesTxt))
-		if err == nil {
-			return parseModulesTxtDependencies(rd, replaced)
+	key := fmt.Sprintf("dependencies %s %s json", strings.TrimSpace(string(sha)), filepath.ToSlash(subpath))
+	var parsed parsedDependencies
+	if b, ok := cache.Get(key); ok && json.Unmarshal(b, &parsed) == nil {
+		logrus.WithField("cache", "hit").Debug(key)
+	} else {
+		parsed.Replaced = map[string]replacedModule{}
+		parsed.Dependencies, err = readDependencies(commit, subpath, parsed.Replaced)
+		if err != nil {
+			return nil, err
 		}
-		rd, err = fileFromRev(commit, filepath.Join(subpath, goMod))
-		if err == nil {
-			return parseGoModDependencies(rd, replaced)
+		if b, err := json.Marshal(parsed); err == nil {
+			cache.Put(key, b)
 		}
 	}
-	rd, err = fileFromRev(commit, modulesTxt)
-	if err == nil {
-		return parseModulesTxtDependencies(rd, replaced)
+	for old, m := range parsed.Replaced {
+		if replaced != nil {
+			replaced[old] = m
+		}
 	}
-	rd, err = fileFromRev(commit, goMod)
-	if err == nil {
-		return parseGoModDependencies(rd, replaced)
+	return parsed.Dependencies, nil
+}
+
+// parseSubPathDependencies returns the dependencies at the commit of the
+// modules at the subpaths, merged when there is more than one subpath
+func parseSubPathDependencies(commit string, subpaths []string, replaced map[string]replacedModule, cache Cache) ([]dependency, error) {
+	if len(subpaths) == 1 {
+		return parseDependencies(commit, subpaths[0], replaced, cache)
 	}
-	return nil, fmt.Errorf("finding dependency file failed: %w", err)
+	members := map[string]struct{}{}
+	var depLists [][]dependency
+	for _, subpath := range subpaths {
+		deps, err := parseDependencies(commit, subpath, replaced, cache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dependencies of %s: %w", subpath, err)
+		}
+		depLists = append(depLists, deps)
+		if mf, err := readGoMod(commit, subpath); err == nil && mf.Module != nil {
+			members[mf.Module.Mod.Path] = struct{}{}
+		}
+	}
+	return mergeDependencies(depLists, members), nil
 }
 
-func parseModulesTxtDependencies(r io.Reader, replaced map[string]string) ([]dependency, error) {
+// readDependencies parses the first dependency file found at the commit,
+// reading all candidate files with a single git command
+func readDependencies(commit, subpath string, replaced map[string]replacedModule) ([]dependency, error) {
+	candidates := []string{vendorConf}
+	// Look for go module at subpath if provided, git always uses forward
+	// slashes for paths within a revision
+	if subpath != "" {
+		candidates = append(candidates, path.Join(filepath.ToSlash(subpath), modulesTxt), path.Join(filepath.ToSlash(subpath), goMod))
+	}
+	candidates = append(candidates, modulesTxt, goWork, goMod)
+	files, err := filesFromRev(commit, candidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range candidates {
+		b, ok := files[file]
+		if !ok {
+			continue
+		}
+		switch {
+		case file == vendorConf:
+			return parseVendorConfDependencies(bytes.NewReader(b))
+		case strings.HasSuffix(file, modulesTxt):
+			return parseModulesTxtDependencies(bytes.NewReader(b), replaced)
+		case file == goWork:
+			return parseGoWorkDependencies(commit, b, replaced)
+		default:
+			return parseGoModDependencies(bytes.NewReader(b), replaced)
+		}
+	}
+	return nil, fmt.Errorf("finding dependency file failed: none of %s found at %s", strings.Join(candidates, ", "), commit)
+}
+
+func parseModulesTxtDependencies(r io.Reader, replaced map[string]replacedModule) ([]dependency, error) {
 	var dependencies []dependency
 	s := bufio.NewScanner(r)
 	for s.Scan() {
@@ -113,25 +342,25 @@ func parseModulesTxtDependencies(r io.Reader, replaced map[string]string) ([]dep
 			commitOrVersionPart = parts[2]
 		} else if len(parts) == 5 && parts[2] == "=>" {
 			if replaced != nil {
-				replaced[parts[1]] = parts[3]
+				replaced[parts[1]] = replacedModule{Old: parts[1], New: parts[3], Version: parts[4]}
 			}
 			// replace directive in go.mod without old version
 			// no need to care since it will has corresponding one with old version
 			continue
 		} else if len(parts) == 6 && parts[3] == "=>" {
 			if replaced != nil {
-				replaced[parts[1]] = parts[4]
+				replaced[parts[1]] = replacedModule{Old: parts[1], New: parts[4], Version: parts[5]}
 			}
 			commitOrVersionPart = parts[5]
 		} else if len(parts) == 4 && parts[2] == "=>" {
 			if replaced != nil {
-				replaced[parts[1]] = parts[3]
+				replaced[parts[1]] = replacedModule{Old: parts[1], New: parts[3]}
 			}
 			// Ignore replace directive which uses filepath
 			continue
 		} else if len(parts) == 5 && parts[3] == "=>" {
 			if replaced != nil {
-				replaced[parts[1]] = parts[4]
+				replaced[parts[1]] = replacedModule{Old: parts[1], New: parts[4]}
 			}
 			// Ignore replace directive which uses filepath
 			continue
@@ -148,7 +377,7 @@ func parseModulesTxtDependencies(r io.Reader, replaced map[string]string) ([]dep
 	return dependencies, nil
 }
 
-func parseGoModDependencies(r io.Reader, replaced map[string]string) ([]dependency, error) {
+func parseGoModDependencies(r io.Reader, replaced map[string]replacedModule) ([]dependency, error) {
 	var err error
 
 	contents, err := io.ReadAll(r)
@@ -156,9 +385,15 @@ func parseGoModDependencies(r io.Reader, replaced map[string]string) ([]dependen
 		return nil, err
 	}
 
-	goMod, err := modfile.ParseLax("go.mod", contents, nil)
+	// Replace directives are only read when parsing strictly, lax parsing
+	// is kept for go.mod files with directives unknown to this version
+	goMod, err := modfile.Parse("go.mod", contents, nil)
 	if err != nil {
-		return nil, err
+		warnings.warn(warningReplace, logrus.Fields{"error": err}, "Unable to parse go.mod strictly, ignoring replace directives")
+		goMod, err = modfile.ParseLax("go.mod", contents, nil)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	depMap := make(map[string]*dependency)
@@ -176,9 +411,9 @@ func parseGoModDependencies(r io.Reader, replaced map[string]string) ([]dependen
 
 	for _, replace := range goMod.Replace {
 		if replaced != nil {
-			replaced[replace.Old.Path] = replace.New.Path
+			replaced[replace.Old.Path] = replacedModule{Old: replace.Old.Path, New: replace.New.Path, Version: replace.New.Version}
 		}
-		if strings.HasPrefix(replace.New.Path, "./") {
+		if modfile.IsDirectoryPath(replace.New.Path) {
 			continue
 		}
 
@@ -205,10 +440,88 @@ func parseGoModDependencies(r io.Reader, replaced map[string]string) ([]dependen
 	for _, dep := range depMap {
 		deps = append(deps, *dep)
 	}
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Name < deps[j].Name
+	})
 
 	return deps, nil
 }
 
+// readGoMod parses the go.mod of the project at the revision
+func readGoMod(rev, subpath string) (*modfile.File, error) {
+	rd, err := fileFromRev(rev, path.Join(filepath.ToSlash(subpath), goMod))
+	if err != nil {
+		return nil, err
+	}
+	contents, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	return modfile.ParseLax(goMod, contents, nil)
+}
+
+// validateGoVersion checks the Go version used to build the release is not
+// older than the minimum of the toolchain directive of the go.mod at the
+// commit, or otherwise of the go directive. No validation is done without a
+// go.mod or for versions which cannot be compared.
+func validateGoVersion(commit, subpath, version string) error {
+	if version == "" {
+		return nil
+	}
+	mf, err := readGoMod(commit, subpath)
+	if err != nil {
+		return nil
+	}
+	version = strings.TrimPrefix(version, "go")
+	if !semver.IsValid("v" + version) {
+		return nil
+	}
+	// The toolchain directive is only parsed in strict mode
+	for _, stmt := range mf.Syntax.Stmt {
+		if line, ok := stmt.(*modfile.Line); ok && len(line.Token) == 2 && line.Token[0] == "toolchain" {
+			toolchain := strings.TrimPrefix(line.Token[1], "go")
+			if semver.IsValid("v"+toolchain) && semver.Compare("v"+version, "v"+toolchain) < 0 {
+				return fmt.Errorf("go version %s is older than go.mod toolchain %s", version, toolchain)
+			}
+			return nil
+		}
+	}
+	if mf.Go != nil && semver.Compare("v"+version, "v"+mf.Go.Version) < 0 {
+		return fmt.Errorf("go version %s is older than go.mod go %s", version, mf.Go.Version)
+	}
+	return nil
+}
+
+// newRetractions returns the retract directives in the go.mod at the commit
+// which are not in the go.mod at the previous revision
+func newRetractions(previous, commit, subpath string) ([]retraction, error) {
+	current, err := readGoMod(commit, subpath)
+	if err != nil {
+		// not a go module
+		return nil, nil
+	}
+	existing := map[retraction]struct{}{}
+	if previous != "" {
+		if mf, err := readGoMod(previous, subpath); err == nil {
+			for _, r := range mf.Retract {
+				existing[retraction{Low: r.Low, High: r.High}] = struct{}{}
+			}
+		}
+	}
+	var retractions []retraction
+	for _, r := range current.Retract {
+		if _, ok := existing[retraction{Low: r.Low, High: r.High}]; ok {
+			continue
+		}
+		retractions = append(retractions, retraction{
+			Low:       r.Low,
+			High:      r.High,
+			Rationale: r.Rationale,
+		})
+	}
+	return retractions, nil
+}
+
 func sanitizeLine(line, commentDelim string) string {
 	ln := strings.TrimSpace(line)
 	if ln == "" {
@@ -367,8 +680,21 @@ func gitChangeDiff(previous, commit string) string {
 	return commit
 }
 
+// getChangelog returns the abbreviated commit, author email, author name
+// and subject of each commit separated by NUL, one commit per line, so the
+// changes and contributors are collected with a single git log
 func getChangelog(previous, commit string) ([]byte, error) {
-	return git("log", "--oneline", "--topo-order", gitChangeDiff(previous, commit))
+	return git("log", "--topo-order", "--format=%h%x00%aE%x00%aN%x00%s", gitChangeDiff(previous, commit))
+}
+
+// commitCount returns the number of commits in the range regardless of the
+// sub-paths the changelog is limited to
+func commitCount(previous, commit string) (int, error) {
+	out, err := git("rev-list", "--count", gitChangeDiff(previous, commit))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
 }
 
 type changeProcessor interface {
@@ -381,10 +707,15 @@ func parseChangelog(changelog []byte) ([]*change, error) {
 		s       = bufio.NewScanner(bytes.NewReader(changelog))
 	)
 	for s.Scan() {
-		fields := strings.Fields(s.Text())
+		fields := strings.SplitN(s.Text(), "\x00", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unparsable git log output: %s", s.Text())
+		}
 		changes = append(changes, &change{
 			Commit:      fields[0],
-			Description: strings.Join(fields[1:], " "),
+			Description: strings.Join(strings.Fields(fields[3]), " "),
+			AuthorName:  fields[2],
+			AuthorEmail: fields[1],
 		})
 	}
 	if err := s.Err(); err != nil {
@@ -431,6 +762,9 @@ func getSha(gitURL, rev string, cache Cache) (string, error) {
 		return string(b), nil
 	}
 	logrus.WithField("cache", "miss").Debug(key)
+	if offline {
+		return "", fmt.Errorf("%s: %w", key, errNotCached)
+	}
 
 	b := lsRemote(key, gitURL, rev)
 	if b == nil {
@@ -470,6 +804,162 @@ func getSha(gitURL, rev string, cache Cache) (string, error) {
 	return sha, nil
 }
 
+// resolveCommit resolves a ref, such as a tag or branch, to a full commit sha
+func resolveCommit(ref string) (string, error) {
+	out, err := git("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve %q to a commit, make sure the ref exists locally", ref)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// fetchCommit fetches the ref from the remote and returns the fetched commit,
+// a ref prefixed with the remote name is fetched without the prefix
+func fetchCommit(remote, ref string) (string, error) {
+	if offline {
+		return "", fmt.Errorf("unable to fetch %q: %w", ref, errNotCached)
+	}
+	logrus.Infof("Fetching %s from %s", ref, remote)
+	if _, err := git("fetch", "--quiet", remote, strings.TrimPrefix(ref, remote+"/")); err != nil {
+		return "", fmt.Errorf("unable to fetch %q from %s: %w", ref, remote, err)
+	}
+	if sha, err := resolveCommit(ref); err == nil {
+		return sha, nil
+	}
+	return resolveCommit("FETCH_HEAD")
+}
+
+// commitDate returns the committer date of the commit
+func commitDate(rev string) (time.Time, error) {
+	out, err := git("show", "-s", "--format=%cI", rev)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+}
+
+// tagMessage returns the subject and body of the message of the annotated
+// tag, without its signature
+func tagMessage(tag string) (string, error) {
+	ref := "refs/tags/" + tag
+	out, err := git("cat-file", "-t", ref)
+	if err != nil {
+		return "", fmt.Errorf("unable to find tag %q: %w", tag, err)
+	}
+	if kind := strings.TrimSpace(string(out)); kind != "tag" {
+		return "", fmt.Errorf("%q is not an annotated tag", tag)
+	}
+	out, err = git("for-each-ref", "--format=%(contents:subject)%0a%0a%(contents:body)", ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// licenseFiles are pathspecs of the project's license files
+var licenseFiles = []string{"LICENSE*", "LICENCE*", "NOTICE*", "COPYING*"}
+
+// licenseChanges returns the license files of the project which were added,
+// modified or deleted between the revisions, at the root of the repository
+// and of each sub-path
+func licenseChanges(previous, commit string, subpaths []string) ([]licenseChange, error) {
+	if previous == "" {
+		return nil, nil
+	}
+	args := []string{"diff", "--name-status", "-z", "--no-renames", previous, commit, "--"}
+	for _, dir := range append([]string{""}, subpaths...) {
+		for _, pattern := range licenseFiles {
+			args = append(args, path.Join(filepath.ToSlash(dir), pattern))
+		}
+	}
+	out, err := git(args...)
+	if err != nil {
+		return nil, err
+	}
+	// Each change is the status and file name terminated by NUL, so file
+	// names are not quoted and may contain spaces
+	var changes []licenseChange
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		var status string
+		switch fields[i] {
+		case "A":
+			status = "added"
+		case "D":
+			status = "deleted"
+		default:
+			status = "modified"
+		}
+		changes = append(changes, licenseChange{File: fields[i+1], Status: status})
+	}
+	return changes, nil
+}
+
+// maintainersFiles are the files listing project maintainers, in order of
+// preference
+var maintainersFiles = []string{"MAINTAINERS", "OWNERS"}
+
+// validateMaintainers checks that each person is listed in the maintainers
+// file at the commit, people are matched by the whole name, email or GitHub
+// handle of an entry ignoring case. No validation is done when the project
+// has no maintainers file.
+func validateMaintainers(commit string, people []string) error {
+	if len(people) == 0 {
+		return nil
+	}
+	var content []byte
+	for _, file := range maintainersFiles {
+		rd, err := fileFromRev(commit, file)
+		if err != nil {
+			continue
+		}
+		if content, err = io.ReadAll(rd); err != nil {
+			return err
+		}
+		logrus.Debugf("Validating release managers and approvers against %s", file)
+		break
+	}
+	if content == nil {
+		logrus.Debug("No maintainers file found, skipping validation of release managers and approvers")
+		return nil
+	}
+	maintainers := maintainerFields(content)
+	for _, person := range people {
+		if _, ok := maintainers[normalizeMaintainer(person)]; !ok {
+			return fmt.Errorf("%q is not listed as a maintainer of the project", person)
+		}
+	}
+	return nil
+}
+
+// maintainerFields returns the names, emails and GitHub handles listed in a
+// maintainers file, either as comma separated quoted fields such as
+// "handle","Name","email" or as the list entries of an OWNERS file
+func maintainerFields(content []byte) map[string]struct{} {
+	fields := map[string]struct{}{}
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		ln := strings.TrimSpace(s.Text())
+		if ln == "" || strings.HasPrefix(ln, "#") || strings.HasSuffix(ln, ":") {
+			continue
+		}
+		ln = strings.TrimPrefix(ln, "- ")
+		for _, field := range strings.Split(ln, ",") {
+			if field = normalizeMaintainer(field); field != "" {
+				fields[field] = struct{}{}
+			}
+		}
+	}
+	return fields
+}
+
+// normalizeMaintainer returns the maintainer field without surrounding
+// quotes, angle brackets or handle prefix for comparing regardless of case
+func normalizeMaintainer(field string) string {
+	field = strings.Trim(strings.TrimSpace(field), `"'<>`)
+	return strings.ToLower(strings.TrimPrefix(field, "@"))
+}
+
 func fileFromRev(rev, file string) (io.Reader, error) {
 	p, err := git("show", fmt.Sprintf("%s:%s", rev, file))
 	if err != nil {
@@ -479,38 +969,321 @@ func fileFromRev(rev, file string) (io.Reader, error) {
 	return bytes.NewReader(p), nil
 }
 
+// filesFromRev returns the contents of the files which exist at the revision
+// by path, using a single git cat-file process for all files
+func filesFromRev(rev string, files []string) (map[string][]byte, error) {
+	var input bytes.Buffer
+	for _, file := range files {
+		fmt.Fprintf(&input, "%s:%s\n", rev, file)
+	}
+	cmd, err := gitCommand("cat-file", "--batch")
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = &input
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, stderr.Bytes())
+	}
+
+	contents := map[string][]byte{}
+	r := bufio.NewReader(bytes.NewReader(out))
+	for _, file := range files {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("unexpected cat-file output for %s: %w", file, err)
+		}
+		// Objects are either "<sha> <type> <size>" followed by the content
+		// or "<object> missing"
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected cat-file header %q: %w", header, err)
+		}
+		b := make([]byte, size+1)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, fmt.Errorf("unexpected cat-file output for %s: %w", file, err)
+		}
+		if fields[1] == "blob" {
+			contents[file] = b[:size]
+		}
+	}
+	return contents, nil
+}
+
 var gitConfigs = map[string]string{}
 var gitSubpaths = []string{}
 
+var (
+	gitPath     string
+	gitPathErr  error
+	gitPathOnce sync.Once
+)
+
+// gitExecutable returns the path of the git executable, looked up once from
+// RELEASE_TOOL_GIT or otherwise git in the PATH
+func gitExecutable() (string, error) {
+	gitPathOnce.Do(func() {
+		name := os.Getenv("RELEASE_TOOL_GIT")
+		if name == "" {
+			name = "git"
+		}
+		gitPath, gitPathErr = exec.LookPath(name)
+		if gitPathErr != nil {
+			gitPathErr = fmt.Errorf("unable to find git, make sure it is installed and in the PATH: %w", gitPathErr)
+		}
+	})
+	return gitPath, gitPathErr
+}
+
 func git(args ...string) ([]byte, error) {
+	cmd, err := gitCommand(args...)
+	if err != nil {
+		return nil, err
+	}
+	o, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, o)
+	}
+	// Git for Windows may output CRLF line endings
+	return bytes.ReplaceAll(o, []byte("\r\n"), []byte("\n")), nil
+}
+
+// gitCommand returns the git command with the configured options
+func gitCommand(args ...string) (*exec.Cmd, error) {
+	executable, err := gitExecutable()
+	if err != nil {
+		return nil, err
+	}
 	var gitArgs []string
-	for k, v := range gitConfigs {
-		gitArgs = append(gitArgs, "-c", fmt.Sprintf("%s=%s", k, v))
+	keys := make([]string, 0, len(gitConfigs))
+	for k := range gitConfigs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		gitArgs = append(gitArgs, "-c", fmt.Sprintf("%s=%s", k, gitConfigs[k]))
 	}
 	gitArgs = append(gitArgs, args...)
 	if len(gitSubpaths) > 0 && len(args) > 0 && args[0] == "log" {
 		gitArgs = append(gitArgs, "--show-pulls", "--")
 		gitArgs = append(gitArgs, gitSubpaths...)
 	}
-	o, err := exec.Command("git", gitArgs...).CombinedOutput()
+	return exec.Command(executable, gitArgs...), nil
+}
+
+// mirrorDependency ensures a bare mirror of the dependency repository exists
+// in the git root and contains the ref, returning the path to the mirror
+func mirrorDependency(gitRoot, name, gitURL, ref string) (string, error) {
+	dir := filepath.Join(gitRoot, name+".git")
+	if _, err := os.Stat(dir); err != nil {
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("unable to stat: %w", err)
+		}
+		if offline {
+			return "", fmt.Errorf("no mirror of %s: %w", name, errNotCached)
+		}
+		logrus.Debugf("git clone --mirror %s %s", gitURL, dir)
+		if _, err := git("clone", "--mirror", gitURL, dir); err != nil {
+			return "", fmt.Errorf("failed to clone: %w", err)
+		}
+		return dir, nil
+	}
+	// Track usage for least recently used pruning, not all platforms
+	// support setting the times of a directory
+	now := time.Now()
+	if err := os.Chtimes(dir, now, now); err != nil {
+		logrus.WithError(err).Debugf("unable to update mirror times for %s", name)
+	}
+	if _, err := git("-C", dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		if offline {
+			return "", fmt.Errorf("%s is missing from the mirror of %s: %w", ref, name, errNotCached)
+		}
+		logrus.WithField("name", name).Debugf("git remote update")
+		if _, err := git("-C", dir, "remote", "update", "--prune"); err != nil {
+			return "", fmt.Errorf("failed to update mirror: %w", err)
+		}
+	}
+	return dir, nil
+}
+
+// pruneMirrors removes the least recently used mirrors from the git root
+// until the total size is within maxSize
+func pruneMirrors(gitRoot string, maxSize int64) error {
+	type mirror struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var (
+		mirrors []mirror
+		total   int64
+	)
+	err := filepath.WalkDir(gitRoot, func(p string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !de.IsDir() || !strings.HasSuffix(p, ".git") {
+			return nil
+		}
+		fi, err := de.Info()
+		if err != nil {
+			return err
+		}
+		size, err := dirSize(p)
+		if err != nil {
+			return err
+		}
+		mirrors = append(mirrors, mirror{path: p, size: size, modTime: fi.ModTime()})
+		total += size
+		return filepath.SkipDir
+	})
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s", err, o)
+		return err
+	}
+	sort.Slice(mirrors, func(i, j int) bool {
+		return mirrors[i].modTime.Before(mirrors[j].modTime)
+	})
+	for _, m := range mirrors {
+		if total <= maxSize {
+			break
+		}
+		logrus.Debugf("Removing least recently used mirror %s (%d bytes)", m.path, m.size)
+		if err := os.RemoveAll(m.path); err != nil {
+			return err
+		}
+		total -= m.size
+	}
+	return nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.Type().IsRegular() {
+			fi, err := de.Info()
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// parseSize parses a size in bytes with an optional K, M, G or T suffix
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	var multiplier int64 = 1
+	if len(s) > 0 {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
 	}
-	return o, nil
+	return n * multiplier, nil
 }
 
 func overrideDependencies(deps []dependency, overrides map[string]dependencyOverride) {
 	if len(overrides) == 0 {
 		return
 	}
+	matched := map[string]struct{}{}
 	for i := range deps {
 		if or, ok := overrides[deps[i].Name]; ok {
+			matched[deps[i].Name] = struct{}{}
 			if or.Previous != "" {
 				logrus.Debugf("Overrode previous version of %s to %s", deps[i].Name, or.Previous)
 				deps[i].Previous = or.Previous
 			}
 		}
 	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := matched[name]; !ok {
+			warnings.warn(warningOverride, logrus.Fields{"name": name}, "Dependency override does not match any dependency")
+		}
+	}
+}
+
+// checkReplaces returns all replaced modules and those not allowed by the
+// policy, sorted by the replaced module path
+func checkReplaces(replaced map[string]replacedModule, policy replacePolicy) ([]replacedModule, []replacedModule) {
+	var all, unexpected []replacedModule
+	for _, m := range replaced {
+		all = append(all, m)
+		allowed := false
+		for _, pattern := range policy.Allow {
+			if matchModulePattern(pattern, m.Old) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			unexpected = append(unexpected, m)
+		}
+	}
+	for _, modules := range [][]replacedModule{all, unexpected} {
+		sort.Slice(modules, func(i, j int) bool {
+			return modules[i].Old < modules[j].Old
+		})
+	}
+	return all, unexpected
+}
+
+// matchModulePattern returns whether the module path matches the pattern,
+// either a path pattern such as "github.com/containerd/*" or a pattern
+// ending in "/..." such as "github.com/containerd/..." which also matches
+// nested paths. The pattern must have been validated.
+func matchModulePattern(pattern, module string) bool {
+	if strings.HasSuffix(pattern, "/...") {
+		prefix := strings.TrimSuffix(pattern, "/...")
+		elems := strings.Split(module, "/")
+		n := strings.Count(prefix, "/") + 1
+		if len(elems) < n {
+			return false
+		}
+		matched, _ := path.Match(prefix, strings.Join(elems[:n], "/"))
+		return matched
+	}
+	matched, _ := path.Match(pattern, module)
+	return matched
+}
+
+// validateModulePatterns returns an error for the first malformed pattern
+func validateModulePatterns(field string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/..."), ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", field, pattern, err)
+		}
+	}
+	return nil
 }
 
 func renameDependencies(deps []dependency, renames map[string]projectRename) {
@@ -536,7 +1309,10 @@ func renameDependencies(deps []dependency, renames map[string]projectRename) {
 	}
 }
 
-func getUpdatedDeps(previous, deps []dependency, ignored []string, cache Cache) ([]dependency, error) {
+// getUpdatedDeps returns the new and updated dependencies. In best effort
+// mode, dependencies which cannot be resolved are returned as unresolved
+// rather than failing.
+func getUpdatedDeps(previous, deps []dependency, ignored []string, cache Cache, bestEffort bool) ([]dependency, error) {
 	var updated []dependency
 	pm, cm := toDepMap(previous), toDepMap(deps)
 	ignoreMap := map[string]struct{}{}
@@ -563,36 +1339,22 @@ func getUpdatedDeps(previous, deps []dependency, ignored []string, cache Cache)
 				updated = append(updated, c)
 			}
 		} else if d.Ref != c.Ref {
-			if d.Sha == "" {
-				if d.GitURL == "" {
-					gitURL, err := resolveGitURL(name, cache)
-					if err != nil {
-						return nil, fmt.Errorf("git url for %s: %w", name, err)
-					}
-					d.GitURL = gitURL
-					if c.GitURL == "" {
-						c.GitURL = d.GitURL
-					}
-				}
-				sha, err := getSha(d.GitURL, d.Ref, cache)
-				if err != nil {
-					return nil, fmt.Errorf("failed to get sha for %s: %w", name, err)
-				}
-				d.Sha = sha
-			}
-			if c.Sha == "" {
+			_, err := resolveDependency(&d, cache)
+			if err == nil {
 				if c.GitURL == "" {
-					gitURL, err := resolveGitURL(name, cache)
-					if err != nil {
-						return nil, fmt.Errorf("git url for %s: %w", name, err)
-					}
-					c.GitURL = gitURL
+					c.GitURL = d.GitURL
 				}
-				sha, err := getSha(c.GitURL, c.Ref, cache)
-				if err != nil {
-					return nil, fmt.Errorf("failed to get sha for %s: %w", name, err)
+				_, err = resolveDependency(&c, cache)
+			}
+			if err != nil {
+				if !bestEffort {
+					return nil, err
 				}
-				c.Sha = sha
+				warnings.warn(warningDependency, logrus.Fields{"name": c.Name, "error": err}, "Unable to resolve dependency")
+				c.Previous = d.Ref
+				c.Unresolved = err.Error()
+				updated = append(updated, c)
+				continue
 			}
 
 			if d.Sha != c.Sha {
@@ -606,6 +1368,69 @@ func getUpdatedDeps(previous, deps []dependency, ignored []string, cache Cache)
 	return updated, nil
 }
 
+// resolveDependency sets the sha of the dependency when not known from
+// the version, using the module proxy when configured or otherwise
+// resolving the git url and querying the remote. The method used to
+// resolve the sha is returned.
+func resolveDependency(dep *dependency, cache Cache) (string, error) {
+	if dep.Sha != "" {
+		return "version", nil
+	}
+	if err := resolveFromProxy(dep, cache); err == nil {
+		return "proxy", nil
+	}
+	if dep.GitURL == "" {
+		gitURL, err := resolveGitURL(dep.Name, cache)
+		if err != nil {
+			return "", fmt.Errorf("git url for %s: %w", dep.Name, err)
+		}
+		dep.GitURL = gitURL
+	}
+	sha, err := getSha(dep.GitURL, dep.Ref, cache)
+	if err != nil {
+		return "", fmt.Errorf("failed to get sha for %s: %w", dep.Name, err)
+	}
+	dep.Sha = sha
+	return "git", nil
+}
+
+// formatChanges sets the formatted text of the changes, when enriched the
+// changes are processed using the github repository
+func formatChanges(changes []*change, repo, linkName string, cache Cache, enrich, short, skipCommits bool) error {
+	if !enrich {
+		for _, change := range changes {
+			change.Formatted = fmt.Sprintf("* %s %s", change.Commit, change.Description)
+		}
+		return nil
+	}
+	if githubConcurrency > 1 {
+		cache = newMemoryCache(cache)
+	}
+	processor := changeProcessorFor(repo, linkName, cache)
+	if p, ok := processor.(*githubChangeProcessor); ok {
+		p.squash = squashMerged(changes)
+		if err := prefetchPRInfo(repo, changes, cache, p.squash); err != nil {
+			return err
+		}
+		if githubConcurrency > 1 {
+			p.fetchConcurrently(changes, githubConcurrency)
+		}
+	}
+	for _, change := range changes {
+		if err := processor.process(change); err != nil {
+			return err
+		}
+		if !change.IsMerge {
+			if skipCommits {
+				change.Formatted = ""
+			} else if short {
+				change.Formatted = change.Title
+			}
+		}
+	}
+	return nil
+}
+
 func toDepMap(deps []dependency) map[string]dependency {
 	out := make(map[string]dependency)
 	for _, d := range deps {
@@ -614,25 +1439,52 @@ func toDepMap(deps []dependency) map[string]dependency {
 	return out
 }
 
-func addContributors(previous, commit string, contributors map[string]contributor) error {
-	raw, err := git("log", `--format=%aE %aN`, gitChangeDiff(previous, commit))
-	if err != nil {
-		return err
-	}
-	s := bufio.NewScanner(bytes.NewReader(raw))
-	for s.Scan() {
-		p := strings.SplitN(s.Text(), " ", 2)
-		if len(p) != 2 {
-			return fmt.Errorf("unparsable git log output: %s", s.Text())
+// addContributors adds the authors of the changes to the contributors,
+// skipping bots and excluded contributors
+func addContributors(changes []*change, contributors map[string]contributor, excluded []string) {
+	for _, c := range changes {
+		name, email := c.AuthorName, c.AuthorEmail
+		if isBot(name) {
+			logrus.Debugf("Skipping bot contributor: %s <%s>", name, email)
+			continue
 		}
-		name := p[1]
-		if name == "bot" || strings.Contains(name, "[bot]") {
-			logrus.Debugf("Skipping bot contributor: %s <%s>", name, p[0])
+		if isExcludedContributor(name, email, excluded) {
+			logrus.Debugf("Skipping excluded contributor: %s <%s>", name, email)
 			continue
 		}
-		addContributor(contributors, name, p[0])
+		addContributor(contributors, name, email)
 	}
-	return s.Err()
+}
+
+// isBot returns whether the contributor name is of a bot account
+func isBot(name string) bool {
+	return name == "bot" || strings.Contains(name, "[bot]")
+}
+
+// hasContributedChanges returns whether any of the changes is authored by a
+// contributor which is neither a bot nor excluded. Merge commits are ignored
+// as they are authored by whoever merged the change.
+func hasContributedChanges(changes []*change, excluded []string) bool {
+	for _, c := range changes {
+		if strings.HasPrefix(c.Description, "Merge ") {
+			continue
+		}
+		if !isBot(c.AuthorName) && !isExcludedContributor(c.AuthorName, c.AuthorEmail, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcludedContributor returns whether the name or email of the contributor
+// matches one of the excluded names or emails, ignoring case
+func isExcludedContributor(name, email string, excluded []string) bool {
+	for _, e := range excluded {
+		if strings.EqualFold(e, name) || strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
 }
 
 func addContributor(contributors map[string]contributor, name, email string) {
@@ -668,11 +1520,70 @@ func orderContributors(contributors map[string]contributor) []contributor {
 	}
 	sort.Slice(all, func(i, j int) bool {
 		if all[i].Commits == all[j].Commits {
+			if all[i].Name == all[j].Name {
+				return all[i].Email < all[j].Email
+			}
 			return all[i].Name < all[j].Name
 		}
 		return all[i].Commits > all[j].Commits
 	})
 
+	for _, suggestion := range mailmapSuggestions(all) {
+		logrus.Info("Mailmap suggestion: " + suggestion)
+		warnings.record(warningMailmap, nil, suggestion)
+	}
+
+	return all
+}
+
+// mergeMailmaps writes the central mailmap, read from a file or url, followed
+// by the repository mailmap to a temporary file and returns its path. Entries
+// of the repository mailmap come last so they take precedence.
+func mergeMailmaps(central, repoMailmap string) (string, error) {
+	var b bytes.Buffer
+	if strings.HasPrefix(central, "https://") || strings.HasPrefix(central, "http://") {
+		resp, err := httpClient.Get(central)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return "", fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, central)
+		}
+		if _, err := io.Copy(&b, resp.Body); err != nil {
+			return "", err
+		}
+	} else {
+		content, err := os.ReadFile(central)
+		if err != nil {
+			return "", err
+		}
+		b.Write(content)
+	}
+	if b.Len() > 0 && b.Bytes()[b.Len()-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	content, err := os.ReadFile(repoMailmap)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	b.Write(content)
+
+	f, err := os.CreateTemp("", "mailmap-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(b.Bytes()); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// mailmapSuggestions returns suggested mailmap entries for contributors
+// with multiple names or emails
+func mailmapSuggestions(all []contributor) []string {
 	nameEmail := map[string]string{}
 	suggestions := []string{}
 	for i := range all {
@@ -686,23 +1597,36 @@ func orderContributors(contributors map[string]contributor) []contributor {
 			nameEmail[all[i].Name] = all[i].Email
 		}
 	}
-	for _, suggestion := range suggestions {
-		logrus.Info("Mailmap suggestion: " + suggestion)
-	}
-
-	return all
+	return suggestions
 }
 
-func groupHighlights(changes []projectChange) []highlightCategory {
+// Names of the highlight categories which may also list changes from
+// other categories
+const (
+	highlightBreaking     = "Breaking"
+	highlightDeprecations = "Deprecations"
+)
+
+// highlightActionRequired is the first highlight category, listing the
+// changes requiring action when upgrading
+const highlightActionRequired = "Action Required"
+
+func groupHighlights(changes []projectChange, sections []highlightSection) []highlightCategory {
+	actionRequired := []highlightChange{}
 	security := []highlightChange{}
 	deprecation := []highlightChange{}
 	breaking := []highlightChange{}
 	categories := map[string][]highlightChange{}
 	categoryList := []string{}
+	custom := make([][]highlightChange, len(sections))
 	for _, project := range changes {
 		for _, c := range project.Changes {
 			if c.IsSecurity {
 				security = append(security, getHighlightChange(project.Name, c))
+			} else if c.IsActionRequired {
+				actionRequired = append(actionRequired, getHighlightChange(project.Name, c))
+			} else if i := matchHighlightSection(c, sections); i >= 0 {
+				custom[i] = append(custom[i], getHighlightChange(project.Name, c))
 			} else if c.IsHighlight {
 				cc, ok := categories[c.Category]
 				if !ok {
@@ -719,7 +1643,29 @@ func groupHighlights(changes []projectChange) []highlightCategory {
 			}
 		}
 	}
-	highlights := make([]highlightCategory, 0, len(categories)+3)
+	highlights := make([]highlightCategory, 0, len(categories)+len(sections)+4)
+	if len(actionRequired) > 0 {
+		highlights = append(highlights, highlightCategory{
+			Name:    highlightActionRequired,
+			Changes: actionRequired,
+		})
+	}
+	order := make([]int, len(sections))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return sections[order[i]].Order < sections[order[j]].Order
+	})
+	for _, i := range order {
+		if len(custom[i]) == 0 {
+			continue
+		}
+		highlights = append(highlights, highlightCategory{
+			Name:    sections[i].Name,
+			Changes: custom[i],
+		})
+	}
 	sort.Strings(categoryList)
 	for _, category := range categoryList {
 		highlights = append(highlights, highlightCategory{
@@ -728,6 +1674,9 @@ func groupHighlights(changes []projectChange) []highlightCategory {
 		})
 	}
 	if len(security) > 0 {
+		sort.SliceStable(security, func(i, j int) bool {
+			return severityRank(security[i].Change.Severity) < severityRank(security[j].Change.Severity)
+		})
 		highlights = append(highlights, highlightCategory{
 			Name:    "Security Advisories",
 			Changes: security,
@@ -735,13 +1684,13 @@ func groupHighlights(changes []projectChange) []highlightCategory {
 	}
 	if len(breaking) > 0 {
 		highlights = append(highlights, highlightCategory{
-			Name:    "Breaking",
+			Name:    highlightBreaking,
 			Changes: breaking,
 		})
 	}
 	if len(deprecation) > 0 {
 		highlights = append(highlights, highlightCategory{
-			Name:    "Deprecations",
+			Name:    highlightDeprecations,
 			Changes: deprecation,
 		})
 	}
@@ -749,6 +1698,250 @@ func groupHighlights(changes []projectChange) []highlightCategory {
 	return highlights
 }
 
+// upgradeNotes returns the changes with an upgrade note from the pull
+// request in the order of the changes
+func upgradeNotes(changes []projectChange) []highlightChange {
+	var notes []highlightChange
+	for _, project := range changes {
+		for _, c := range project.Changes {
+			if c.UpgradeNote != "" {
+				notes = append(notes, highlightChange{Project: project.Name, Change: c})
+			}
+		}
+	}
+	return notes
+}
+
+// missingReleaseNotes returns the pull requests labeled for the changelog
+// which have no release-note block, so the pull request title is used
+func missingReleaseNotes(changes []projectChange) []string {
+	var missing []string
+	for _, project := range changes {
+		for _, c := range project.Changes {
+			if c.IsHighlight && c.PullRequest != 0 && c.ReleaseNote == "" {
+				missing = append(missing, fmt.Sprintf("%s#%d", project.Name, c.PullRequest))
+			}
+		}
+	}
+	return missing
+}
+
+// dedupeHighlights handles changes listed in a highlight category as well as
+// in the breaking or deprecation highlights. With "reference", the change in
+// the category refers to the breaking or deprecation listing. With
+// "annotate", the breaking or deprecation listing notes the category.
+func dedupeHighlights(highlights []highlightCategory, mode string) []highlightCategory {
+	if mode == "" || mode == "repeat" {
+		return highlights
+	}
+	type changeKey struct {
+		project, commit string
+	}
+	var (
+		listedIn   = map[changeKey]string{}
+		categoryOf = map[changeKey]string{}
+	)
+	for _, category := range highlights {
+		if category.Name == highlightBreaking || category.Name == highlightDeprecations {
+			for _, hc := range category.Changes {
+				listedIn[changeKey{hc.Project, hc.Change.Commit}] = category.Name
+			}
+		}
+	}
+	for i, category := range highlights {
+		if category.Name == highlightBreaking || category.Name == highlightDeprecations {
+			continue
+		}
+		for j, hc := range category.Changes {
+			key := changeKey{hc.Project, hc.Change.Commit}
+			listing, ok := listedIn[key]
+			if !ok {
+				continue
+			}
+			if _, ok := categoryOf[key]; !ok {
+				categoryOf[key] = category.Name
+			}
+			if mode == "reference" {
+				c := *hc.Change
+				c.Formatted = c.Title
+				if c.Link != "" {
+					c.Formatted = fmt.Sprintf("[%s](%s)", c.Title, c.Link)
+				}
+				c.Formatted += fmt.Sprintf(" _(see %s)_", listing)
+				highlights[i].Changes[j].Change = &c
+			}
+		}
+	}
+	if mode == "annotate" {
+		for i, category := range highlights {
+			if category.Name != highlightBreaking && category.Name != highlightDeprecations {
+				continue
+			}
+			for j, hc := range category.Changes {
+				if name, ok := categoryOf[changeKey{hc.Project, hc.Change.Commit}]; ok {
+					c := *hc.Change
+					c.Formatted = fmt.Sprintf("%s _(also listed in %s)_", c.Formatted, name)
+					highlights[i].Changes[j].Change = &c
+				}
+			}
+		}
+	}
+	return highlights
+}
+
+// severityRank returns the order of an advisory severity, from most to
+// least severe with unknown severities last
+func severityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 0
+	case "high":
+		return 1
+	case "medium", "moderate":
+		return 2
+	case "low":
+		return 3
+	}
+	return 4
+}
+
+// matchHighlightSection returns the index of the first custom highlight
+// section matching the change or -1 if none match. Only merged pull
+// requests are considered so individual commits are not listed twice.
+func matchHighlightSection(c *change, sections []highlightSection) int {
+	if !c.IsMerge {
+		return -1
+	}
+	for i, section := range sections {
+		for _, label := range section.Labels {
+			if hasLabel(c, label) {
+				return i
+			}
+		}
+		if section.re != nil && section.re.MatchString(c.Title) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyBreakingChanges marks the changes listed in the breaking section of
+// the release file as breaking. Entries are keyed by commit and matched to
+// the pull request which merged the commit, so that the pull request title,
+// labels and release note are used. Entries which cannot be matched are
+// returned as new changes using their description.
+func applyBreakingChanges(changes []*change, head string, breaking map[string]*change) []*change {
+	keys := make([]string, 0, len(breaking))
+	for key := range breaking {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var unmatched []*change
+	for _, key := range keys {
+		entry := breaking[key]
+		commit := entry.Commit
+		if commit == "" {
+			commit = key
+		}
+		c := findChange(changes, commit, head)
+		if c == nil {
+			logrus.Debugf("Breaking change %s not found in changes, using description", commit)
+			unmatched = append(unmatched, &change{
+				Commit:      commit,
+				Description: entry.Description,
+				Title:       entry.Description,
+				IsBreaking:  true,
+				Formatted:   entry.Description,
+			})
+			continue
+		}
+		if !c.IsMerge && entry.Description != "" {
+			setTitle(c, entry.Description)
+		} else if c.ReleaseNote != "" {
+			setTitle(c, releaseNoteTitle(c.ReleaseNote))
+		}
+		c.IsBreaking = true
+	}
+	return unmatched
+}
+
+// setTitle replaces the title of the change along with the title in its
+// formatted text, which starts with the title of pull requests and ends with
+// the description of commits
+func setTitle(c *change, title string) {
+	if c.IsMerge && strings.HasPrefix(c.Formatted, c.Title) {
+		c.Formatted = title + strings.TrimPrefix(c.Formatted, c.Title)
+	} else if !c.IsMerge && strings.HasSuffix(c.Formatted, c.Title) {
+		c.Formatted = strings.TrimSuffix(c.Formatted, c.Title) + title
+	}
+	c.Title = title
+}
+
+// validateBreakingChanges returns an error if any commit in the breaking
+// section of the release file is not within the release range
+func validateBreakingChanges(previous, head string, breaking map[string]*change) error {
+	var missing []string
+	for key, entry := range breaking {
+		commit := entry.Commit
+		if commit == "" {
+			commit = key
+		}
+		if !inRange(commit, previous, head) {
+			missing = append(missing, commit)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("breaking changes not found in release range: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// inRange returns whether the commit is reachable from head but not from
+// previous
+func inRange(commit, previous, head string) bool {
+	full, err := resolveCommit(commit)
+	if err != nil {
+		return false
+	}
+	if _, err := git("merge-base", "--is-ancestor", full, head); err != nil {
+		return false
+	}
+	if previous != "" {
+		if _, err := git("merge-base", "--is-ancestor", full, previous); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// findChange returns the change for the pull request which merged the
+// commit, or the change for the commit itself when not merged through a
+// pull request. Nil is returned if the commit is not found in the changes.
+func findChange(changes []*change, commit, head string) *change {
+	full, err := resolveCommit(commit)
+	if err != nil {
+		return nil
+	}
+	var merge string
+	if out, err := git("log", "--merges", "--ancestry-path", "--reverse", "--format=%H", full+".."+head); err == nil {
+		if fields := strings.Fields(string(out)); len(fields) > 0 {
+			merge = fields[0]
+		}
+	}
+	var found *change
+	for _, c := range changes {
+		if merge != "" && strings.HasPrefix(merge, c.Commit) {
+			return c
+		}
+		if found == nil && strings.HasPrefix(full, c.Commit) {
+			found = c
+		}
+	}
+	return found
+}
+
 func getHighlightChange(project string, c *change) highlightChange {
 	return highlightChange{
 		Project: project,
@@ -782,10 +1975,32 @@ func resolveGitURL(name string, cache Cache) (string, error) {
 		return string(b), nil
 	}
 
-	resp, err := http.Get(u)
+	// Strip path segments on failure since sub modules or retracted
+	// paths may not serve the go-import meta tag
+	var firstErr error
+	for try := name; strings.Contains(try, "/"); try = path.Dir(try) {
+		resolved, err := getGoImport(try)
+		if err == nil {
+			cache.Put(u, []byte(resolved))
+			return resolved, nil
+		}
+		logrus.WithError(err).Debugf("go-get resolution failed for %s", try)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", firstErr
+}
+
+// getGoImport returns the git repository from the go-import meta tag served
+// for the module path
+func getGoImport(name string) (string, error) {
+	u := "https://" + name + "?go-get=1"
+	resp, err := httpClient.Get(u)
 	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
 		return "", fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, u)
 	}
@@ -795,8 +2010,8 @@ func resolveGitURL(name string, cache Cache) (string, error) {
 		switch t.Next() {
 		case html.ErrorToken:
 			err := t.Err()
-			if err == nil {
-				err = errors.New("no go-import meta tag")
+			if err == nil || err == io.EOF {
+				err = fmt.Errorf("no go-import meta tag for %s", u)
 			}
 			return "", err
 		case html.StartTagToken, html.SelfClosingTagToken:
@@ -814,9 +2029,7 @@ func resolveGitURL(name string, cache Cache) (string, error) {
 			if name == "go-import" {
 				parts := strings.Fields(content)
 				if len(parts) == 3 && parts[1] == "git" {
-					resolved := parts[2]
-					cache.Put(u, []byte(resolved))
-					return resolved, nil
+					return parts[2], nil
 				}
 			}
 		}
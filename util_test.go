@@ -16,7 +16,19 @@
 
 package main
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestParseModuleCommit(t *testing.T) {
 	for i, tc := range []struct {
@@ -67,3 +79,1112 @@ func TestGetGitURL(t *testing.T) {
 	}
 
 }
+
+func TestGroupHighlightsSections(t *testing.T) {
+	sections := []highlightSection{
+		{Name: "Windows", Labels: []string{"os/windows"}, Order: 2},
+		{Name: "CRI", re: regexp.MustCompile(`(?i)\bcri\b`), Order: 1},
+	}
+	changes := []projectChange{{Changes: []*change{
+		{Title: "Fix hcs shim", Labels: []string{"os/windows"}, IsMerge: true, IsHighlight: true, Category: "Runtime"},
+		{Title: "Update CRI plugin", IsMerge: true},
+		{Title: "Add new snapshotter", IsMerge: true, IsHighlight: true, Category: "Snapshotters"},
+		{Title: "CRI commit without PR"},
+	}}}
+
+	highlights := groupHighlights(changes, sections)
+	expected := []string{"CRI", "Windows", "Snapshotters"}
+	if len(highlights) != len(expected) {
+		t.Fatalf("unexpected highlights %v", highlights)
+	}
+	for i, name := range expected {
+		if highlights[i].Name != name {
+			t.Errorf("[%d] unexpected category %q, expected %q", i, highlights[i].Name, name)
+		}
+		if len(highlights[i].Changes) != 1 {
+			t.Errorf("[%d] unexpected %d changes in %q", i, len(highlights[i].Changes), name)
+		}
+	}
+}
+
+func TestOrderContributors(t *testing.T) {
+	contributors := map[string]contributor{}
+	for _, c := range []struct{ name, email string }{
+		{"Jane Doe", "jane@example.com"},
+		{"Jane Doe", "jane@other.example.com"},
+		{"Jane Doe", "jane@other.example.com"},
+		{"Alex Smith", "alex@example.com"},
+		{"Ann Other", "ann@example.com"},
+		{"Jane Doe", "doe@example.com"},
+	} {
+		addContributor(contributors, c.name, c.email)
+	}
+
+	for i := 0; i < 10; i++ {
+		ordered := orderContributors(contributors)
+		expected := []string{"jane@other.example.com", "alex@example.com", "ann@example.com", "doe@example.com", "jane@example.com"}
+		for j, email := range expected {
+			if ordered[j].Email != email {
+				t.Fatalf("[%d] unexpected contributor %q, expected %q", j, ordered[j].Email, email)
+			}
+		}
+	}
+}
+
+func TestParseChangelogContributors(t *testing.T) {
+	raw := "abc1234\x00jane@example.com\x00Jane Doe\x00Add  shim cleanup\n" +
+		"def5678\x00bot@example.com\x00dependabot[bot]\x00Bump golang.org/x/net\n" +
+		"0123abc\x00jane@example.com\x00Jane Doe\x00Fix shim cleanup\n"
+	changes, err := parseChangelog([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 || changes[0].Commit != "abc1234" || changes[0].Description != "Add shim cleanup" || changes[1].AuthorName != "dependabot[bot]" {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+
+	contributors := map[string]contributor{}
+	addContributors(changes, contributors, nil)
+	if len(contributors) != 1 || contributors["jane@example.com"].Commits != 2 {
+		t.Errorf("unexpected contributors %+v", contributors)
+	}
+
+	if _, err := parseChangelog([]byte("abc1234 Add shim cleanup\n")); err == nil {
+		t.Error("expected error for unparsable line")
+	}
+}
+
+func TestHasContributedChanges(t *testing.T) {
+	for _, tc := range []struct {
+		changes  []*change
+		expected bool
+	}{
+		{nil, false},
+		{[]*change{
+			{Description: "Bump golang.org/x/net from 0.7.0 to 0.8.0", AuthorName: "dependabot[bot]"},
+			{Description: "Merge pull request #1 from dependabot/go_modules/golang.org/x/net-0.8.0", AuthorName: "Jane Doe"},
+			{Description: "Update vendor", AuthorName: "Release Bot", AuthorEmail: "release@example.com"},
+		}, false},
+		{[]*change{
+			{Description: "Bump golang.org/x/net from 0.7.0 to 0.8.0", AuthorName: "dependabot[bot]"},
+			{Description: "Fix shim cleanup", AuthorName: "Jane Doe", AuthorEmail: "jane@example.com"},
+		}, true},
+	} {
+		if actual := hasContributedChanges(tc.changes, []string{"release@example.com"}); actual != tc.expected {
+			t.Errorf("expected %t for %+v", tc.expected, tc.changes)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		str  string
+		size int64
+	}{
+		{"1024", 1024},
+		{"10K", 10 << 10},
+		{"512M", 512 << 20},
+		{"20G", 20 << 30},
+		{"20GB", 20 << 30},
+		{"1t", 1 << 40},
+	} {
+		size, err := parseSize(tc.str)
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.str, err)
+		}
+		if size != tc.size {
+			t.Errorf("[%s] unexpected size %d, expected %d", tc.str, size, tc.size)
+		}
+	}
+	if _, err := parseSize("lots"); err == nil {
+		t.Error("expected error parsing invalid size")
+	}
+}
+
+func TestGroupHighlightsSecuritySeverity(t *testing.T) {
+	changes := []projectChange{{Changes: []*change{
+		{Title: "unknown", IsSecurity: true},
+		{Title: "low", IsSecurity: true, Severity: "low"},
+		{Title: "critical", IsSecurity: true, Severity: "critical"},
+		{Title: "moderate", IsSecurity: true, Severity: "moderate"},
+		{Title: "high", IsSecurity: true, Severity: "high"},
+	}}}
+	highlights := groupHighlights(changes, nil)
+	if len(highlights) != 1 {
+		t.Fatalf("unexpected highlights %v", highlights)
+	}
+	for i, expected := range []string{"critical", "high", "moderate", "low", "unknown"} {
+		if title := highlights[0].Changes[i].Change.Title; title != expected {
+			t.Errorf("[%d] unexpected advisory %q, expected %q", i, title, expected)
+		}
+	}
+}
+
+func TestGroupHighlightsActionRequired(t *testing.T) {
+	changes := []projectChange{{Changes: []*change{
+		{Title: "Add feature", IsHighlight: true, Category: "Runtime"},
+		{Title: "Remove config", IsHighlight: true, Category: "Runtime", IsActionRequired: true},
+	}}}
+	highlights := groupHighlights(changes, nil)
+	if len(highlights) != 2 || highlights[0].Name != highlightActionRequired || highlights[0].Changes[0].Change.Title != "Remove config" {
+		t.Fatalf("unexpected highlights %v", highlights)
+	}
+	if len(highlights[1].Changes) != 1 || highlights[1].Changes[0].Change.Title != "Add feature" {
+		t.Errorf("unexpected runtime highlights %v", highlights[1].Changes)
+	}
+}
+
+func TestMissingReleaseNotes(t *testing.T) {
+	changes := []projectChange{
+		{Changes: []*change{
+			{PullRequest: 1, IsHighlight: true, ReleaseNote: "Add feature"},
+			{PullRequest: 2, IsHighlight: true},
+			{PullRequest: 3},
+		}},
+		{Name: "ttrpc", Changes: []*change{
+			{PullRequest: 4, IsHighlight: true},
+		}},
+	}
+	missing := missingReleaseNotes(changes)
+	expected := []string{"#2", "ttrpc#4"}
+	if len(missing) != len(expected) {
+		t.Fatalf("unexpected missing release notes %v", missing)
+	}
+	for i, ref := range expected {
+		if missing[i] != ref {
+			t.Errorf("[%d] unexpected pull request %q, expected %q", i, missing[i], ref)
+		}
+	}
+}
+
+func TestCheckReplaces(t *testing.T) {
+	replaced := map[string]replacedModule{
+		"github.com/containerd/ttrpc":          {Old: "github.com/containerd/ttrpc", New: "github.com/fork/ttrpc", Version: "v1.2.2"},
+		"github.com/containerd/log":            {Old: "github.com/containerd/log", New: "../log"},
+		"golang.org/x/sys":                     {Old: "golang.org/x/sys", New: "github.com/fork/sys", Version: "v0.5.0"},
+		"github.com/containerd/containerd/api": {Old: "github.com/containerd/containerd/api", New: "./api"},
+	}
+	all, unexpected := checkReplaces(replaced, replacePolicy{Allow: []string{"github.com/containerd/*", "github.com/containerd/containerd/..."}})
+	if len(all) != 4 || all[0].Old != "github.com/containerd/containerd/api" || all[3].Old != "golang.org/x/sys" {
+		t.Errorf("unexpected replaced modules %v", all)
+	}
+	if len(unexpected) != 1 || unexpected[0].Old != "golang.org/x/sys" || unexpected[0].New != "github.com/fork/sys" {
+		t.Errorf("unexpected disallowed modules %v", unexpected)
+	}
+}
+
+func TestMatchModulePattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		module  string
+		matched bool
+	}{
+		{"github.com/containerd/*", "github.com/containerd/log", true},
+		{"github.com/containerd/*", "github.com/containerd/containerd/api", false},
+		{"github.com/containerd/...", "github.com/containerd/containerd/api", true},
+		{"github.com/containerd/...", "github.com/containerd", true},
+		{"github.com/containerd/...", "github.com/containerdx/log", false},
+		{"github.com/*/...", "github.com/containerd/containerd/api", true},
+		{"golang.org/x/sys", "golang.org/x/sys", true},
+		{"golang.org/x/sys", "golang.org/x/sys/unix", false},
+	} {
+		if matched := matchModulePattern(tc.pattern, tc.module); matched != tc.matched {
+			t.Errorf("%s %s: expected %t", tc.pattern, tc.module, tc.matched)
+		}
+	}
+}
+
+func TestLoadReleaseInvalidPattern(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "release.toml")
+	for _, tc := range []struct {
+		config string
+		err    string
+	}{
+		{"[replace_policy]\nallow = [\"github.com/[containerd\"]", `invalid replace_policy.allow pattern "github.com/[containerd"`},
+		{`tool_deps = ["github.com/[/..."]`, `invalid tool_deps pattern "github.com/[/..."`},
+		{`test_deps = ["github.com/stretchr/*", "["]`, `invalid test_deps pattern "["`},
+		{"test_deps = [\"github.com/stretchr/...\"]\n[replace_policy]\nallow = [\"github.com/containerd/...\"]", ""},
+	} {
+		if err := os.WriteFile(p, []byte(tc.config+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadRelease(p)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("[%s] unexpected error %v", tc.config, err)
+			}
+		} else if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+			t.Errorf("[%s] expected error %q, got %v", tc.config, tc.err, err)
+		}
+	}
+}
+
+func TestParseReplacedModules(t *testing.T) {
+	defer func(report *warningReport) {
+		warnings = report
+	}(warnings)
+	warnings = &warningReport{}
+
+	goMod := `module github.com/containerd/containerd
+
+go 1.21
+
+toolchain go1.21.5
+
+require (
+	github.com/containerd/ttrpc v1.2.1
+	github.com/containerd/log v0.1.0
+)
+
+replace github.com/containerd/ttrpc => github.com/fork/ttrpc v1.2.2
+
+replace github.com/containerd/log => ../log
+`
+	modulesTxt := `# github.com/containerd/ttrpc v1.2.1 => github.com/fork/ttrpc v1.2.2
+## explicit
+github.com/containerd/ttrpc
+# github.com/containerd/log => ../log
+`
+	expected := map[string]replacedModule{
+		"github.com/containerd/ttrpc": {Old: "github.com/containerd/ttrpc", New: "github.com/fork/ttrpc", Version: "v1.2.2"},
+		"github.com/containerd/log":   {Old: "github.com/containerd/log", New: "../log"},
+	}
+	for name, parse := range map[string]func() (map[string]replacedModule, error){
+		"go.mod": func() (map[string]replacedModule, error) {
+			replaced := map[string]replacedModule{}
+			_, err := parseGoModDependencies(strings.NewReader(goMod), replaced)
+			return replaced, err
+		},
+		"modules.txt": func() (map[string]replacedModule, error) {
+			replaced := map[string]replacedModule{}
+			_, err := parseModulesTxtDependencies(strings.NewReader(modulesTxt), replaced)
+			return replaced, err
+		},
+	} {
+		replaced, err := parse()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(replaced, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, replaced)
+		}
+	}
+	if found := warnings.ofKind(warningReplace); len(found) != 0 {
+		t.Errorf("unexpected warnings %+v", found)
+	}
+
+	// Directives unknown to this version fall back to lax parsing, which
+	// ignores replace directives
+	replaced := map[string]replacedModule{}
+	if _, err := parseGoModDependencies(strings.NewReader(goMod+"\nunknown v1\n"), replaced); err != nil {
+		t.Fatal(err)
+	}
+	if len(replaced) != 0 {
+		t.Errorf("unexpected replaced modules %v", replaced)
+	}
+	if found := warnings.ofKind(warningReplace); len(found) != 1 || found[0].Message != "Unable to parse go.mod strictly, ignoring replace directives" {
+		t.Errorf("unexpected warnings %+v", found)
+	}
+}
+
+func TestFilesFromRev(t *testing.T) {
+	files, err := filesFromRev("HEAD", []string{vendorConf, goMod, modulesTxt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := files[vendorConf]; ok {
+		t.Errorf("unexpected %s", vendorConf)
+	}
+	if !bytes.HasPrefix(files[goMod], []byte("module github.com/containerd/release-tool")) {
+		t.Errorf("unexpected %s content %q", goMod, files[goMod])
+	}
+	if len(files[modulesTxt]) == 0 {
+		t.Errorf("expected %s content", modulesTxt)
+	}
+}
+
+func TestParseDependenciesCached(t *testing.T) {
+	cache := &dirCache{root: t.TempDir()}
+	deps, err := parseDependencies("HEAD", "", nil, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) == 0 {
+		t.Fatal("expected dependencies")
+	}
+	sha, err := git("rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get("dependencies " + strings.TrimSpace(string(sha)) + "  json"); !ok {
+		t.Fatal("expected parsed dependencies to be cached by commit")
+	}
+	cached, err := parseDependencies("HEAD", "", nil, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deps, cached) {
+		t.Errorf("expected cached dependencies %v, got %v", deps, cached)
+	}
+}
+
+func TestGetUpdatedDepsBestEffort(t *testing.T) {
+	// The remote is not queried offline, so the versions are not resolved
+	defer func(o bool) {
+		offline = o
+	}(offline)
+	offline = true
+	t.Setenv("GOPROXY", "off")
+	gitURL := "https://example.com/missing.git"
+	previous := []dependency{
+		{Name: "example.com/missing", Ref: "v1.0.0", GitURL: gitURL},
+		{Name: "example.com/same", Ref: "v1.0.0", Sha: "0123456789ab"},
+	}
+	current := []dependency{
+		{Name: "example.com/missing", Ref: "v1.1.0", GitURL: gitURL},
+		{Name: "example.com/same", Ref: "v1.0.0", Sha: "0123456789ab"},
+	}
+
+	if _, err := getUpdatedDeps(previous, current, nil, nilCache{}, false); err == nil {
+		t.Fatal("expected error for unresolved dependency")
+	}
+	updated, err := getUpdatedDeps(previous, current, nil, nilCache{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 1 || updated[0].Name != "example.com/missing" || updated[0].Previous != "v1.0.0" || updated[0].Unresolved == "" {
+		t.Errorf("expected unresolved dependency, got %+v", updated)
+	}
+}
+
+// testRepo is a git repository in a temporary directory, GIT_DIR points to
+// it for the rest of the test
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+// newTestRepo initializes a repository with the files committed, or without
+// any commit when no files are given
+func newTestRepo(t *testing.T, files map[string]string) *testRepo {
+	t.Helper()
+	tr := &testRepo{t: t, dir: t.TempDir()}
+	tr.git("init", "-q")
+	t.Setenv("GIT_DIR", filepath.Join(tr.dir, ".git"))
+	if len(files) > 0 {
+		tr.commit("Initial commit", files)
+	}
+	return tr
+}
+
+// git runs git in the repository as the test user, returning the output
+// without the trailing new line
+func (tr *testRepo) git(args ...string) string {
+	tr.t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = tr.dir
+	cmd.Env = append(os.Environ(), "GIT_DIR="+filepath.Join(tr.dir, ".git"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		tr.t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// write writes the files to the work tree
+func (tr *testRepo) write(files map[string]string) {
+	tr.t.Helper()
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Join(tr.dir, filepath.Dir(name)), 0755); err != nil {
+			tr.t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tr.dir, name), []byte(content), 0644); err != nil {
+			tr.t.Fatal(err)
+		}
+	}
+}
+
+// commit writes the files and commits all changes of the work tree,
+// returning the commit sha
+func (tr *testRepo) commit(message string, files map[string]string) string {
+	tr.t.Helper()
+	tr.write(files)
+	tr.git("add", "-A")
+	tr.git("commit", "-q", "--allow-empty", "-m", message)
+	return tr.git("rev-parse", "HEAD")
+}
+
+func TestNewRetractions(t *testing.T) {
+	tr := newTestRepo(t, map[string]string{"go.mod": "module example.com/mod\n\ngo 1.21\n\nretract v1.0.0 // Published accidentally\n"})
+	tr.commit("Retract v1.1", map[string]string{"go.mod": "module example.com/mod\n\ngo 1.21\n\nretract (\n\tv1.0.0 // Published accidentally\n\t[v1.1.0, v1.1.2] // Data race in shim cleanup\n)\n"})
+
+	for _, tc := range []struct {
+		previous string
+		expected []retraction
+	}{
+		{"HEAD~1", []retraction{{Low: "v1.1.0", High: "v1.1.2", Rationale: "Data race in shim cleanup"}}},
+		{"HEAD", nil},
+		{"", []retraction{
+			{Low: "v1.0.0", High: "v1.0.0", Rationale: "Published accidentally"},
+			{Low: "v1.1.0", High: "v1.1.2", Rationale: "Data race in shim cleanup"},
+		}},
+	} {
+		retractions, err := newRetractions(tc.previous, "HEAD", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(retractions, tc.expected) {
+			t.Errorf("expected %+v since %q, got %+v", tc.expected, tc.previous, retractions)
+		}
+	}
+	if retractions, err := newRetractions("HEAD~1", "HEAD", "api"); err != nil || retractions != nil {
+		t.Errorf("expected no retractions without a go.mod, got %+v: %v", retractions, err)
+	}
+}
+
+func TestValidateGoVersion(t *testing.T) {
+	newTestRepo(t, map[string]string{
+		"go.mod":     "module example.com/mod\n\ngo 1.21\n\ntoolchain go1.21.5\n",
+		"api/go.mod": "module example.com/mod/api\n\ngo 1.20\n",
+	})
+
+	for _, tc := range []struct {
+		subpath string
+		version string
+		err     string
+	}{
+		{"", "1.21.5", ""},
+		{"", "go1.21.5", ""},
+		{"", "1.21.6", ""},
+		{"", "go1.22.0", ""},
+		{"", "1.21.4", "go version 1.21.4 is older than go.mod toolchain 1.21.5"},
+		{"api", "1.20.3", ""},
+		{"api", "1.21.0", ""},
+		{"api", "1.19.9", "go version 1.19.9 is older than go.mod go 1.20"},
+		{"missing", "1.19.9", ""},
+		{"", "", ""},
+	} {
+		err := validateGoVersion("HEAD", tc.subpath, tc.version)
+		if tc.err == "" && err != nil {
+			t.Errorf("unexpected error for %q in %q: %v", tc.version, tc.subpath, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("expected error %q for %q in %q, got %v", tc.err, tc.version, tc.subpath, err)
+		}
+	}
+}
+
+func TestTagMessage(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	tr.commit("Initial commit", nil)
+	tr.git("tag", "-a", "v1.0.0", "-m", "containerd 1.0.0\n\nWelcome to the v1.0.0 release of containerd!")
+	tr.git("tag", "v1.0.1")
+
+	message, err := tagMessage("v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "containerd 1.0.0\n\nWelcome to the v1.0.0 release of containerd!"; message != expected {
+		t.Errorf("expected %q, got %q", expected, message)
+	}
+	if _, err := tagMessage("v1.0.1"); err == nil {
+		t.Error("expected error for lightweight tag")
+	}
+}
+
+func TestFetchCommit(t *testing.T) {
+	origin := newTestRepo(t, nil)
+	origin.commit("Initial commit", nil)
+	origin.git("tag", "v1.0.0")
+	origin.git("checkout", "-q", "-b", "release/1.0")
+	origin.commit("Prepare v1.0.1", nil)
+	tr := newTestRepo(t, nil)
+	tr.git("remote", "add", "origin", origin.dir)
+
+	for ref, expected := range map[string]string{
+		"v1.0.0":             "v1.0.0",
+		"origin/release/1.0": "release/1.0",
+	} {
+		if _, err := resolveCommit(ref); err == nil {
+			t.Fatalf("expected %s to be missing before fetching", ref)
+		}
+		sha, err := fetchCommit("origin", ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := origin.git("rev-parse", expected+"^{commit}"); sha != out {
+			t.Errorf("expected %s to fetch %s, got %s", ref, out, sha)
+		}
+	}
+}
+
+func TestGenerationTime(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	t.Setenv("GIT_COMMITTER_DATE", "2023-03-01T12:00:00Z")
+	tr.commit("Initial commit", nil)
+
+	for _, tc := range []struct {
+		epoch    string
+		expected string
+	}{
+		{"", "2023-03-01T12:00:00Z"},
+		{"1700000000", "2023-11-14T22:13:20Z"},
+	} {
+		t.Setenv("SOURCE_DATE_EPOCH", tc.epoch)
+		// Generating again gives the same time
+		for i := 0; i < 2; i++ {
+			generated, err := generationTime("HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := generated.Format(time.RFC3339); actual != tc.expected {
+				t.Errorf("expected %s with SOURCE_DATE_EPOCH %q, got %s", tc.expected, tc.epoch, actual)
+			}
+		}
+	}
+}
+
+func TestCommitCount(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	tr.commit("Initial commit", nil)
+	tr.git("tag", "v1.0.0")
+	for _, file := range []string{"api/api.go", "main.go"} {
+		tr.commit("Add "+file, map[string]string{file: "package main\n"})
+	}
+	defer func(subpaths []string) {
+		gitSubpaths = subpaths
+	}(gitSubpaths)
+	gitSubpaths = []string{"api"}
+
+	changes, err := changelog("v1.0.0", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := commitCount("v1.0.0", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || count != 2 {
+		t.Errorf("expected 1 change in api of 2 commits, got %d changes of %d commits", len(changes), count)
+	}
+}
+
+func TestLicenseChanges(t *testing.T) {
+	tr := newTestRepo(t, map[string]string{
+		"LICENSE": "Apache License\n",
+		"NOTICE":  "Notice\n",
+	})
+	tr.git("tag", "v1.0.0")
+	if err := os.Remove(filepath.Join(tr.dir, "NOTICE")); err != nil {
+		t.Fatal(err)
+	}
+	tr.commit("Update licenses", map[string]string{
+		"LICENSE":             "Apache License, Version 2.0\n",
+		"LICENSE THIRD PARTY": "MIT\n",
+		"api/LICENSE":         "Apache License\n",
+		"other/LICENSE":       "MIT\n",
+	})
+
+	changes, err := licenseChanges("v1.0.0", "HEAD", []string{"api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []licenseChange{
+		{File: "LICENSE", Status: "modified"},
+		{File: "LICENSE THIRD PARTY", Status: "added"},
+		{File: "NOTICE", Status: "deleted"},
+		{File: "api/LICENSE", Status: "added"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, changes)
+	}
+}
+
+func TestApplyBreakingChanges(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	first := tr.commit("Remove v1 API", nil)
+	head := tr.commit("Drop aufs", nil)
+
+	changes := []*change{
+		{Commit: head[:12], Title: "Drop aufs", Formatted: "[`" + head[:12] + "`](link) Drop aufs"},
+		{Commit: first[:12], Title: "Remove v1 API", ReleaseNote: "Remove the\nv1 API", IsMerge: true, Formatted: "Remove v1 API ([#1](link))"},
+	}
+	unmatched := applyBreakingChanges(changes, head, map[string]*change{
+		"aufs":   {Commit: head[:12], Description: "The aufs snapshotter was removed"},
+		"api":    {Commit: first[:12]},
+		"config": {Commit: "0123456789ab", Description: "Config version 1 is no longer supported"},
+	})
+	for i, expected := range []string{
+		"[`" + head[:12] + "`](link) The aufs snapshotter was removed",
+		"Remove the v1 API ([#1](link))",
+	} {
+		if !changes[i].IsBreaking || changes[i].Formatted != expected {
+			t.Errorf("expected breaking change %q, got %+v", expected, changes[i])
+		}
+	}
+	if len(unmatched) != 1 || unmatched[0].Formatted != "Config version 1 is no longer supported" {
+		t.Errorf("unexpected unmatched changes %+v", unmatched)
+	}
+}
+
+func TestLoadSections(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "upgrade.md"), []byte("Run the migration\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &release{Sections: []customSection{
+		{Title: "Upgrade guide", File: "upgrade.md", Position: "after-highlights"},
+		{Title: "Acknowledgements", Body: "Thanks"},
+	}}
+	if err := loadSections(r.Sections, dir); err != nil {
+		t.Fatal(err)
+	}
+	if sections := r.SectionsAt("after-highlights"); len(sections) != 1 || sections[0].Body != "Run the migration\n" {
+		t.Errorf("unexpected sections after highlights %v", sections)
+	}
+	if sections := r.SectionsAt("end"); len(sections) != 1 || sections[0].Title != "Acknowledgements" {
+		t.Errorf("unexpected sections at end %v", sections)
+	}
+	if err := loadSections([]customSection{{Title: "Bad", Position: "middle"}}, dir); err == nil {
+		t.Error("expected error for unknown position")
+	}
+}
+
+func TestLoadReleasePrevious(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		config      string
+		previous    string
+		comparisons []string
+	}{
+		{`previous = "v1.7.0"`, "v1.7.0", nil},
+		{`previous = ["v1.7.0", "v1.6.20"]`, "v1.7.0", []string{"v1.6.20"}},
+		{`project_name = "containerd"`, "", nil},
+	} {
+		p := filepath.Join(dir, "release.toml")
+		if err := os.WriteFile(p, []byte(tc.config+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		r, err := loadRelease(p)
+		if err != nil {
+			t.Fatalf("[%s] %v", tc.config, err)
+		}
+		if r.Previous != tc.previous || len(r.Comparisons) != len(tc.comparisons) {
+			t.Fatalf("[%s] unexpected previous %q with comparisons %v", tc.config, r.Previous, r.Comparisons)
+		}
+		for i, c := range r.Comparisons {
+			if c.Previous != tc.comparisons[i] {
+				t.Errorf("[%s] unexpected comparison %q, expected %q", tc.config, c.Previous, tc.comparisons[i])
+			}
+		}
+	}
+}
+
+func TestDedupeHighlights(t *testing.T) {
+	newChanges := func() []projectChange {
+		return []projectChange{{Changes: []*change{
+			{Commit: "aaa", Title: "Remove v1 API", Link: "https://github.com/containerd/containerd/pull/1", Formatted: "Remove v1 API (#1)", ReleaseNote: "The v1 API was removed, use v2", IsMerge: true, IsHighlight: true, IsBreaking: true, Category: "Runtime"},
+			{Commit: "bbb", Title: "Add shim", Formatted: "Add shim (#2)", IsMerge: true, IsHighlight: true, Category: "Runtime"},
+		}}}
+	}
+	for _, tc := range []struct {
+		mode     string
+		runtime  string
+		breaking string
+	}{
+		{"repeat", "Remove v1 API (#1)", "Remove v1 API (#1)"},
+		{"reference", "[Remove v1 API](https://github.com/containerd/containerd/pull/1) _(see Breaking)_", "Remove v1 API (#1)"},
+		{"annotate", "Remove v1 API (#1)", "Remove v1 API (#1) _(also listed in Runtime)_"},
+	} {
+		highlights := dedupeHighlights(groupHighlights(newChanges(), nil), tc.mode)
+		if len(highlights) != 2 || highlights[1].Name != highlightBreaking {
+			t.Fatalf("%s: unexpected highlights %v", tc.mode, highlights)
+		}
+		if f := highlights[0].Changes[0].Change.Formatted; f != tc.runtime {
+			t.Errorf("%s: unexpected category change %q, expected %q", tc.mode, f, tc.runtime)
+		}
+		if f := highlights[0].Changes[1].Change.Formatted; f != "Add shim (#2)" {
+			t.Errorf("%s: unexpected category change %q", tc.mode, f)
+		}
+		if f := highlights[1].Changes[0].Change.Formatted; f != tc.breaking {
+			t.Errorf("%s: unexpected breaking change %q, expected %q", tc.mode, f, tc.breaking)
+		}
+	}
+}
+
+func TestMergeMailmaps(t *testing.T) {
+	dir := t.TempDir()
+	central := filepath.Join(dir, "central")
+	repo := filepath.Join(dir, ".mailmap")
+	if err := os.WriteFile(central, []byte("Jane Doe <jane@example.com> <jdoe@users.noreply.github.com>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(repo, []byte("Jane Doe <jane@containerd.io> <jane@example.com>\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := mergeMailmaps(central, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(merged)
+	b, err := os.ReadFile(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Jane Doe <jane@example.com> <jdoe@users.noreply.github.com>\nJane Doe <jane@containerd.io> <jane@example.com>\n"
+	if string(b) != expected {
+		t.Errorf("unexpected merged mailmap %q", b)
+	}
+
+	if merged, err := mergeMailmaps(central, filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("unexpected error without repository mailmap: %v", err)
+	} else {
+		os.Remove(merged)
+	}
+}
+
+func TestIsExcludedContributor(t *testing.T) {
+	excluded := []string{"Release Automation", "mirror@example.com"}
+	for _, tc := range []struct {
+		name, email string
+		expected    bool
+	}{
+		{"release automation", "ci@example.com", true},
+		{"Jane Doe", "Mirror@example.com", true},
+		{"Jane Doe", "jane@example.com", false},
+	} {
+		if actual := isExcludedContributor(tc.name, tc.email, excluded); actual != tc.expected {
+			t.Errorf("[%s <%s>] unexpected exclusion %t", tc.name, tc.email, actual)
+		}
+	}
+}
+
+// labelProcessor marks changes as highlights or security fixes from their
+// description instead of the pull request labels
+type labelProcessor struct{}
+
+func (labelProcessor) process(c *change) error {
+	c.IsHighlight = strings.HasPrefix(c.Description, "Highlight:")
+	c.IsSecurity = strings.HasPrefix(c.Description, "Security:")
+	c.Formatted = c.Description
+	return nil
+}
+
+func TestWriteBranchSummary(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	for _, release := range []struct {
+		tag     string
+		commits []string
+	}{
+		{"v1.7.0", []string{"Initial release"}},
+		{"v1.7.1", []string{"Highlight: Fix shim cleanup", "Update docs"}},
+		{"v1.7.2", []string{"Security: Fix CVE-2023-0001"}},
+	} {
+		for _, commit := range release.commits {
+			tr.commit(commit, nil)
+		}
+		tr.git("tag", release.tag)
+	}
+
+	for _, tc := range []struct {
+		tags     []string
+		expected string
+	}{
+		{
+			tags: []string{"v1.7.0", "v1.7.1", "v1.7.2"},
+			expected: "# Summary of release/1.7 since v1.7.0\n" +
+				"\n## v1.7.2\n\n1 changes since v1.7.1\n" +
+				"\n### Security Advisories\n\n* Security: Fix CVE-2023-0001\n" +
+				"\n## v1.7.1\n\n2 changes since v1.7.0\n" +
+				"\n### Highlights\n\n* Highlight: Fix shim cleanup\n",
+		},
+		{
+			tags: []string{"v1.7.1", "v1.7.2"},
+			expected: "# Summary of release/1.7 since v1.7.1\n" +
+				"\n## v1.7.2\n\n1 changes since v1.7.1\n" +
+				"\n### Security Advisories\n\n* Security: Fix CVE-2023-0001\n",
+		},
+	} {
+		var b bytes.Buffer
+		if err := writeBranchSummary(&b, "release/1.7", tc.tags, labelProcessor{}); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tc.expected {
+			t.Errorf("%v: expected:\n%s\ngot:\n%s", tc.tags, tc.expected, b.String())
+		}
+	}
+}
+
+func TestResolveCommit(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	shas := []string{tr.commit("Initial commit", nil)}
+	tr.git("tag", "-a", "-m", "Release v1.0.0", "v1.0.0")
+	tr.git("branch", "release/1.0")
+	shas = append(shas, tr.commit("Second commit", nil))
+
+	for _, tc := range []struct {
+		ref string
+		sha string
+		err string
+	}{
+		{"HEAD", shas[1], ""},
+		{"v1.0.0", shas[0], ""},
+		{"release/1.0", shas[0], ""},
+		{shas[1][:12], shas[1], ""},
+		{"v1.0.1", "", `unable to resolve "v1.0.1" to a commit, make sure the ref exists locally`},
+	} {
+		sha, err := resolveCommit(tc.ref)
+		if tc.err == "" && err != nil {
+			t.Errorf("unexpected error for %s: %v", tc.ref, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("expected error %q for %s, got %v", tc.err, tc.ref, err)
+		}
+		if sha != tc.sha {
+			t.Errorf("expected %s to resolve to %q, got %q", tc.ref, tc.sha, sha)
+		}
+	}
+}
+
+func TestCommitDate(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	t.Setenv("GIT_AUTHOR_DATE", "2020-01-01T00:00:00Z")
+	for _, commit := range []struct {
+		message string
+		date    string
+	}{
+		{"Initial commit", "2023-03-01T12:00:00Z"},
+		{"Second commit", "2023-04-02T09:30:00+02:00"},
+	} {
+		t.Setenv("GIT_COMMITTER_DATE", commit.date)
+		tr.commit(commit.message, nil)
+	}
+
+	r := &release{}
+	for _, tc := range []struct {
+		rev      string
+		date     *time.Time
+		expected string
+	}{
+		{"HEAD~1", &r.PreviousDate, "2023-03-01T12:00:00Z"},
+		{"HEAD", &r.CommitDate, "2023-04-02T09:30:00+02:00"},
+	} {
+		date, err := commitDate(tc.rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := date.Format(time.RFC3339); actual != tc.expected {
+			t.Errorf("expected committer date %s for %s, got %s", tc.expected, tc.rev, actual)
+		}
+		*tc.date = date
+	}
+
+	var b bytes.Buffer
+	if err := renderTemplate(&b, `{{.PreviousDate.Format "2006-01-02"}} to {{.CommitDate.Format "2006-01-02"}}`, r); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "2023-03-01 to 2023-04-02"; b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestValidateMaintainers(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	tr.commit("Initial commit", nil)
+	tr.commit("Add maintainers", map[string]string{
+		"MAINTAINERS": "# The containerd maintainers\n\n\"jdoe\",\"Jane Doe\",\"jane@example.com\",\"\"\n\"jroe\",\"John Roe\",\"john@example.com\",\"\"\n",
+	})
+
+	for _, tc := range []struct {
+		commit string
+		people []string
+		err    string
+	}{
+		{"HEAD", nil, ""},
+		{"HEAD", []string{"@jdoe", "John Roe", "JANE@example.com"}, ""},
+		{"HEAD", []string{"@jdoe", "@someone"}, `"@someone" is not listed as a maintainer of the project`},
+		{"HEAD", []string{"jdo"}, `"jdo" is not listed as a maintainer of the project`},
+		{"HEAD", []string{"Jane"}, `"Jane" is not listed as a maintainer of the project`},
+		{"HEAD", []string{"an"}, `"an" is not listed as a maintainer of the project`},
+		// Without a maintainers file the people are not validated
+		{"HEAD~1", []string{"@someone"}, ""},
+	} {
+		err := validateMaintainers(tc.commit, tc.people)
+		if tc.err == "" && err != nil {
+			t.Errorf("unexpected error for %v at %s: %v", tc.people, tc.commit, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("expected error %q for %v at %s, got %v", tc.err, tc.people, tc.commit, err)
+		}
+	}
+}
+
+func TestWarningReport(t *testing.T) {
+	defer func(report *warningReport) {
+		warnings = report
+	}(warnings)
+
+	for _, tc := range []struct {
+		overrides map[string]dependencyOverride
+		expected  string
+	}{
+		{map[string]dependencyOverride{"example.com/a": {Previous: "v1.0.0"}}, `{"warnings":[]}`},
+		{
+			map[string]dependencyOverride{"example.com/a": {Previous: "v1.0.0"}, "example.com/c": {}, "example.com/b": {}},
+			`{"warnings":[` +
+				`{"kind":"override","message":"Dependency override does not match any dependency","fields":{"name":"example.com/b"}},` +
+				`{"kind":"override","message":"Dependency override does not match any dependency","fields":{"name":"example.com/c"}}]}`,
+		},
+	} {
+		warnings = &warningReport{}
+		deps := []dependency{{Name: "example.com/a", Previous: "v0.9.0", Ref: "v1.1.0"}}
+		overrideDependencies(deps, tc.overrides)
+		if deps[0].Previous != "v1.0.0" {
+			t.Errorf("expected overridden previous version, got %s", deps[0].Previous)
+		}
+
+		report := filepath.Join(t.TempDir(), "warnings.json")
+		if err := warnings.write(report); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, b); err != nil {
+			t.Fatal(err)
+		}
+		if compact.String() != tc.expected {
+			t.Errorf("expected report %s, got %s", tc.expected, compact.String())
+		}
+	}
+}
+
+func TestMailmapSuggestions(t *testing.T) {
+	for _, tc := range []struct {
+		contributors []contributor
+		expected     []string
+	}{
+		{
+			[]contributor{{Name: "Jane Doe", Email: "jane@example.com"}, {Name: "John Roe", Email: "john@example.com"}},
+			[]string{},
+		},
+		{
+			[]contributor{{Name: "Jane Doe", Email: "jane@example.com", OtherNames: []string{"jdoe"}}},
+			[]string{`"Jane Doe <jane@example.com>" also has name "jdoe"`},
+		},
+		{
+			[]contributor{{Name: "Jane Doe", Email: "jane@example.com"}, {Name: "John Roe", Email: "john@example.com"}, {Name: "Jane Doe", Email: "jdoe@users.noreply.github.com"}},
+			[]string{`"Jane Doe <jane@example.com> <jdoe@users.noreply.github.com>" has multiple emails`},
+		},
+	} {
+		if suggestions := mailmapSuggestions(tc.contributors); !reflect.DeepEqual(suggestions, tc.expected) {
+			t.Errorf("expected suggestions %q, got %q", tc.expected, suggestions)
+		}
+	}
+}
+
+func TestMaintainerFields(t *testing.T) {
+	for _, tc := range []struct {
+		content  string
+		expected []string
+	}{
+		{
+			"# comment\n\"jdoe\",\"Jane Doe\",\"jane@example.com\",\"0123ABCD\"\n",
+			[]string{"jdoe", "jane doe", "jane@example.com", "0123abcd"},
+		},
+		{
+			"approvers:\n  - jdoe\n  - \"@JRoe\"\nreviewers:\n  - jsmith\n",
+			[]string{"jdoe", "jroe", "jsmith"},
+		},
+	} {
+		fields := maintainerFields([]byte(tc.content))
+		expected := map[string]struct{}{}
+		for _, field := range tc.expected {
+			expected[field] = struct{}{}
+		}
+		if !reflect.DeepEqual(fields, expected) {
+			t.Errorf("expected fields %v, got %v", tc.expected, fields)
+		}
+	}
+}
+
+func TestCompareBaseline(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	var shas []string
+	for _, step := range []struct {
+		files   map[string]string
+		message string
+	}{
+		{map[string]string{"go.mod": "module example.com/mod\n\ngo 1.21\n\nrequire (\n\texample.com/a v1.0.0\n\texample.com/tool v1.0.0\n)\n"}, "Baseline"},
+		{map[string]string{"go.mod": "module example.com/mod\n\ngo 1.21\n\nrequire (\n\texample.com/a v1.0.1\n\texample.com/tool v1.0.0\n)\n"}, "Previous release"},
+		{map[string]string{
+			"go.mod":   "module example.com/mod\n\ngo 1.21\n\nrequire (\n\texample.com/a v1.1.0\n\texample.com/tool v1.1.0\n)\n",
+			"tools.go": "//go:build tools\n\npackage tools\n\nimport _ \"example.com/tool/cmd/tool\"\n",
+		}, "Release"},
+	} {
+		shas = append(shas, tr.commit(step.message, step.files))
+	}
+
+	// The overridden previous versions avoid resolving the dependencies
+	r := &release{
+		CommitSha: shas[2],
+		OverrideDeps: map[string]dependencyOverride{
+			"example.com/a":    {Previous: "v1.0.0"},
+			"example.com/tool": {Previous: "v1.0.0"},
+		},
+	}
+	cache := &dirCache{root: t.TempDir()}
+	current, err := currentDependencies(r, nil, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		excludeDevDeps bool
+		expected       []string
+	}{
+		{false, []string{"example.com/a v1.0.0 -> v1.1.0", "example.com/tool v1.0.0 -> v1.1.0 (tool)"}},
+		{true, []string{"example.com/a v1.0.0 -> v1.1.0"}},
+	} {
+		// The baseline and previous release give the same changes as the
+		// dependency changes of the release
+		for _, previous := range shas[:2] {
+			c := &comparison{PreviousSha: previous}
+			if err := compareBaseline(r, c, current, false, tc.excludeDevDeps, cache, false); err != nil {
+				t.Fatal(err)
+			}
+			updated, err := dependencyChanges(r, previous, current, tc.excludeDevDeps, cache, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.Dependencies, updated) {
+				t.Errorf("expected the baseline changes %+v to match %+v", c.Dependencies, updated)
+			}
+			var changes []string
+			for _, dep := range c.Dependencies {
+				change := fmt.Sprintf("%s %s -> %s", dep.Name, dep.Previous, dep.Ref)
+				if dep.Usage != "" {
+					change += " (" + dep.Usage + ")"
+				}
+				changes = append(changes, change)
+			}
+			if !reflect.DeepEqual(changes, tc.expected) {
+				t.Errorf("expected changes %q since %s, got %q", tc.expected, previous, changes)
+			}
+		}
+	}
+}
+
+func TestChangeRepo(t *testing.T) {
+	for _, tc := range []struct {
+		r        release
+		expected string
+	}{
+		{release{GithubRepo: "containerd/containerd"}, "containerd/containerd"},
+		{release{GithubRepo: "containerd/containerd", GitlabRepo: "containerd/nerdbox"}, gitlabPrefix + "containerd/nerdbox"},
+		{release{GiteaRepo: "codeberg.org/containerd/nerdbox"}, "codeberg.org/containerd/nerdbox"},
+	} {
+		if repo := tc.r.changeRepo(); repo != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, repo)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+type vendoredModule struct {
+	Version  string
+	Explicit bool
+	Packages []string
+
+	// Replace is the path and version of the replacement, or the
+	// directory of a local replacement
+	Replace string
+}
+
+// parseVendoredModules returns the modules listed in vendor/modules.txt by
+// module path, replacement-only entries are ignored
+func parseVendoredModules(r io.Reader) (map[string]*vendoredModule, error) {
+	var (
+		modules = map[string]*vendoredModule{}
+		current *vendoredModule
+		s       = bufio.NewScanner(r)
+	)
+	for s.Scan() {
+		ln := strings.TrimSpace(s.Text())
+		switch {
+		case ln == "":
+		case strings.HasPrefix(ln, "## "):
+			if current != nil {
+				for _, annotation := range strings.Split(strings.TrimPrefix(ln, "## "), ";") {
+					if strings.TrimSpace(annotation) == "explicit" {
+						current.Explicit = true
+					}
+				}
+			}
+		case strings.HasPrefix(ln, "# "):
+			parts := strings.Fields(ln)
+			if len(parts) < 3 || parts[2] == "=>" {
+				// replacement of all versions of a module
+				current = nil
+				continue
+			}
+			current = &vendoredModule{Version: parts[2]}
+			if len(parts) > 4 && parts[3] == "=>" {
+				current.Replace = strings.Join(parts[4:], " ")
+			}
+			modules[parts[1]] = current
+		default:
+			if current != nil {
+				current.Packages = append(current.Packages, ln)
+			}
+		}
+	}
+	return modules, s.Err()
+}
+
+// checkVendorDrift compares the vendor directory at the commit with the
+// go.mod requirements and replacements, like the consistency check done by
+// the go command, and returns the discrepancies found. Nothing is returned
+// when the project does not vendor its dependencies.
+func checkVendorDrift(commit, subpath string) ([]string, error) {
+	subpath = filepath.ToSlash(subpath)
+	name := path.Join(subpath, modulesTxt)
+	out, err := git("ls-tree", "--name-only", commit, "--", name)
+	if err != nil {
+		return nil, err
+	} else if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+	modPath := path.Join(subpath, goMod)
+	files, err := filesFromRev(commit, []string{name, modPath})
+	if err != nil {
+		return nil, err
+	}
+	vendored, err := parseVendoredModules(bytes.NewReader(files[name]))
+	if err != nil {
+		return nil, err
+	}
+	// The replacements are only parsed in strict mode
+	mf, err := modfile.Parse(modPath, files[modPath], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// replacements of a specific version take precedence over those of
+	// all versions of a module
+	replaced := map[string]string{}
+	for _, r := range mf.Replace {
+		replacement := r.New.Path
+		if r.New.Version != "" {
+			replacement += " " + r.New.Version
+		}
+		if r.Old.Version != "" {
+			replaced[r.Old.Path+"@"+r.Old.Version] = replacement
+		} else if _, ok := replaced[r.Old.Path]; !ok {
+			replaced[r.Old.Path] = replacement
+		}
+	}
+
+	var drift []string
+	required := map[string]struct{}{}
+	for _, require := range mf.Require {
+		required[require.Mod.Path] = struct{}{}
+		replacement, ok := replaced[require.Mod.Path+"@"+require.Mod.Version]
+		if !ok {
+			replacement = replaced[require.Mod.Path]
+		}
+		vm, ok := vendored[require.Mod.Path]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("%s %s is required in go.mod but not vendored", require.Mod.Path, require.Mod.Version))
+		case vm.Version != require.Mod.Version:
+			drift = append(drift, fmt.Sprintf("%s is %s in go.mod but %s in vendor", require.Mod.Path, require.Mod.Version, vm.Version))
+		case !vm.Explicit:
+			drift = append(drift, fmt.Sprintf("%s is required in go.mod but not marked explicit in vendor", require.Mod.Path))
+		case vm.Replace != replacement:
+			drift = append(drift, fmt.Sprintf("%s is replaced by %q in go.mod but by %q in vendor", require.Mod.Path, replacement, vm.Replace))
+		}
+	}
+	for name, vm := range vendored {
+		if _, ok := required[name]; !ok && vm.Explicit {
+			drift = append(drift, fmt.Sprintf("%s is vendored as explicit but not required in go.mod", name))
+		}
+	}
+
+	vendorDir := path.Join(subpath, "vendor")
+	out, err = git("ls-tree", "-r", "-d", "--name-only", commit, "--", vendorDir)
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]struct{}{}
+	for _, dir := range strings.Split(string(out), "\n") {
+		dirs[dir] = struct{}{}
+	}
+	for name, vm := range vendored {
+		for _, pkg := range vm.Packages {
+			if _, ok := dirs[path.Join(vendorDir, pkg)]; !ok {
+				drift = append(drift, fmt.Sprintf("package %s of %s is missing from vendor", pkg, name))
+			}
+		}
+	}
+	sort.Strings(drift)
+	return drift, nil
+}
@@ -0,0 +1,96 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVendoredModules(t *testing.T) {
+	modules, err := parseVendoredModules(strings.NewReader(`# github.com/containerd/ttrpc v1.2.0
+## explicit; go 1.13
+github.com/containerd/ttrpc
+# github.com/gogo/protobuf v1.3.2 => github.com/fork/protobuf v1.3.3
+## explicit
+github.com/gogo/protobuf/proto
+github.com/gogo/protobuf/types
+# golang.org/x/sys v0.1.0
+golang.org/x/sys/unix
+# github.com/local/mod => ../mod
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 3 {
+		t.Fatalf("unexpected modules %v", modules)
+	}
+	if m := modules["github.com/gogo/protobuf"]; m == nil || m.Version != "v1.3.2" || !m.Explicit || len(m.Packages) != 2 || m.Replace != "github.com/fork/protobuf v1.3.3" {
+		t.Errorf("unexpected protobuf module %+v", m)
+	}
+	if m := modules["golang.org/x/sys"]; m == nil || m.Explicit || len(m.Packages) != 1 {
+		t.Errorf("unexpected sys module %+v", m)
+	}
+}
+
+func TestCheckVendorDrift(t *testing.T) {
+	tr := newTestRepo(t, nil)
+	tr.commit("Not vendored", map[string]string{"go.mod": `module github.com/containerd/example
+
+go 1.21
+
+toolchain go1.21.5
+
+require (
+	github.com/containerd/ttrpc v1.2.0
+	github.com/gogo/protobuf v1.3.2
+)
+
+replace github.com/gogo/protobuf => github.com/fork/protobuf v1.3.3
+`})
+	tr.commit("Vendor without replacement", map[string]string{
+		"vendor/github.com/containerd/ttrpc/ttrpc.go":    "package ttrpc\n",
+		"vendor/github.com/gogo/protobuf/proto/proto.go": "package proto\n",
+		"vendor/modules.txt": `# github.com/containerd/ttrpc v1.2.0
+## explicit; go 1.13
+github.com/containerd/ttrpc
+# github.com/gogo/protobuf v1.3.2
+## explicit
+github.com/gogo/protobuf/proto
+`,
+	})
+
+	for _, tc := range []struct {
+		commit   string
+		expected []string
+	}{
+		{"HEAD~1", nil},
+		{"HEAD", []string{`github.com/gogo/protobuf is replaced by "github.com/fork/protobuf v1.3.3" in go.mod but by "" in vendor`}},
+	} {
+		drift, err := checkVendorDrift(tc.commit, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(drift, tc.expected) {
+			t.Errorf("expected drift %q at %s, got %q", tc.expected, tc.commit, drift)
+		}
+	}
+	if _, err := checkVendorDrift("0123456789abcdef0123456789abcdef01234567", ""); err == nil {
+		t.Error("expected error for a missing commit")
+	}
+}
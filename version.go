@@ -0,0 +1,96 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
+)
+
+// Version may be set at build time using
+// -ldflags "-X main.Version=v0.x.y"
+var Version = ""
+
+const toolRepo = "containerd/release-tool"
+
+// toolVersion returns the version of the release tool, falling back to the
+// module version from the build info
+func toolVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+var versionCommand = &cli.Command{
+	Name:  "version",
+	Usage: "show the release tool version and build information",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "check",
+			Usage: "check whether a newer release of the release tool is available",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		current := toolVersion()
+		fmt.Printf("release-tool %s\n", current)
+		fmt.Printf("  go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range info.Settings {
+				switch setting.Key {
+				case "vcs.revision", "vcs.time", "vcs.modified":
+					fmt.Printf("  %s: %s\n", setting.Key, setting.Value)
+				}
+			}
+		}
+
+		if !context.Bool("check") {
+			return nil
+		}
+		if !semver.IsValid(current) {
+			logrus.Infof("release-tool %s is not a released version, skipping the check for a newer release", current)
+			return nil
+		}
+		latest, err := getLatestRelease(toolRepo)
+		if err != nil {
+			return fmt.Errorf("unable to check latest release: %w", err)
+		}
+		if semver.Compare(current, latest.TagName) < 0 {
+			logrus.Warnf("A newer release-tool %s is available, upgrade with: go install github.com/%s@%s", latest.TagName, toolRepo, latest.TagName)
+		} else {
+			logrus.Infof("release-tool is up to date")
+		}
+		return nil
+	},
+}
+
+// getLatestRelease returns the latest published release of the repository
+//
+// See https://docs.github.com/en/rest/releases/releases?apiVersion=2022-11-28#get-the-latest-release
+func getLatestRelease(repo string) (releaseInfo, error) {
+	var info releaseInfo
+	err := getGithubJSON(fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo), &info)
+	return info, err
+}
@@ -0,0 +1,114 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Kinds of warnings collected in the report
+const (
+	warningReplace     = "replace"
+	warningMailmap     = "mailmap"
+	warningOverride    = "override"
+	warningGithub      = "github"
+	warningGitlab      = "gitlab"
+	warningGitea       = "gitea"
+	warningReleaseNote = "release-note"
+	warningLint        = "lint"
+	warningLink        = "link"
+	warningDependency  = "dependency"
+	warningVendor      = "vendor"
+	warningLicense     = "license"
+	warningBuild       = "build"
+	warningSize        = "size"
+)
+
+type warning struct {
+	Kind    string            `json:"kind"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// warningReport collects the warnings found while generating a release so
+// they can be written as a machine readable report
+type warningReport struct {
+	mu       sync.Mutex
+	warnings []warning
+}
+
+var warnings = &warningReport{}
+
+// record adds a warning to the report without logging it
+func (wr *warningReport) record(kind string, fields logrus.Fields, msg string) {
+	w := warning{
+		Kind:    kind,
+		Message: msg,
+	}
+	if len(fields) > 0 {
+		w.Fields = make(map[string]string, len(fields))
+		for k, v := range fields {
+			w.Fields[k] = fmt.Sprint(v)
+		}
+	}
+	wr.mu.Lock()
+	wr.warnings = append(wr.warnings, w)
+	wr.mu.Unlock()
+}
+
+// warn logs the warning and adds it to the report
+func (wr *warningReport) warn(kind string, fields logrus.Fields, msg string) {
+	logrus.WithFields(fields).Warn(msg)
+	wr.record(kind, fields, msg)
+}
+
+// ofKind returns the collected warnings of the kind
+func (wr *warningReport) ofKind(kind string) []warning {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	var found []warning
+	for _, w := range wr.warnings {
+		if w.Kind == kind {
+			found = append(found, w)
+		}
+	}
+	return found
+}
+
+// write writes the collected warnings as JSON to the file
+func (wr *warningReport) write(path string) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	report := struct {
+		Warnings []warning `json:"warnings"`
+	}{
+		Warnings: wr.warnings,
+	}
+	if report.Warnings == nil {
+		report.Warnings = []warning{}
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}